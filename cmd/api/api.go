@@ -1,6 +1,13 @@
 package api
 
 import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/caldav"
+	"github.com/Komilov31/calendar-service/internal/config"
 	"github.com/Komilov31/calendar-service/internal/handler"
 	"github.com/Komilov31/calendar-service/internal/middleware"
 	"github.com/Komilov31/calendar-service/internal/repository"
@@ -9,19 +16,31 @@ import (
 )
 
 type APIServer struct {
-	addr string
+	cfg config.Config
 }
 
-func NewServer(addr string) *APIServer {
-	return &APIServer{addr: addr}
+func NewServer(cfg config.Config) *APIServer {
+	return &APIServer{cfg: cfg}
 }
 
 func (s *APIServer) Run() error {
 	router := gin.Default()
 	router.Use(middleware.LoggingMiddleware()) // навесили всем хэндлерам middleware для логирования
+	router.Use(middleware.ErrorMiddleware())   // ошибки из c.Error отдаются в формате problem+json
+
+	authenticator, err := newAuthenticator(s.cfg)
+	if err != nil {
+		return err
+	}
+	router.Use(middleware.Authenticate(authenticator)) // пользователь запроса из API ключа или JWT
 
-	repository := repository.New()
-	service := service.New(repository)
+	storage, closer, err := newStorage(s.cfg)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	service := service.New(storage)
 	handler := handler.New(service)
 
 	router.POST("/create_event", handler.CreateEvent)
@@ -30,6 +49,77 @@ func (s *APIServer) Run() error {
 	router.GET("/events_for_day", handler.GetEventsForDay)
 	router.GET("/events_for_week", handler.GetEventsForWeek)
 	router.GET("/events_for_month", handler.GetEventsForMonth)
+	router.GET("/events", handler.GetEventsInRange)
+	router.POST("/update_user_settings", handler.UpdateUserSettings)
+	router.GET("/user_settings", handler.GetUserSettings)
+	router.POST("/freebusy", handler.FreeBusy)
+	router.POST("/scheduling/slots", handler.FindSlots)
+
+	router.POST("/v2/users", handler.CreateUserV2)
+	router.GET("/v2/users/:user_id", handler.GetUserV2)
+	router.PUT("/v2/users/:user_id", handler.ReplaceUserV2)
+	router.DELETE("/v2/users/:user_id", handler.DeleteUserV2)
+
+	events := router.Group("/v2/users/:user_id/events")
+	events.GET("", handler.ListEventsV2)
+	events.POST("", handler.CreateEventV2)
+	events.GET("/:event_id", handler.GetEventV2)
+	events.PUT("/:event_id", handler.ReplaceEventV2)
+	events.PATCH("/:event_id", handler.PatchEventV2)
+	events.DELETE("/:event_id", handler.DeleteEventV2)
+	events.POST("/import", handler.ImportEventsV2)
+	router.GET("/v2/users/:user_id/calendar.ics", handler.ExportCalendarV2)
+	router.GET("/v2/users/:user_id/invitations", handler.ListInvitationsV2)
+	router.PUT("/v2/users/:user_id/invitations/:event_id", handler.RespondInvitationV2)
+
+	calendars := router.Group("/v2/users/:user_id/calendars")
+	calendars.GET("", handler.ListCalendarsV2)
+	calendars.POST("", handler.CreateCalendarV2)
+	calendars.GET("/:calendar_id", handler.GetCalendarV2)
+	calendars.PUT("/:calendar_id", handler.ReplaceCalendarV2)
+	calendars.DELETE("/:calendar_id", handler.DeleteCalendarV2)
+	calendars.GET("/:calendar_id/acl", handler.ListACLV2)
+	calendars.PUT("/:calendar_id/acl/:grantee_id", handler.PutACLV2)
+	calendars.DELETE("/:calendar_id/acl/:grantee_id", handler.DeleteACLV2)
+
+	// календарные клиенты подключаются по CalDAV к /caldav/ или через /.well-known/caldav
+	caldav.New(service).Register(router)
+
+	return router.Run(s.cfg.Port)
+}
+
+// без ключей и секрета сервис не запускается, если аутентификация не выключена явно
+func newAuthenticator(cfg config.Config) (*auth.Authenticator, error) {
+	if cfg.AuthDisabled {
+		log.Println("authentication is disabled by AUTH_DISABLED")
+		return nil, nil
+	}
+
+	keys, err := auth.ParseKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator := auth.New(cfg.JWTSecret, keys)
+	if !authenticator.Enabled() {
+		return nil, fmt.Errorf("authentication is not configured: set API_KEYS or JWT_SECRET, or AUTH_DISABLED=true")
+	}
+
+	return authenticator, nil
+}
 
-	return router.Run(s.addr)
+// выбирает хранилище по конфигу: в памяти (по умолчанию) или на диске с журналом
+func newStorage(cfg config.Config) (service.EventStorage, io.Closer, error) {
+	switch cfg.Storage {
+	case config.StorageMemory:
+		return repository.New(), io.NopCloser(nil), nil
+	case config.StorageFile:
+		repo, err := repository.NewFile(cfg.DataDir, cfg.SnapshotEvery)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open file storage: %w", err)
+		}
+		return repo, repo, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage type: %s", cfg.Storage)
+	}
 }
@@ -2,13 +2,14 @@ package main
 
 import (
 	"log"
+	_ "time/tzdata" // база зон IANA внутри бинарника, в контейнере ее может не быть
 
 	"github.com/Komilov31/calendar-service/cmd/api"
 	"github.com/Komilov31/calendar-service/internal/config"
 )
 
 func main() {
-	apiServer := api.NewServer(config.Envs.Port)
+	apiServer := api.NewServer(config.Envs)
 	if err := apiServer.Run(); err != nil {
 		log.Fatal(err)
 	}
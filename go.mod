@@ -30,8 +30,8 @@ require (
 require (
 	github.com/gabriel-vasile/mimetype v1.4.8 // indirect
 	github.com/gin-gonic/gin v1.10.1
-	github.com/go-playground/locales v0.14.1 // indirect
-	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/go-playground/locales v0.14.1
+	github.com/go-playground/universal-translator v0.18.1
 	github.com/joho/godotenv v1.5.1
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/sirupsen/logrus v1.9.3
@@ -0,0 +1,106 @@
+// Package apperror описывает ошибки предметной области, общие для репозитория,
+// сервиса и обработчиков. HTTP статус выбирается по виду ошибки в одном месте -
+// в middleware.ErrorMiddleware.
+package apperror
+
+import "errors"
+
+type Kind string
+
+const (
+	KindNotFound   Kind = "not_found"
+	KindConflict   Kind = "conflict"
+	KindValidation Kind = "validation"
+	KindForbidden  Kind = "forbidden"
+	KindInternal   Kind = "internal"
+
+	// учетные данные не переданы или неверны
+	KindUnauthorized Kind = "unauthorized"
+
+	// условие запроса (If-Match) не выполнено
+	KindPreconditionFailed Kind = "precondition_failed"
+
+	// тело запроса больше допустимого
+	KindTooLarge Kind = "too_large"
+)
+
+// Error - ошибка с видом и стабильным машиночитаемым кодом, например event_not_found.
+// Code не меняется между версиями, клиенты могут на него опираться; Message - для людей.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError - нарушенное правило валидации: JSON путь к полю, правило и его параметр
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// ValidationFields - ошибка валидации со списком всех нарушенных правил
+func ValidationFields(message string, fields []FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: message, Fields: fields}
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func Validation(code, message string) *Error {
+	return New(KindValidation, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func PreconditionFailed(code, message string) *Error {
+	return New(KindPreconditionFailed, code, message)
+}
+
+func TooLarge(code, message string) *Error {
+	return New(KindTooLarge, code, message)
+}
+
+// Internal оборачивает непредвиденную ошибку, ее текст не показывается клиенту
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal", Message: "internal error", Err: err}
+}
+
+// From достает *Error из цепочки ошибок, остальные ошибки считаются внутренними
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	return Internal(err)
+}
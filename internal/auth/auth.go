@@ -0,0 +1,144 @@
+// Package auth проверяет учетные данные запроса: API ключи, которые хранятся
+// только в виде SHA-256 хешей, и JWT с подписью HS256, которые проверяются
+// локально по общему секрету.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+)
+
+// ScopeAdmin разрешает действовать от имени любого пользователя
+const ScopeAdmin = "admin"
+
+var (
+	ErrNoCredentials      = apperror.Unauthorized("missing_credentials", "authentication required")
+	ErrInvalidCredentials = apperror.Unauthorized("invalid_credentials", "invalid API key or token")
+	ErrTokenExpired       = apperror.Unauthorized("token_expired", "token has expired")
+)
+
+// Identity - аутентифицированный пользователь и его права
+type Identity struct {
+	UserId int
+	Scopes []string
+}
+
+func (i Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+type Authenticator struct {
+	// пользователи по SHA-256 хешу ключа в hex
+	keys   map[string]Identity
+	secret []byte
+}
+
+// New создает проверку с секретом для JWT и API ключами из ParseKeys. Пустой
+// секрет отключает JWT.
+func New(secret string, keys map[string]Identity) *Authenticator {
+	return &Authenticator{keys: keys, secret: []byte(secret)}
+}
+
+// Enabled сообщает, настроен ли хотя бы один способ аутентификации
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.keys) > 0 || len(a.secret) > 0)
+}
+
+// HashKey возвращает хеш API ключа в том виде, в котором он хранится в настройках
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseKeys разбирает список ключей через запятую. Ключ записывается как
+// hash:user_id или hash:user_id:scopes, где scopes - права через пробел.
+func ParseKeys(value string) (map[string]Identity, error) {
+	keys := make(map[string]Identity)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid API key entry %q: want hash:user_id[:scopes]", entry)
+		}
+
+		hash := strings.ToLower(parts[0])
+		if sum, err := hex.DecodeString(hash); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key entry %q: hash must be SHA-256 in hex", entry)
+		}
+
+		userId, err := strconv.Atoi(parts[1])
+		if err != nil || userId <= 0 {
+			return nil, fmt.Errorf("invalid API key entry %q: user_id must be a positive integer", entry)
+		}
+
+		identity := Identity{UserId: userId}
+		if len(parts) == 3 {
+			identity.Scopes = strings.Fields(parts[2])
+		}
+		keys[hash] = identity
+	}
+
+	return keys, nil
+}
+
+// Authenticate проверяет учетные данные запроса:
+//   - Authorization: Bearer <JWT или API ключ>
+//   - X-API-Key: <API ключ>
+//   - Authorization: Basic с API ключом или JWT в качестве пароля, для календарных клиентов
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(key)
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return Identity{}, ErrNoCredentials
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return a.credentials(credentials)
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return Identity{}, ErrInvalidCredentials
+		}
+		_, password, _ := strings.Cut(string(decoded), ":")
+		return a.credentials(password)
+	default:
+		return Identity{}, ErrInvalidCredentials
+	}
+}
+
+// JWT отличается от API ключа тремя частями через точку
+func (a *Authenticator) credentials(value string) (Identity, error) {
+	if strings.Count(value, ".") == 2 {
+		return a.token(value)
+	}
+
+	return a.apiKey(value)
+}
+
+func (a *Authenticator) apiKey(key string) (Identity, error) {
+	identity, ok := a.keys[HashKey(key)]
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func request(headers ...string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return r
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(HashKey("user-key") + ":7, " + HashKey("admin-key") + ":1:admin events")
+	require.NoError(t, err)
+	assert.Equal(t, Identity{UserId: 7}, keys[HashKey("user-key")])
+	assert.Equal(t, Identity{UserId: 1, Scopes: []string{"admin", "events"}}, keys[HashKey("admin-key")])
+
+	keys, err = ParseKeys("")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, value := range []string{"plain-key:7", HashKey("key"), HashKey("key") + ":0"} {
+		_, err := ParseKeys(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	a := New("", map[string]Identity{HashKey("user-key"): {UserId: 7}})
+	require.True(t, a.Enabled())
+
+	for name, r := range map[string]*http.Request{
+		"Header": request("X-API-Key", "user-key"),
+		"Bearer": request("Authorization", "Bearer user-key"),
+		"Basic":  request("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("anyone:user-key"))),
+	} {
+		identity, err := a.Authenticate(r)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 7, identity.UserId, name)
+	}
+
+	_, err := a.Authenticate(request("X-API-Key", "other-key"))
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = a.Authenticate(request())
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestToken(t *testing.T) {
+	a := New("secret", nil)
+
+	token, err := a.Token(Identity{UserId: 3, Scopes: []string{ScopeAdmin}}, time.Hour)
+	require.NoError(t, err)
+
+	identity, err := a.Authenticate(request("Authorization", "Bearer "+token))
+	require.NoError(t, err)
+	assert.Equal(t, 3, identity.UserId)
+	assert.True(t, identity.HasScope(ScopeAdmin))
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, err := a.Token(Identity{UserId: 3}, -time.Hour)
+		require.NoError(t, err)
+
+		_, err = a.Authenticate(request("Authorization", "Bearer "+expired))
+		assert.Equal(t, ErrTokenExpired, err)
+	})
+
+	t.Run("Other secret", func(t *testing.T) {
+		forged, err := New("other", nil).Token(Identity{UserId: 3}, time.Hour)
+		require.NoError(t, err)
+
+		_, err = a.Authenticate(request("Authorization", "Bearer "+forged))
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("Without exp", func(t *testing.T) {
+		signed := encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(`{"sub":"3","iat":1700000000}`))
+		endless := signed + "." + encode(a.sign(signed))
+
+		_, err := a.Authenticate(request("Authorization", "Bearer "+endless))
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("Algorithm none", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		unsigned := encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+
+		_, err := a.Authenticate(request("Authorization", "Bearer "+unsigned))
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := New("", map[string]Identity{HashKey("key"): {UserId: 1}}).Authenticate(request("Authorization", "Bearer "+token))
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+}
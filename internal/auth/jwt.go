@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// допустимое расхождение часов сервиса и выпустившей токен стороны
+const clockSkew = time.Minute
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// claims - используемые поля токена: sub - id пользователя, scope - права через пробел.
+// exp обязателен: бессрочные токены не принимаются
+type claims struct {
+	Subject   string `json:"sub"`
+	Scope     string `json:"scope,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// Token выпускает JWT для identity со сроком действия ttl
+func (a *Authenticator) Token(identity Identity, ttl time.Duration) (string, error) {
+	now := time.Now()
+	payload, err := json.Marshal(claims{
+		Subject:   strconv.Itoa(identity.UserId),
+		Scope:     strings.Join(identity.Scopes, " "),
+		ExpiresAt: now.Add(ttl).Unix(),
+		IssuedAt:  now.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	head, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	signed := encode(head) + "." + encode(payload)
+	return signed + "." + encode(a.sign(signed)), nil
+}
+
+// token проверяет подпись HS256 и сроки действия JWT. Другие алгоритмы, в том
+// числе none, не принимаются.
+func (a *Authenticator) token(token string) (Identity, error) {
+	if len(a.secret) == 0 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	var head header
+	if err := decodeJSON(parts[0], &head); err != nil || head.Alg != "HS256" {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, a.sign(parts[0]+"."+parts[1])) {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	var c claims
+	if err := decodeJSON(parts[1], &c); err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	if c.ExpiresAt == 0 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return Identity{}, ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(c.NotBefore, 0)) {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	userId, err := strconv.Atoi(c.Subject)
+	if err != nil || userId <= 0 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return Identity{UserId: userId, Scopes: strings.Fields(c.Scope)}, nil
+}
+
+func (a *Authenticator) sign(data string) []byte {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJSON(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,192 @@
+// Package caldav - подмножество CalDAV (RFC 4791) для календарных клиентов:
+// PROPFIND, REPORT calendar-query и calendar-multiget, GET, PUT и DELETE ресурсов
+// .ics. Синхронизация по getctag календаря и ETag ресурсов.
+//
+// Каждый пользователь - принципал /caldav/users/{user_id}/ с одним календарем
+// /caldav/users/{user_id}/calendar/. Событие - ресурс {uid}.ics, где uid - UID
+// события в iCalendar. Все изменения идут через сервис, как и в JSON API.
+// Клиенты аутентифицируются по Basic с API ключом или JWT в качестве пароля.
+package caldav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	methodPropfind = "PROPFIND"
+	methodReport   = "REPORT"
+
+	resourceSuffix = ".ics"
+	calendarName   = "calendar"
+
+	// тип ресурса события для getcontenttype
+	eventContentType = "text/calendar; charset=utf-8; component=vevent"
+)
+
+var errNoSuchResource = apperror.NotFound("resource_not_found", "no such calendar resource")
+
+type EventsService interface {
+	CreateEvent(int, model.Event) (model.Event, error)
+	UpdateEvent(int, model.UpdateEvent) (model.Event, error)
+	DeleteEvent(int, model.DeleteEvent) error
+	GetEvent(int, int, int, *time.Location) (model.Event, error)
+	GetEventByUID(int, int, string, *time.Location) (model.Event, error)
+	GetEvents(int, int) ([]*model.Event, error)
+	GetEventsInRange(int, int, time.Time, time.Time) ([]*model.Event, error)
+	Location(int, string) (*time.Location, error)
+}
+
+type Handler struct {
+	service EventsService
+}
+
+func New(eventsService EventsService) *Handler {
+	return &Handler{
+		service: eventsService,
+	}
+}
+
+// Register подключает маршруты CalDAV к роутеру
+func (h *Handler) Register(router gin.IRouter) {
+	router.GET("/.well-known/caldav", h.WellKnown)
+	router.Handle(methodPropfind, "/.well-known/caldav", h.WellKnown)
+
+	dav := router.Group("/caldav")
+	dav.Handle(http.MethodOptions, "/*path", h.Options)
+	dav.Handle(methodPropfind, "/", h.PropfindRoot)
+	dav.Handle(methodPropfind, "/users/:user_id/", h.PropfindPrincipal)
+	dav.Handle(methodPropfind, "/users/:user_id/"+calendarName+"/", h.PropfindCalendar)
+	dav.Handle(methodReport, "/users/:user_id/"+calendarName+"/", h.Report)
+	dav.Handle(methodPropfind, "/users/:user_id/"+calendarName+"/:resource", h.PropfindResource)
+	dav.GET("/users/:user_id/"+calendarName+"/:resource", h.GetResource)
+	dav.HEAD("/users/:user_id/"+calendarName+"/:resource", h.GetResource)
+	dav.PUT("/users/:user_id/"+calendarName+"/:resource", h.PutResource)
+	dav.DELETE("/users/:user_id/"+calendarName+"/:resource", h.DeleteResource)
+}
+
+// WellKnown направляет клиента на корень CalDAV (RFC 6764)
+func (h *Handler) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, "/caldav/")
+}
+
+// Options сообщает клиенту, что сервер поддерживает CalDAV
+func (h *Handler) Options(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", strings.Join([]string{http.MethodOptions, methodPropfind, methodReport, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete}, ", "))
+	c.Status(http.StatusOK)
+}
+
+func principalPath(userId int) string {
+	return fmt.Sprintf("/caldav/users/%d/", userId)
+}
+
+func calendarPath(userId int) string {
+	return principalPath(userId) + calendarName + "/"
+}
+
+func resourcePath(userId int, event *model.Event) string {
+	return calendarPath(userId) + url.PathEscape(ical.UID(event)) + resourceSuffix
+}
+
+// ctag меняется при любом изменении календаря: создании, изменении или удалении события
+func ctag(events []*model.Event) string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, fmt.Sprintf("%d:%d", event.EventId, event.Version))
+	}
+	slices.Sort(ids)
+
+	sum := sha256.Sum256([]byte(strings.Join(ids, ";")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func userId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil || id <= 0 {
+		c.Error(apperror.Validation("invalid_user_id", "invalid user_id"))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// currentUser - аутентифицированный пользователь, без аутентификации - пользователь из пути
+func currentUser(c *gin.Context, userId int) int {
+	if identity, ok := middleware.CurrentIdentity(c); ok {
+		return identity.UserId
+	}
+
+	return userId
+}
+
+// uid из имени ресурса {uid}.ics
+func resourceUID(c *gin.Context) (string, bool) {
+	uid, ok := strings.CutSuffix(c.Param("resource"), resourceSuffix)
+	if !ok || uid == "" {
+		c.Error(errNoSuchResource)
+		return "", false
+	}
+
+	return uid, true
+}
+
+// события календаря пользователя. У пользователя без событий календарь пуст
+func (h *Handler) events(c *gin.Context, userId int) ([]*model.Event, error) {
+	events, err := h.service.GetEvents(middleware.Actor(c, userId), userId)
+	if err != nil && apperror.From(err).Kind != apperror.KindNotFound {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// findEvent находит событие ресурса uid без перебора календаря: по сохраненному UID
+// или по id из UID, построенного по id. Без ошибки и события ресурса нет.
+func (h *Handler) findEvent(c *gin.Context, userId int, uid string) (*model.Event, error) {
+	actor := middleware.Actor(c, userId)
+	event, err := h.service.GetEventByUID(actor, userId, uid, time.UTC)
+	if err == nil {
+		return &event, nil
+	}
+	if apperror.From(err).Kind != apperror.KindNotFound {
+		return nil, err
+	}
+
+	eventId, ok := ical.EventId(uid)
+	if !ok {
+		return nil, nil
+	}
+
+	event, err = h.service.GetEvent(actor, userId, eventId, time.UTC)
+	if err != nil {
+		if apperror.From(err).Kind == apperror.KindNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// в календаре только собственные события пользователя, приглашений в нем нет
+	if event.UserId != userId || ical.UID(&event) != uid {
+		return nil, nil
+	}
+
+	return &event, nil
+}
+
+func calendarData(event *model.Event) []byte {
+	return ical.Marshal(ical.Calendar{Events: []*model.Event{event}})
+}
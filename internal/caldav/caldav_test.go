@@ -0,0 +1,306 @@
+package caldav
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const calendarURL = "/caldav/users/1/calendar/"
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+
+	repo := repository.New()
+	repo.CreateUser(model.User{UserId: 1, Name: "Alice", Email: "alice@example.com"})
+	New(service.New(repo)).Register(router)
+	return router
+}
+
+func send(router *gin.Engine, method, url, body string, headers ...string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func resource(uid, summary string, start time.Time) string {
+	return "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:" + uid + "\r\n" +
+		"SUMMARY:" + summary + "\r\n" +
+		"DTSTART:" + start.UTC().Format("20060102T150405Z") + "\r\n" +
+		"DTEND:" + start.Add(time.Hour).UTC().Format("20060102T150405Z") + "\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+}
+
+func TestDiscovery(t *testing.T) {
+	router := setupRouter()
+
+	t.Run("WellKnown", func(t *testing.T) {
+		w := send(router, methodPropfind, "/.well-known/caldav", "")
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/caldav/", w.Header().Get("Location"))
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		w := send(router, http.MethodOptions, calendarURL, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("DAV"), "calendar-access")
+	})
+
+	t.Run("Principal", func(t *testing.T) {
+		body := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+			`<D:prop><C:calendar-home-set/><D:owner/></D:prop></D:propfind>`
+		w := send(router, methodPropfind, "/caldav/users/1/", body, "Depth", "0")
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "<C:calendar-home-set><D:href>/caldav/users/1/</D:href></C:calendar-home-set>")
+		// неизвестное свойство отдается с 404
+		assert.Contains(t, w.Body.String(), "<D:owner/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>")
+	})
+
+	t.Run("InvalidUser", func(t *testing.T) {
+		w := send(router, methodPropfind, "/caldav/users/abc/", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestCurrentUserPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(middleware.Authenticate(auth.New("", map[string]auth.Identity{auth.HashKey("secret-key"): {UserId: 5}})))
+	New(service.New(repository.New())).Register(router)
+
+	body := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop><D:current-user-principal/></D:prop></D:propfind>`
+
+	t.Run("Authenticated", func(t *testing.T) {
+		credentials := base64.StdEncoding.EncodeToString([]byte("user:secret-key"))
+		w := send(router, methodPropfind, "/caldav/", body, "Depth", "0", "Authorization", "Basic "+credentials)
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "<D:current-user-principal><D:href>/caldav/users/5/</D:href></D:current-user-principal>")
+	})
+
+	t.Run("Challenge", func(t *testing.T) {
+		w := send(router, methodPropfind, "/caldav/", body)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+	})
+}
+
+func TestResources(t *testing.T) {
+	router := setupRouter()
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+
+	ctag := func() string {
+		body := `<D:propfind xmlns:D="DAV:" xmlns:CS="http://calendarserver.org/ns/"><D:prop><CS:getctag/></D:prop></D:propfind>`
+		w := send(router, methodPropfind, calendarURL, body, "Depth", "0")
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		return w.Body.String()
+	}
+	empty := ctag()
+
+	// создание с If-None-Match: *
+	w := send(router, http.MethodPut, calendarURL+"standup.ics", resource("standup", "Standup", start), "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	tag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"1-[0-9a-f]{8}"$`, tag)
+	created := ctag()
+	assert.NotEqual(t, empty, created)
+
+	t.Run("CreateExisting", func(t *testing.T) {
+		w := send(router, http.MethodPut, calendarURL+"standup.ics", resource("standup", "Standup", start), "If-None-Match", "*")
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("UIDMismatch", func(t *testing.T) {
+		w := send(router, http.MethodPut, calendarURL+"other.ics", resource("standup", "Standup", start))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InPast", func(t *testing.T) {
+		w := send(router, http.MethodPut, calendarURL+"past.ics", resource("past", "Past", time.Now().Add(-48*time.Hour)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		w := send(router, http.MethodGet, calendarURL+"standup.ics", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tag, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), "UID:standup\r\n")
+		assert.Contains(t, w.Body.String(), "SUMMARY:Standup\r\n")
+
+		for header, status := range map[string]int{
+			tag:           http.StatusNotModified,
+			`W/` + tag:    http.StatusNotModified,
+			`"3", ` + tag: http.StatusNotModified,
+			`*`:           http.StatusNotModified,
+			`"1"`:         http.StatusOK,
+			`"1-abcdef"`:  http.StatusOK,
+		} {
+			w = send(router, http.MethodGet, calendarURL+"standup.ics", "", "If-None-Match", header)
+			assert.Equal(t, status, w.Code, header)
+		}
+
+		w = send(router, http.MethodGet, calendarURL+"missing.ics", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Listing", func(t *testing.T) {
+		body := `<D:propfind xmlns:D="DAV:"><D:prop><D:getetag/></D:prop></D:propfind>`
+		w := send(router, methodPropfind, calendarURL, body, "Depth", "1")
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "<D:href>"+calendarURL+"standup.ics</D:href>")
+		assert.Contains(t, w.Body.String(), "<D:getetag>&#34;"+strings.Trim(tag, `"`)+"&#34;</D:getetag>")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		w := send(router, http.MethodPut, calendarURL+"standup.ics", resource("standup", "Daily", start), "If-Match", `"5"`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = send(router, http.MethodPut, calendarURL+"standup.ics", resource("standup", "Daily", start), "If-Match", `"1"`)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		assert.Regexp(t, `^"2-[0-9a-f]{8}"$`, w.Header().Get("ETag"))
+		assert.NotEqual(t, created, ctag())
+
+		w = send(router, http.MethodGet, calendarURL+"standup.ics", "")
+		assert.Contains(t, w.Body.String(), "SUMMARY:Daily\r\n")
+	})
+
+	t.Run("Multiget", func(t *testing.T) {
+		body := `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+			`<D:prop><D:getetag/><C:calendar-data/></D:prop>` +
+			`<D:href>` + calendarURL + `standup.ics</D:href><D:href>` + calendarURL + `missing.ics</D:href>` +
+			`</C:calendar-multiget>`
+		w := send(router, methodReport, calendarURL, body, "Depth", "1")
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Contains(t, w.Body.String(), "SUMMARY:Daily&#xD;&#xA;")
+		assert.Contains(t, w.Body.String(), "<D:href>"+calendarURL+"missing.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>")
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		query := func(from, to time.Time) string {
+			body := `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+				`<D:prop><D:getetag/></D:prop><C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">` +
+				`<C:time-range start="` + from.UTC().Format(timeRangeLayout) + `" end="` + to.UTC().Format(timeRangeLayout) + `"/>` +
+				`</C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`
+			w := send(router, methodReport, calendarURL, body, "Depth", "1")
+			require.Equal(t, http.StatusMultiStatus, w.Code)
+			return w.Body.String()
+		}
+
+		assert.Contains(t, query(start.Add(-time.Hour), start.Add(time.Hour)), "standup.ics")
+		assert.NotContains(t, query(start.Add(24*time.Hour), start.Add(48*time.Hour)), "standup.ics")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := send(router, http.MethodDelete, calendarURL+"standup.ics", "", "If-Match", `"1"`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = send(router, http.MethodDelete, calendarURL+"standup.ics", "", "If-Match", `"2"`)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = send(router, http.MethodGet, calendarURL+"standup.ics", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestResourceLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+
+	repo := repository.New()
+	repo.CreateUser(model.User{UserId: 1, Name: "Alice", Email: "alice@example.com"})
+	repo.CreateUser(model.User{UserId: 2, Name: "Bob", Email: "bob@example.com"})
+	New(service.New(repo)).Register(router)
+
+	start := model.Date(time.Now().Add(48 * time.Hour).Truncate(time.Hour))
+	own, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Created in API", Start: start})
+	require.NoError(t, err)
+	invitation, err := repo.CreateEvent(model.Event{UserId: 2, Text: "Invitation", Start: start, Attendees: []model.Attendee{{UserId: 1}}})
+	require.NoError(t, err)
+
+	// события из JSON API доступны по UID, построенному по id
+	w := send(router, http.MethodGet, calendarURL+ical.UID(&own)+".ics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SUMMARY:Created in API\r\n")
+
+	// приглашения в календарь пользователя не входят
+	w = send(router, http.MethodGet, calendarURL+ical.UID(&invitation)+".ics", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// UID из клиента, похожий на построенный по id, не путается с событием этого id
+	w = send(router, http.MethodPut, calendarURL+"999@calendar-service.ics", resource("999@calendar-service", "Imported", time.Time(start)), "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = send(router, http.MethodGet, calendarURL+"999@calendar-service.ics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SUMMARY:Imported\r\n")
+}
+
+func TestResourceETagFollowsRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(middleware.Authenticate(auth.New("", map[string]auth.Identity{
+		auth.HashKey("viewer-key"):   {UserId: 2},
+		auth.HashKey("freebusy-key"): {UserId: 3},
+	})))
+
+	repo := repository.New()
+	for _, user := range []model.User{{UserId: 1, Name: "Alice", Email: "alice@example.com"}, {UserId: 2, Name: "Bob", Email: "bob@example.com"}, {UserId: 3, Name: "Carol", Email: "carol@example.com"}} {
+		_, err := repo.CreateUser(user)
+		require.NoError(t, err)
+	}
+	New(service.New(repo)).Register(router)
+
+	start := model.Date(time.Now().Add(48 * time.Hour).Truncate(time.Hour))
+	event, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Salary review", Start: start})
+	require.NoError(t, err)
+	for grantee, role := range map[int]string{2: model.RoleViewer, 3: model.RoleFreeBusy} {
+		_, err := repo.PutACL(1, model.ACLEntry{CalendarId: event.CalendarId, UserId: grantee, Role: role})
+		require.NoError(t, err)
+	}
+
+	get := func(key string, headers ...string) *httptest.ResponseRecorder {
+		credentials := base64.StdEncoding.EncodeToString([]byte("user:" + key))
+		return send(router, http.MethodGet, calendarURL+ical.UID(&event)+".ics", "", append([]string{"Authorization", "Basic " + credentials}, headers...)...)
+	}
+
+	viewer := get("viewer-key")
+	require.Equal(t, http.StatusOK, viewer.Code, viewer.Body.String())
+	assert.Contains(t, viewer.Body.String(), "SUMMARY:Salary review\r\n")
+
+	freebusy := get("freebusy-key")
+	require.Equal(t, http.StatusOK, freebusy.Code, freebusy.Body.String())
+	assert.NotContains(t, freebusy.Body.String(), "Salary review")
+
+	// у разных представлений одной версии разные теги, тег читателя не дает 304 на время
+	assert.NotEqual(t, viewer.Header().Get("ETag"), freebusy.Header().Get("ETag"))
+	assert.Contains(t, freebusy.Header().Get("Vary"), "Authorization")
+
+	w := get("freebusy-key", "If-None-Match", viewer.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = get("freebusy-key", "If-None-Match", freebusy.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
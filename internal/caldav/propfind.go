@@ -0,0 +1,170 @@
+package caldav
+
+import (
+	"fmt"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// PropfindRoot отвечает на запрос к корню. По current-user-principal клиент находит
+// принципал аутентифицированного пользователя. Без аутентификации текущего
+// пользователя нет, и клиент переходит к принципалу по адресу из настроек.
+func (h *Handler) PropfindRoot(c *gin.Context) {
+	req, ok := propfindRequest(c)
+	if !ok {
+		return
+	}
+
+	props := properties{
+		{propResourceType, "<D:collection/>"},
+		{propDisplayName, "calendar-service"},
+	}
+	if identity, ok := middleware.CurrentIdentity(c); ok {
+		props = append(props, property{propPrincipal, href(principalPath(identity.UserId))})
+	}
+
+	ms := newMultistatus()
+	ms.response("/caldav/", props, req)
+	ms.send(c)
+}
+
+// PropfindPrincipal отдает свойства принципала, главное из них - calendar-home-set
+func (h *Handler) PropfindPrincipal(c *gin.Context) {
+	userId, ok := userId(c)
+	if !ok {
+		return
+	}
+
+	req, ok := propfindRequest(c)
+	if !ok {
+		return
+	}
+
+	ms := newMultistatus()
+	ms.response(principalPath(userId), principalProperties(userId, currentUser(c, userId)), req)
+	if depth(c) > 0 {
+		events, err := h.events(c, userId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		ms.response(calendarPath(userId), calendarProperties(userId, events), req)
+	}
+	ms.send(c)
+}
+
+// PropfindCalendar отдает свойства календаря, с Depth: 1 - и свойства всех его ресурсов.
+// По getctag клиент узнает, что календарь изменился, по getetag - какие ресурсы загрузить.
+func (h *Handler) PropfindCalendar(c *gin.Context) {
+	userId, ok := userId(c)
+	if !ok {
+		return
+	}
+
+	req, ok := propfindRequest(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.events(c, userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ms := newMultistatus()
+	ms.response(calendarPath(userId), calendarProperties(userId, events), req)
+	if depth(c) > 0 {
+		for _, event := range events {
+			ms.response(resourcePath(userId, event), resourceProperties(event), req)
+		}
+	}
+	ms.send(c)
+}
+
+func (h *Handler) PropfindResource(c *gin.Context) {
+	userId, ok := userId(c)
+	if !ok {
+		return
+	}
+
+	uid, ok := resourceUID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := propfindRequest(c)
+	if !ok {
+		return
+	}
+
+	event, err := h.findEvent(c, userId, uid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if event == nil {
+		c.Error(errNoSuchResource)
+		return
+	}
+
+	ms := newMultistatus()
+	ms.response(resourcePath(userId, event), resourceProperties(event), req)
+	ms.send(c)
+}
+
+func propfindRequest(c *gin.Context) (davRequest, bool) {
+	req, err := readRequest(c)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_xml", "invalid PROPFIND body: "+err.Error()))
+		return davRequest{}, false
+	}
+
+	return req, true
+}
+
+// Depth: infinity не поддерживается и считается как 1 (RFC 4918, 9.1 разрешает отказ)
+func depth(c *gin.Context) int {
+	if c.GetHeader("Depth") == "0" {
+		return 0
+	}
+
+	return 1
+}
+
+func principalProperties(userId, current int) properties {
+	return properties{
+		{propResourceType, "<D:collection/><D:principal/>"},
+		{propDisplayName, fmt.Sprintf("user %d", userId)},
+		{propPrincipal, href(principalPath(current))},
+		{propPrincipalURL, href(principalPath(userId))},
+		{propCalendarHome, href(principalPath(userId))},
+	}
+}
+
+func calendarProperties(userId int, events []*model.Event) properties {
+	return properties{
+		{propResourceType, "<D:collection/><C:calendar/>"},
+		{propDisplayName, fmt.Sprintf("calendar-service: user %d", userId)},
+		{propSupportedCalendar, `<C:comp name="VEVENT"/>`},
+		{propSupportedReports, "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"},
+		{propPrivileges, "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>"},
+		{propCTag, ctag(events)},
+		{propETag, escape(`"` + ctag(events) + `"`)},
+	}
+}
+
+// calendar-data отдается только по явному запросу
+func resourceProperties(event *model.Event) properties {
+	data := calendarData(event)
+	return properties{
+		{propResourceType, ""},
+		{propETag, escape(resourceETag(event, data))},
+		{propContentType, eventContentType},
+		{propCalendarData, escape(string(data))},
+	}
+}
@@ -0,0 +1,131 @@
+package caldav
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// максимальный период time-range, для которого серии разворачиваются точно.
+// Для более длинного или открытого периода серии попадают в ответ целиком.
+const maxRangeSpan = 366 * 24 * time.Hour
+
+// Report выполняет calendar-multiget (ресурсы по списку адресов) и
+// calendar-query (ресурсы, пересекающиеся с time-range). Других фильтров,
+// кроме time-range, нет: в календаре только VEVENT.
+func (h *Handler) Report(c *gin.Context) {
+	userId, ok := userId(c)
+	if !ok {
+		return
+	}
+
+	req, err := readRequest(c)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_xml", "invalid REPORT body: "+err.Error()))
+		return
+	}
+
+	ms := newMultistatus()
+	switch req.root {
+	case reportMultiget:
+		for _, path := range req.hrefs {
+			event, err := h.findEvent(c, userId, hrefUID(path))
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if event == nil {
+				ms.notFound(path)
+				continue
+			}
+			ms.response(resourcePath(userId, event), resourceProperties(event), req)
+		}
+	case reportQuery:
+		events, err := h.events(c, userId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		events, err = h.query(c, userId, events, req.from, req.to)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for _, event := range events {
+			ms.response(resourcePath(userId, event), resourceProperties(event), req)
+		}
+	default:
+		c.Error(apperror.Forbidden("unsupported_report", "only calendar-query and calendar-multiget reports are supported"))
+		return
+	}
+	ms.send(c)
+}
+
+// query оставляет события, пересекающиеся с [from, to). Серия попадает в ответ,
+// если в периоде есть хотя бы один ее экземпляр.
+func (h *Handler) query(c *gin.Context, userId int, events []*model.Event, from, to time.Time) ([]*model.Event, error) {
+	if from.IsZero() && to.IsZero() {
+		return events, nil
+	}
+
+	if from.IsZero() || to.IsZero() || to.Sub(from) > maxRangeSpan {
+		var result []*model.Event
+		for _, event := range events {
+			if event.IsRecurring() || overlaps(event, from, to) {
+				result = append(result, event)
+			}
+		}
+		return result, nil
+	}
+
+	occurrences, err := h.service.GetEventsInRange(middleware.Actor(c, userId), userId, from, to)
+	if err != nil && apperror.From(err).Kind != apperror.KindNotFound {
+		return nil, err
+	}
+
+	// экземпляры серии приходят с id серии
+	ids := make(map[int]bool, len(occurrences))
+	for _, occurrence := range occurrences {
+		ids[occurrence.EventId] = true
+	}
+
+	var result []*model.Event
+	for _, event := range events {
+		if ids[event.EventId] {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+// пересечение с полуоткрытым периодом, нулевая граница не ограничивает
+func overlaps(event *model.Event, from, to time.Time) bool {
+	start, end := time.Time(event.Start), time.Time(event.End)
+	if !from.IsZero() && !end.After(from) && !(start.Equal(end) && start.Equal(from)) {
+		return false
+	}
+
+	return to.IsZero() || start.Before(to)
+}
+
+// uid из адреса ресурса в calendar-multiget: адрес может быть абсолютным URL
+func hrefUID(href string) string {
+	if u, err := url.Parse(href); err == nil {
+		href = u.EscapedPath()
+	}
+
+	name := href[strings.LastIndex(href, "/")+1:]
+	uid, err := url.PathUnescape(strings.TrimSuffix(name, resourceSuffix))
+	if err != nil {
+		return ""
+	}
+
+	return uid
+}
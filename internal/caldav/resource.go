@@ -0,0 +1,238 @@
+package caldav
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/handler"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// максимальный размер ресурса в PUT
+const maxResourceSize = 1 << 20
+
+var errPreconditionFailed = apperror.PreconditionFailed("version_mismatch", "resource does not match If-Match or If-None-Match")
+
+func (h *Handler) GetResource(c *gin.Context) {
+	event, ok := h.resource(c)
+	if !ok {
+		return
+	}
+
+	// представление зависит от учетных данных читателя
+	data := calendarData(event)
+	tag := resourceETag(event, data)
+	c.Header("ETag", tag)
+	c.Header("Vary", "Authorization, X-API-Key")
+	if handler.NotModified(c, tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, ical.ContentType, data)
+}
+
+// PutResource создает или заменяет событие ресурсом iCalendar. Ресурс содержит одно
+// событие или серию с исключениями, его имя совпадает с UID. Клиент создает ресурс
+// с If-None-Match: *, а меняет с If-Match, чтобы не затереть чужие изменения.
+func (h *Handler) PutResource(c *gin.Context) {
+	userId, ok := userId(c)
+	if !ok {
+		return
+	}
+
+	uid, ok := resourceUID(c)
+	if !ok {
+		return
+	}
+
+	loc, err := h.service.Location(userId, "")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event, err := parseResource(c, uid, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	event.UserId = userId
+	for _, override := range event.Overrides {
+		override.UserId = userId
+	}
+
+	// nil, если ресурса еще нет
+	stored, err := h.findEvent(c, userId, uid)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	versions, ok := preconditions(c, stored)
+	if !ok {
+		return
+	}
+
+	if stored == nil {
+		h.createResource(c, event)
+	} else {
+		h.replaceResource(c, stored, event, versions)
+	}
+}
+
+func (h *Handler) DeleteResource(c *gin.Context) {
+	event, ok := h.resource(c)
+	if !ok {
+		return
+	}
+
+	versions, ok := preconditions(c, event)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteEvent(middleware.Actor(c, event.UserId), model.DeleteEvent{UserId: event.UserId, EventId: event.EventId, IfMatch: versions})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) createResource(c *gin.Context, event model.Event) {
+	if !handler.Validate(c, event) {
+		return
+	}
+
+	created, err := h.service.CreateEvent(middleware.Actor(c, event.UserId), event)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", resourceETag(&created, calendarData(&created)))
+	c.Status(http.StatusCreated)
+}
+
+// ресурс заменяет событие целиком, включая исключения серии. Начало в прошлом
+// допустимо, если оно не менялось.
+func (h *Handler) replaceResource(c *gin.Context, stored *model.Event, event model.Event, versions []int) {
+	moved := !time.Time(event.Start).Equal(time.Time(stored.Start))
+	if moved && !handler.Validate(c, event) || !moved && !handler.Validate(c, event, "Start") {
+		return
+	}
+	event.Normalize()
+
+	updateEvent := model.UpdateEvent{
+		EventId:      &stored.EventId,
+		UserId:       &stored.UserId,
+		Text:         &event.Text,
+		End:          &event.End,
+		AllDay:       &event.AllDay,
+		Recurrence:   &event.Recurrence,
+		TimeZone:     &event.TimeZone,
+		Transparency: &event.Transparency,
+		ExDates:      &event.ExDates,
+		Overrides:    &event.Overrides,
+		IfMatch:      versions,
+	}
+	if moved {
+		updateEvent.Start = &event.Start
+	}
+
+	updated, err := h.service.UpdateEvent(middleware.Actor(c, stored.UserId), updateEvent)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", resourceETag(&updated, calendarData(&updated)))
+	c.Status(http.StatusNoContent)
+}
+
+// тег ресурса зависит от тела: с ролью freebusy в нем остается только время
+func resourceETag(event *model.Event, data []byte) string {
+	return handler.RepresentationETag(*event, data)
+}
+
+// событие ресурса из адреса запроса
+func (h *Handler) resource(c *gin.Context) (*model.Event, bool) {
+	userId, ok := userId(c)
+	if !ok {
+		return nil, false
+	}
+
+	uid, ok := resourceUID(c)
+	if !ok {
+		return nil, false
+	}
+
+	event, err := h.findEvent(c, userId, uid)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if event == nil {
+		c.Error(errNoSuchResource)
+		return nil, false
+	}
+
+	return event, true
+}
+
+// parseResource разбирает тело PUT: одно событие, возможно с исключениями серии,
+// с UID из имени ресурса. Время без зоны трактуется в зоне пользователя.
+func parseResource(c *gin.Context, uid string, loc *time.Location) (model.Event, error) {
+	components, err := ical.Parse(http.MaxBytesReader(c.Writer, c.Request.Body, maxResourceSize), loc)
+	if err != nil {
+		return model.Event{}, apperror.Validation("invalid_calendar", err.Error())
+	}
+
+	var masters []ical.Component
+	for _, component := range components {
+		if component.Err != nil {
+			return model.Event{}, apperror.Validation("invalid_calendar", component.Err.Error())
+		}
+		if component.Master < 0 {
+			masters = append(masters, component)
+		}
+	}
+
+	if len(masters) != 1 || masters[0].Cancelled {
+		return model.Event{}, apperror.Validation("invalid_resource", "resource must contain exactly one event")
+	}
+
+	// UID задает имя ресурса, поэтому у события другого ресурса он не может совпасть (RFC 4791, 5.3.2)
+	master := masters[0]
+	if master.UID != "" && master.UID != uid {
+		return model.Event{}, apperror.Validation("uid_mismatch", "UID of the event must match the resource name")
+	}
+
+	event := master.Event
+	event.UID = uid
+	return event, nil
+}
+
+// preconditions проверяет If-None-Match: * и If-Match для ресурса event (nil -
+// ресурса нет) и возвращает версии для проверки при сохранении
+func preconditions(c *gin.Context, event *model.Event) ([]int, bool) {
+	if strings.TrimSpace(c.GetHeader("If-None-Match")) == "*" && event != nil {
+		c.Error(errPreconditionFailed)
+		return nil, false
+	}
+
+	// If-Match, даже *, не совпадает с ресурсом, которого нет
+	if c.GetHeader("If-Match") != "" && event == nil {
+		c.Error(errPreconditionFailed)
+		return nil, false
+	}
+
+	return handler.IfMatch(c)
+}
@@ -0,0 +1,229 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+
+	// максимальный размер тела PROPFIND и REPORT
+	maxRequestSize = 1 << 20
+
+	timeRangeLayout = "20060102T150405Z"
+)
+
+// префиксы пространств имен в ответах, объявляются в корне multistatus
+var prefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCS: "CS"}
+
+var (
+	propResourceType      = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName       = xml.Name{Space: nsDAV, Local: "displayname"}
+	propPrincipal         = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL      = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propPrivileges        = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propSupportedReports  = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propETag              = xml.Name{Space: nsDAV, Local: "getetag"}
+	propContentType       = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCalendarHome      = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propCalendarData      = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propSupportedCalendar = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCTag              = xml.Name{Space: nsCS, Local: "getctag"}
+
+	reportQuery    = xml.Name{Space: nsCalDAV, Local: "calendar-query"}
+	reportMultiget = xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}
+)
+
+// davRequest - тело PROPFIND или REPORT: запрошенные свойства, адреса ресурсов
+// calendar-multiget и time-range calendar-query
+type davRequest struct {
+	root     xml.Name
+	allProp  bool
+	props    []xml.Name
+	hrefs    []string
+	from, to time.Time
+}
+
+// readRequest разбирает тело запроса. Пустое тело PROPFIND означает allprop (RFC 4918, 9.1)
+func readRequest(c *gin.Context) (davRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestSize))
+	if err != nil {
+		return davRequest{}, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return davRequest{allProp: true}, nil
+	}
+
+	var (
+		req     davRequest
+		stack   []xml.Name
+		decoder = xml.NewDecoder(bytes.NewReader(body))
+	)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return davRequest{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			var parent xml.Name
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			switch {
+			case len(stack) == 0:
+				req.root = t.Name
+			case parent == xml.Name{Space: nsDAV, Local: "prop"} && len(stack) == 2:
+				req.props = append(req.props, t.Name)
+			case t.Name == xml.Name{Space: nsDAV, Local: "allprop"}:
+				req.allProp = true
+			case t.Name == xml.Name{Space: nsCalDAV, Local: "time-range"}:
+				for _, attr := range t.Attr {
+					value, err := time.Parse(timeRangeLayout, attr.Value)
+					if err != nil {
+						return davRequest{}, err
+					}
+					switch attr.Name.Local {
+					case "start":
+						req.from = value
+					case "end":
+						req.to = value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 2 && stack[1] == (xml.Name{Space: nsDAV, Local: "href"}) {
+				req.hrefs = append(req.hrefs, strings.TrimSpace(string(t)))
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// property - свойство ресурса, value - его содержимое в XML
+type property struct {
+	name  xml.Name
+	value string
+}
+
+// properties - свойства ресурса по порядку
+type properties []property
+
+func (p properties) get(name xml.Name) (property, bool) {
+	for _, prop := range p {
+		if prop.name == name {
+			return prop, true
+		}
+	}
+
+	return property{}, false
+}
+
+// multistatus собирает ответ 207 Multi-Status (RFC 4918, 13)
+type multistatus struct {
+	buf bytes.Buffer
+}
+
+func newMultistatus() *multistatus {
+	m := &multistatus{}
+	m.buf.WriteString(xml.Header)
+	m.buf.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + nsCalDAV + `" xmlns:CS="` + nsCS + `">`)
+	return m
+}
+
+// response добавляет ресурс с запрошенными свойствами: найденные - со статусом 200,
+// остальные - с 404. При allprop отдаются все свойства, кроме calendar-data.
+func (m *multistatus) response(href string, props properties, req davRequest) {
+	var found, missing []property
+	if req.allProp {
+		for _, prop := range props {
+			if prop.name != propCalendarData {
+				found = append(found, prop)
+			}
+		}
+	}
+	for _, name := range req.props {
+		if prop, ok := props.get(name); ok {
+			found = append(found, prop)
+		} else {
+			missing = append(missing, property{name: name})
+		}
+	}
+
+	m.buf.WriteString("<D:response><D:href>" + escape(href) + "</D:href>")
+	m.propstat(found, http.StatusOK)
+	m.propstat(missing, http.StatusNotFound)
+	m.buf.WriteString("</D:response>")
+}
+
+// notFound добавляет адрес, по которому ресурса нет
+func (m *multistatus) notFound(href string) {
+	m.buf.WriteString("<D:response><D:href>" + escape(href) + "</D:href>" + status(http.StatusNotFound) + "</D:response>")
+}
+
+func (m *multistatus) propstat(props []property, code int) {
+	if len(props) == 0 {
+		return
+	}
+
+	m.buf.WriteString("<D:propstat><D:prop>")
+	for _, prop := range props {
+		m.buf.WriteString(element(prop.name, prop.value))
+	}
+	m.buf.WriteString("</D:prop>" + status(code) + "</D:propstat>")
+}
+
+func (m *multistatus) send(c *gin.Context) {
+	m.buf.WriteString("</D:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", m.buf.Bytes())
+}
+
+// element - элемент с содержимым value. Для неизвестного пространства имен
+// оно объявляется на самом элементе.
+func element(name xml.Name, value string) string {
+	tag, attrs := name.Local, ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		attrs = ` xmlns="` + escape(name.Space) + `"`
+	}
+
+	if value == "" {
+		return "<" + tag + attrs + "/>"
+	}
+	return "<" + tag + attrs + ">" + value + "</" + tag + ">"
+}
+
+func status(code int) string {
+	return fmt.Sprintf("<D:status>HTTP/1.1 %d %s</D:status>", code, http.StatusText(code))
+}
+
+func href(path string) string {
+	return "<D:href>" + escape(path) + "</D:href>"
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
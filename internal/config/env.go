@@ -3,12 +3,28 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
 type Config struct {
-	Port string
+	Port          string
+	Storage       string
+	DataDir       string
+	SnapshotEvery int
+
+	// секрет подписи JWT и хеши API ключей в формате auth.ParseKeys
+	JWTSecret string
+	APIKeys   string
+
+	// явно выключает аутентификацию, без нее и без ключей сервис не запускается
+	AuthDisabled bool
 }
 
 var Envs = initConfig()
@@ -20,14 +36,48 @@ func initConfig() Config {
 	}
 
 	return Config{
-		Port: getEnv("PORT", ":8080"),
+		Port:          ":" + getEnv("PORT", "8080"),
+		Storage:       getEnv("STORAGE", StorageMemory),
+		DataDir:       getEnv("DATA_DIR", "data"),
+		SnapshotEvery: getEnvInt("SNAPSHOT_EVERY", 1000),
+		JWTSecret:     getEnv("JWT_SECRET", ""),
+		APIKeys:       getEnv("API_KEYS", ""),
+		AuthDisabled:  getEnvBool("AUTH_DISABLED", false),
 	}
 }
 
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
-		return ":" + value
+		return value
 	}
 
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid value for %s: %s", key, value)
+	}
+
+	return i
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("invalid value for %s: %s", key, value)
+	}
+
+	return b
+}
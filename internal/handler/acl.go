@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Обработчики доступов к календарю /v2/users/:user_id/calendars/:calendar_id/acl
+
+func (h *Handler) ListACLV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.service.GetACL(middleware.Actor(c, userId), userId, calendarId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string][]model.ACLEntry{"result": entries})
+}
+
+// PutACLV2 выдает пользователю grantee_id роль из тела запроса или меняет выданную
+func (h *Handler) PutACLV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	grantee, ok := pathId(c, "grantee_id")
+	if !ok {
+		return
+	}
+
+	var entry model.ACLEntry
+	if !bindJSON(c, &entry) {
+		return
+	}
+	entry.CalendarId, entry.UserId = calendarId, grantee
+
+	if !Validate(c, entry) {
+		return
+	}
+
+	entry, err := h.service.PutACL(middleware.Actor(c, userId), userId, entry)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.ACLEntry{"result": entry})
+}
+
+func (h *Handler) DeleteACLV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	grantee, ok := pathId(c, "grantee_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteACL(middleware.Actor(c, userId), userId, calendarId, grantee); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACLV2(t *testing.T) {
+	t.Run("List", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetACL", 7, 7, 3).Return([]model.ACLEntry{
+			{CalendarId: 3, UserId: 8, Role: model.RoleViewer},
+		}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/7/calendars/3/acl", nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"result":[{"calendar_id":3,"user_id":8,"role":"viewer"}]}`, w.Body.String())
+	})
+
+	t.Run("Put", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		entry := model.ACLEntry{CalendarId: 3, UserId: 8, Role: model.RoleFreeBusy}
+		mockService.On("PutACL", 7, 7, entry).Return(entry, nil)
+
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/7/calendars/3/acl/8", bytes.NewBufferString(`{"role":"freebusy"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/7/calendars/3/acl/8", bytes.NewBufferString(`{"role":"admin"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(new(MockEventsService))).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"role"`)
+	})
+
+	t.Run("Delete by another user", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("DeleteACL", 8, 7, 3, 8).Return(service.ErrAccessDenied)
+
+		req, _ := http.NewRequest(http.MethodDelete, "/v2/users/7/calendars/3/acl/8", nil)
+		req.Header.Set("X-Test-User", "8")
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"access_denied"`)
+	})
+
+	t.Run("Calendar of another user under own path", func(t *testing.T) {
+		s := service.New(repository.New())
+		for _, userId := range []int{1, 2} {
+			_, err := s.CreateUser(userId, model.User{UserId: userId, Name: "User"})
+			require.NoError(t, err)
+		}
+		victim, err := s.CreateCalendar(1, model.Calendar{UserId: 1, Name: "Personal"})
+		require.NoError(t, err)
+
+		path := fmt.Sprintf("/v2/users/2/calendars/%d/acl", victim.CalendarId)
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPut, path+"/2", bytes.NewBufferString(`{"role":"owner"}`)),
+			httptest.NewRequest(http.MethodGet, path, nil),
+			httptest.NewRequest(http.MethodDelete, path+"/2", nil),
+		} {
+			req.Header.Set("X-Test-User", "2")
+			w := httptest.NewRecorder()
+			setupV2Router(New(s)).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code, req.Method)
+			assert.Contains(t, w.Body.String(), `"code":"calendar_not_found"`, req.Method)
+		}
+
+		_, err = s.GetCalendar(2, 1, victim.CalendarId)
+		assert.Equal(t, service.ErrAccessDenied, err)
+	})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	t.Run("Actor from identity", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetCalendars", 8, 7).Return([]model.Calendar{}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/7/calendars", nil)
+		req.Header.Set("X-Test-User", "8")
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Admin acts on behalf of user", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetCalendars", 7, 7).Return([]model.Calendar{}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/7/calendars", nil)
+		req.Header.Set("X-Test-User", "1")
+		req.Header.Set("X-Test-Scope", auth.ScopeAdmin)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Legacy routes without user_id", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetUserSettings", 8, 8).Return(model.UserSettings{UserId: 8, TimeZone: "UTC"}, nil)
+		mockService.On("CreateEvent", 8, mock.MatchedBy(func(e model.Event) bool {
+			return e.UserId == 8
+		})).Return(model.Event{EventId: 1, UserId: 8}, nil)
+
+		router := setupRouter(New(mockService))
+
+		req, _ := http.NewRequest(http.MethodGet, "/settings", nil)
+		req.Header.Set("X-Test-User", "8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		start := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+		req, _ = http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(`{"text":"Meeting","start":"`+start+`"}`))
+		req.Header.Set("X-Test-User", "8")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := auth.New("test-secret", map[string]auth.Identity{auth.HashKey("key-8"): {UserId: 8}})
+
+	mockService := new(MockEventsService)
+	mockService.On("GetUserSettings", 8, 8).Return(model.UserSettings{UserId: 8, TimeZone: "UTC"}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(middleware.Authenticate(authenticator))
+	router.GET("/user_settings", New(mockService).GetUserSettings)
+
+	get := func(headers ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/user_settings", nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := authenticator.Token(auth.Identity{UserId: 8}, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get("Authorization", "Bearer "+token).Code)
+	assert.Equal(t, http.StatusOK, get("X-API-Key", "key-8").Code)
+
+	w := get()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"missing_credentials"`)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	w = get("X-API-Key", "key-9")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_credentials"`)
+}
+
+func TestAuthenticateUnconfigured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(middleware.Authenticate(auth.New("", nil)))
+	router.GET("/user_settings", New(new(MockEventsService)).GetUserSettings)
+
+	// без ключей и секрета запрос с чужим user_id не проходит
+	req, _ := http.NewRequest(http.MethodGet, "/user_settings?user_id=8", nil)
+	req.Header.Set("X-API-Key", "any-key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_credentials"`)
+}
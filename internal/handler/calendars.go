@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Обработчики календарей пользователя /v2/users/:user_id/calendars
+
+func (h *Handler) ListCalendarsV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	calendars, err := h.service.GetCalendars(middleware.Actor(c, userId), userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string][]model.Calendar{"result": calendars})
+}
+
+// CreateCalendarV2 создает календарь и возвращает 201 с адресом нового ресурса в Location
+func (h *Handler) CreateCalendarV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var calendar model.Calendar
+	if !bindJSON(c, &calendar) {
+		return
+	}
+	calendar.UserId = userId
+
+	if !Validate(c, calendar) {
+		return
+	}
+
+	calendar, err := h.service.CreateCalendar(middleware.Actor(c, userId), calendar)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", calendarLocation(calendar.UserId, calendar.CalendarId))
+	c.JSON(http.StatusCreated, map[string]model.Calendar{"result": calendar})
+}
+
+func (h *Handler) GetCalendarV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	calendar, err := h.service.GetCalendar(middleware.Actor(c, userId), userId, calendarId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.Calendar{"result": calendar})
+}
+
+// ReplaceCalendarV2 заменяет название, цвет, зону и описание календаря
+func (h *Handler) ReplaceCalendarV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	var calendar model.Calendar
+	if !bindJSON(c, &calendar) {
+		return
+	}
+	calendar.UserId, calendar.CalendarId = userId, calendarId
+
+	if !Validate(c, calendar) {
+		return
+	}
+
+	calendar, err := h.service.UpdateCalendar(middleware.Actor(c, userId), calendar)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.Calendar{"result": calendar})
+}
+
+// DeleteCalendarV2 удаляет календарь вместе с его событиями. Календарь по умолчанию удалить нельзя
+func (h *Handler) DeleteCalendarV2(c *gin.Context) {
+	userId, calendarId, ok := calendarIds(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteCalendar(middleware.Actor(c, userId), userId, calendarId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// inCalendars оставляет события календарей из списка ids, пустой список - все
+// календари пользователя. Каждый календарь из списка должен принадлежать пользователю.
+func (h *Handler) inCalendars(c *gin.Context, userId int, ids []int, events []*model.Event) ([]*model.Event, error) {
+	if len(ids) == 0 {
+		return events, nil
+	}
+
+	calendars, err := h.service.GetCalendars(middleware.Actor(c, userId), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !slices.ContainsFunc(calendars, func(calendar model.Calendar) bool { return calendar.CalendarId == id }) {
+			return nil, apperror.NotFound("calendar_not_found", fmt.Sprintf("no such calendar %d", id))
+		}
+		selected[id] = true
+	}
+
+	var result []*model.Event
+	for _, event := range events {
+		if selected[event.CalendarId] {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+// id календарей из query параметра calendar_id: через запятую или несколькими параметрами
+func queryCalendars(c *gin.Context) ([]int, error) {
+	var ids []int
+	for _, value := range c.QueryArray("calendar_id") {
+		for _, part := range strings.Split(value, ",") {
+			id, err := parseId(strings.TrimSpace(part))
+			if err != nil {
+				return nil, apperror.Validation("invalid_calendar_id", "invalid calendar_id")
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func calendarIds(c *gin.Context) (int, int, bool) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	calendarId, ok := pathId(c, "calendar_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	return userId, calendarId, true
+}
+
+func calendarLocation(userId, calendarId int) string {
+	return fmt.Sprintf("/v2/users/%d/calendars/%d", userId, calendarId)
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarsV2(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("CreateCalendar", mock.Anything, mock.MatchedBy(func(c model.Calendar) bool {
+			return c.UserId == 7 && c.Name == "Work" && c.Color == "#3366ff"
+		})).Return(model.Calendar{CalendarId: 3, UserId: 7, Name: "Work", Color: "#3366ff"}, nil)
+
+		body := `{"name":"Work","color":"#3366ff","time_zone":"Europe/Moscow"}`
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users/7/calendars", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/v2/users/7/calendars/3", w.Header().Get("Location"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		body := `{"color":"red","time_zone":"Mars/Olympus"}`
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users/7/calendars", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(new(MockEventsService))).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"name"`)
+		assert.Contains(t, w.Body.String(), `"field":"color"`)
+		assert.Contains(t, w.Body.String(), `"field":"time_zone"`)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("UpdateCalendar", mock.Anything, model.Calendar{CalendarId: 3, UserId: 7, Name: "Office"}).
+			Return(model.Calendar{CalendarId: 3, UserId: 7, Name: "Office"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/7/calendars/3", bytes.NewBufferString(`{"name":"Office"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Delete default", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("DeleteCalendar", 7, 7, 1).Return(repository.ErrDefaultCalendar)
+
+		req, _ := http.NewRequest(http.MethodDelete, "/v2/users/7/calendars/1", nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"default_calendar"`)
+	})
+}
+
+func TestEventsInCalendars(t *testing.T) {
+	from := time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	mockService := new(MockEventsService)
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetCalendars", 1, 1).Return([]model.Calendar{
+		{CalendarId: 1, UserId: 1, Name: "Default", Default: true},
+		{CalendarId: 2, UserId: 1, Name: "Work"},
+		{CalendarId: 3, UserId: 1, Name: "On-call"},
+	}, nil)
+	mockService.On("GetEventsInRange", 1, 1, from, to).Return([]*model.Event{
+		{EventId: 1, UserId: 1, CalendarId: 1, Text: "Birthday"},
+		{EventId: 2, UserId: 1, CalendarId: 1, Text: "Dentist"},
+		{EventId: 3, UserId: 1, CalendarId: 2, Text: "Standup"},
+		{EventId: 4, UserId: 1, CalendarId: 3, Text: "Pager"},
+	}, nil)
+
+	list := func(query string) (int, []string) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events?from=2025-08-18&to=2025-08-25"+query, nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		var response struct {
+			Result []model.Event `json:"result"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		var texts []string
+		for _, event := range response.Result {
+			texts = append(texts, event.Text)
+		}
+		return w.Code, texts
+	}
+
+	t.Run("All calendars", func(t *testing.T) {
+		code, texts := list("")
+		require.Equal(t, http.StatusOK, code)
+		assert.Len(t, texts, 4)
+	})
+
+	t.Run("One calendar", func(t *testing.T) {
+		_, texts := list("&calendar_id=2")
+		assert.Equal(t, []string{"Standup"}, texts)
+	})
+
+	t.Run("Several calendars", func(t *testing.T) {
+		_, texts := list("&calendar_id=1,3")
+		assert.Equal(t, []string{"Birthday", "Dentist", "Pager"}, texts)
+
+		_, repeated := list("&calendar_id=1&calendar_id=3")
+		assert.Equal(t, texts, repeated)
+	})
+
+	t.Run("Unknown calendar", func(t *testing.T) {
+		code, _ := list("&calendar_id=9")
+		assert.Equal(t, http.StatusNotFound, code)
+
+		code, _ = list("&calendar_id=abc")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
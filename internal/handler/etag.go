@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+var errVersionMismatch = apperror.PreconditionFailed("version_mismatch", "If-Match does not match any version of the event")
+
+// ETag события - его версия в кавычках, например "3". Тег сильный: If-Match
+// сравнивает только сильные теги (RFC 9110, 13.1.1).
+func ETag(event model.Event) string {
+	return `"` + strconv.Itoa(event.Version) + `"`
+}
+
+func setETag(c *gin.Context, event model.Event) {
+	c.Header("ETag", ETag(event))
+}
+
+// RepresentationETag - тег ответа на чтение события (JSON или ресурса CalDAV): версия и хеш тела, например
+// "3-1a2b3c4d". Тело одной версии зависит от зоны tz и от роли читателя (с ролью
+// freebusy остается только время), поэтому у разных представлений разные теги.
+// If-Match по такому тегу сравнивает только версию.
+func RepresentationETag(event model.Event, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.Itoa(event.Version) + "-" + hex.EncodeToString(sum[:4]) + `"`
+}
+
+// IfMatch разбирает заголовок If-Match в список версий. Без заголовка и для "*"
+// список пуст, версия не проверяется. Если ни один тег не может совпасть
+// (слабые или чужие теги), запрос сразу отклоняется с 412.
+func IfMatch(c *gin.Context) ([]int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		if version, weak, ok := parseETag(tag); ok && !weak {
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		c.Error(errVersionMismatch)
+		return nil, false
+	}
+
+	return versions, true
+}
+
+// NotModified сообщает, совпадает ли тег представления с If-None-Match. Для
+// If-None-Match теги сравниваются слабо: префикс W/ не учитывается.
+func NotModified(c *gin.Context, tag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseETag(tag string) (version int, weak bool, ok bool) {
+	tag = strings.TrimSpace(tag)
+	if rest, found := strings.CutPrefix(tag, "W/"); found {
+		tag, weak = rest, true
+	}
+
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false, false
+	}
+
+	// у тега представления после версии идет хеш тела
+	value, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, false, false
+	}
+
+	return version, weak, true
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestETags(t *testing.T) {
+	start := model.Date(time.Now().Add(48 * time.Hour))
+	stored := model.Event{EventId: 42, UserId: 1, Text: "Meeting", Start: start, End: start, Version: 3}
+
+	send := func(mockService *MockEventsService, method, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/v2/users/1/events/42", bytes.NewBufferString(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+		return w
+	}
+
+	get := func(tz string, event model.Event, headers map[string]string) *httptest.ResponseRecorder {
+		loc, _ := time.LoadLocation(tz)
+		mockService := new(MockEventsService)
+		mockService.On("Location", 1, tz).Return(loc, nil)
+		mockService.On("GetEvent", 1, 1, 42, loc).Return(event.In(loc), nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events/42?tz="+tz, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Read exposes ETag", func(t *testing.T) {
+		w := get("UTC", stored, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasPrefix(w.Header().Get("ETag"), `"3-`), w.Header().Get("ETag"))
+		assert.Equal(t, w.Header().Get("ETag"), get("UTC", stored, nil).Header().Get("ETag"))
+	})
+
+	t.Run("If-None-Match", func(t *testing.T) {
+		tag := get("UTC", stored, nil).Header().Get("ETag")
+		tests := []struct {
+			header string
+			status int
+		}{
+			{tag, http.StatusNotModified},
+			{"W/" + tag, http.StatusNotModified},
+			{`"1", ` + tag, http.StatusNotModified},
+			{`*`, http.StatusNotModified},
+			{`"3"`, http.StatusOK},
+			{`"2"`, http.StatusOK},
+			{`3`, http.StatusOK},
+		}
+
+		for _, tt := range tests {
+			w := get("UTC", stored, map[string]string{"If-None-Match": tt.header})
+
+			assert.Equal(t, tt.status, w.Code, tt.header)
+			assert.Equal(t, tag, w.Header().Get("ETag"), tt.header)
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String(), tt.header)
+			}
+		}
+	})
+
+	t.Run("Representation depends on time zone and role", func(t *testing.T) {
+		tag := get("UTC", stored, nil).Header().Get("ETag")
+
+		// та же версия в другой зоне - другое представление
+		w := get("Asia/Tokyo", stored, map[string]string{"If-None-Match": tag})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, tag, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), "+09:00")
+
+		w = get("UTC", stored.FreeBusy(), map[string]string{"If-None-Match": tag})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, tag, w.Header().Get("ETag"))
+		assert.NotEmpty(t, w.Header().Get("Vary"))
+	})
+
+	t.Run("If-Match is passed to the update", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return assert.ObjectsAreEqual([]int{2, 3}, u.IfMatch)
+		})).Return(model.Event{EventId: 42, UserId: 1, Text: "Planning", Version: 4}, nil)
+
+		w := send(mockService, http.MethodPatch, `{"text": "Planning"}`, map[string]string{"If-Match": `"2", W/"5", "3-1a2b3c4d"`})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Version mismatch is 412", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 42, IfMatch: []int{2}}).Return(repository.ErrVersionMismatch)
+
+		w := send(mockService, http.MethodDelete, "", map[string]string{"If-Match": `"2"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"version_mismatch"`)
+	})
+
+	t.Run("Weak tags never match If-Match", func(t *testing.T) {
+		mockService := new(MockEventsService)
+
+		w := send(mockService, http.MethodDelete, "", map[string]string{"If-Match": `W/"3"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertNotCalled(t, "DeleteEvent", mock.Anything)
+	})
+
+	t.Run("Patch document is applied to the version it was computed from", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(stored, nil)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return assert.ObjectsAreEqual([]int{3}, u.IfMatch)
+		})).Return(model.Event{}, repository.ErrVersionMismatch)
+
+		w := send(mockService, http.MethodPatch, `{"text": "Planning"}`, map[string]string{"Content-Type": mergePatchType})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Patch document with stale If-Match", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(stored, nil)
+
+		w := send(mockService, http.MethodPatch, `{"text": "Planning"}`, map[string]string{"Content-Type": mergePatchType, "If-Match": `"2"`})
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockService.AssertNotCalled(t, "UpdateEvent", mock.Anything)
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// FreeBusy возвращает занятость пользователей user_ids в периоде [from, to) без текста
+// событий. Времена возвращаются в зоне time_zone запроса. Недоступный или неизвестный
+// пользователь не ломает запрос: для него возвращается код ошибки без интервалов.
+func (h *Handler) FreeBusy(c *gin.Context) {
+	var query model.FreeBusyQuery
+	if !bindJSON(c, &query) {
+		return
+	}
+
+	if !Validate(c, query) {
+		return
+	}
+
+	loc, err := time.LoadLocation(query.TimeZone)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_time_zone", "unknown time zone"))
+		return
+	}
+
+	from, to, ok := parseRange(c, query.From, query.To, loc)
+	if !ok {
+		return
+	}
+
+	result := make([]model.FreeBusy, 0, len(query.UserIds))
+	for _, userId := range query.UserIds {
+		busy, err := h.service.GetFreeBusy(middleware.Actor(c, userId), userId, from.In(loc), to.In(loc))
+		if err != nil {
+			appErr := apperror.From(err)
+			if appErr.Kind != apperror.KindForbidden && appErr.Kind != apperror.KindNotFound {
+				c.Error(err)
+				return
+			}
+
+			result = append(result, model.FreeBusy{UserId: userId, Busy: []model.Interval{}, Error: appErr.Code})
+			continue
+		}
+
+		result = append(result, model.FreeBusy{UserId: userId, Busy: busy})
+	}
+
+	c.JSON(http.StatusOK, map[string][]model.FreeBusy{"result": result})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFreeBusy(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		moscow, _ := time.LoadLocation("Europe/Moscow")
+		from := time.Date(2024, 1, 15, 0, 0, 0, 0, moscow)
+		to := time.Date(2024, 1, 16, 0, 0, 0, 0, moscow)
+		busy := []model.Interval{{Start: model.Date(from.Add(9 * time.Hour)), End: model.Date(from.Add(10 * time.Hour))}}
+
+		mockService := new(MockEventsService)
+		mockService.On("GetFreeBusy", 1, 1, mock.MatchedBy(from.Equal), mock.MatchedBy(to.Equal)).Return(busy, nil)
+		mockService.On("GetFreeBusy", 2, 2, mock.MatchedBy(from.Equal), mock.MatchedBy(to.Equal)).Return([]model.Interval{}, nil)
+
+		body := `{"user_ids":[1,2],"from":"2024-01-15","to":"2024-01-16","time_zone":"Europe/Moscow"}`
+		req, _ := http.NewRequest(http.MethodPost, "/freebusy", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupRouter(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"result":[
+			{"user_id":1,"busy":[{"start":"2024-01-15T09:00:00+03:00","end":"2024-01-15T10:00:00+03:00"}]},
+			{"user_id":2,"busy":[]}
+		]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("As authenticated user", func(t *testing.T) {
+		// недоступные пользователи не ломают запрос для остальных
+		mockService := new(MockEventsService)
+		mockService.On("GetFreeBusy", 5, 5, mock.Anything, mock.Anything).Return([]model.Interval{}, nil)
+		mockService.On("GetFreeBusy", 5, 1, mock.Anything, mock.Anything).Return([]model.Interval(nil), service.ErrAccessDenied)
+		mockService.On("GetFreeBusy", 5, 9, mock.Anything, mock.Anything).Return([]model.Interval(nil), repository.ErrNoSuchUser)
+
+		body := `{"user_ids":[5,1,9],"from":"2024-01-15T09:00:00Z","to":"2024-01-15T18:00:00Z"}`
+		req, _ := http.NewRequest(http.MethodPost, "/freebusy", bytes.NewBufferString(body))
+		req.Header.Set("X-Test-User", "5")
+		w := httptest.NewRecorder()
+		setupRouter(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"result":[
+			{"user_id":5,"busy":[]},
+			{"user_id":1,"busy":[],"error":"access_denied"},
+			{"user_id":9,"busy":[],"error":"user_not_found"}
+		]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Internal error", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetFreeBusy", 1, 1, mock.Anything, mock.Anything).Return([]model.Interval(nil), errors.New("disk failure"))
+
+		body := `{"user_ids":[1],"from":"2024-01-15T09:00:00Z","to":"2024-01-15T18:00:00Z"}`
+		req, _ := http.NewRequest(http.MethodPost, "/freebusy", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupRouter(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for body, code := range map[string]string{
+			`{"user_ids":[],"from":"2024-01-15","to":"2024-01-16"}`:    "validation_failed",
+			`{"user_ids":[1,1],"from":"2024-01-15","to":"2024-01-16"}`: "validation_failed",
+			`{"user_ids":[1],"from":"yesterday","to":"2024-01-16"}`:    "invalid_from",
+			`{"user_ids":[1],"from":"2024-01-16","to":"2024-01-15"}`:   "invalid_range",
+			`{"user_ids":[1],"from":"2024-01-01","to":"2025-06-01"}`:   "range_too_long",
+		} {
+			req, _ := http.NewRequest(http.MethodPost, "/freebusy", bytes.NewBufferString(body))
+			w := httptest.NewRecorder()
+			setupRouter(New(new(MockEventsService))).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), `"code":"`+code+`"`, body)
+		}
+	})
+}
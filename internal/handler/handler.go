@@ -4,23 +4,57 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
 	"github.com/Komilov31/calendar-service/internal/model"
-	"github.com/Komilov31/calendar-service/internal/repository"
 	"github.com/Komilov31/calendar-service/internal/validator"
 	"github.com/gin-gonic/gin"
 )
 
+// EventsService выполняет запросы от имени пользователя - первого аргумента методов
+// (см. middleware.Actor) - и проверяет его права на календари владельца данных
 type EventsService interface {
-	CreateEvent(model.Event) model.Event
-	UpdateEvent(model.UpdateEvent) (model.Event, error)
-	DeleteEvent(int, int) error
-	GetEventsForDay(int, time.Time) ([]*model.Event, error)
-	GetEventsForWeek(int, time.Time) ([]*model.Event, error)
-	GetEventsForMonth(int, time.Time) ([]*model.Event, error)
+	CreateEvent(int, model.Event) (model.Event, error)
+	CanCreateEvent(int, model.Event) error
+	UpdateEvent(int, model.UpdateEvent) (model.Event, error)
+	DeleteEvent(int, model.DeleteEvent) error
+	GetEvent(int, int, int, *time.Location) (model.Event, error)
+	GetEvents(int, int) ([]*model.Event, error)
+	GetEventsForDay(int, int, time.Time) ([]*model.Event, error)
+	GetEventsForWeek(int, int, time.Time) ([]*model.Event, error)
+	GetEventsForISOWeek(int, int, time.Time) ([]*model.Event, error)
+	GetEventsForMonth(int, int, time.Time) ([]*model.Event, error)
+	GetEventsForQuarter(int, int, time.Time) ([]*model.Event, error)
+	GetEventsForYear(int, int, time.Time) ([]*model.Event, error)
+	GetEventsInRange(int, int, time.Time, time.Time) ([]*model.Event, error)
+	UpdateUserSettings(int, model.UserSettings) (model.UserSettings, error)
+	GetUserSettings(int, int) (model.UserSettings, error)
+	CreateCalendar(int, model.Calendar) (model.Calendar, error)
+	UpdateCalendar(int, model.Calendar) (model.Calendar, error)
+	DeleteCalendar(int, int, int) error
+	GetCalendar(int, int, int) (model.Calendar, error)
+	GetCalendars(int, int) ([]model.Calendar, error)
+	GetACL(int, int, int) ([]model.ACLEntry, error)
+	PutACL(int, int, model.ACLEntry) (model.ACLEntry, error)
+	DeleteACL(int, int, int, int) error
+	CreateUser(int, model.User) (model.User, error)
+	UpdateUser(int, model.User) (model.User, error)
+	GetUser(int, int) (model.User, error)
+	DeleteUser(int, int, bool) error
+	GetInvitations(int, int) ([]*model.Event, error)
+	GetFreeBusy(int, int, time.Time, time.Time) ([]model.Interval, error)
+	Respond(int, int, int, string) (model.Event, error)
+	Location(int, string) (*time.Location, error)
 }
 
+// максимальная длина периода в запросе /events
+const maxRangeSpan = 366 * 24 * time.Hour
+
+var errInvalidId = errors.New("id must be a positive integer")
+
 type Handler struct {
 	service EventsService
 }
@@ -33,174 +67,403 @@ func New(eventsService EventsService) *Handler {
 
 func (h *Handler) CreateEvent(c *gin.Context) {
 	var event model.Event
+	if !bindJSON(c, &event) {
+		return
+	}
 
-	if err := c.BindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if identity, ok := middleware.CurrentIdentity(c); ok && event.UserId == 0 {
+		event.UserId = identity.UserId
+	}
+
+	if !Validate(c, event) {
 		return
 	}
 
-	if err := validator.Validate.Struct(event); err != nil {
-		errMsg := validator.CreateValidationErrorResponse(err)
-		c.JSON(http.StatusBadRequest, map[string]string{"error": errMsg})
+	event, err := h.service.CreateEvent(middleware.Actor(c, event.UserId), event)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	event = h.service.CreateEvent(event)
+	setETag(c, event)
 	c.JSON(http.StatusOK, map[string]model.Event{"result": event})
 }
 
 func (h *Handler) UpdateEvent(c *gin.Context) {
-	var updateEvent model.UpdateEvent
+	userId, ok := queryUser(c)
+	if !ok {
+		return
+	}
 
-	id := c.Query("user_id")
-	userId, err := strconv.Atoi(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user_id or was not provided"})
+	eventId, ok := queryId(c, "event_id")
+	if !ok {
+		return
+	}
+
+	updateEvent, ok := bindUpdate(c, userId, eventId)
+	if !ok {
 		return
 	}
 
-	e := c.Query("event_id")
-	event_id, err := strconv.Atoi(e)
+	event, err := h.service.UpdateEvent(middleware.Actor(c, userId), updateEvent)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid event_id or was not provided"})
+		c.Error(err)
 		return
 	}
 
-	updateEvent.EventId = &event_id
-	updateEvent.UserId = &userId
+	setETag(c, event)
+	c.JSON(http.StatusOK, map[string]model.Event{"result": event})
+}
 
-	if err := c.BindJSON(&updateEvent); err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+func (h *Handler) DeleteEvent(c *gin.Context) {
+	userId, ok := queryUser(c)
+	if !ok {
 		return
 	}
 
-	if err := validator.Validate.Struct(updateEvent); err != nil {
-		errMsg := validator.CreateValidationErrorResponse(err)
-		c.JSON(http.StatusBadRequest, map[string]string{"error": errMsg})
+	eventId, ok := queryId(c, "event_id")
+	if !ok {
 		return
 	}
 
-	event, err := h.service.UpdateEvent(updateEvent)
-	if err != nil {
-		if errors.Is(err, repository.ErrNoSuchEvent) || errors.Is(err, repository.ErrNoSuchUser) {
-			c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	deleteEvent, ok := deleteRequest(c, userId, eventId)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]model.Event{"result": event})
+	if err := h.service.DeleteEvent(middleware.Actor(c, userId), deleteEvent); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"result": "sucessfully deleted event"})
 }
 
-func (h *Handler) DeleteEvent(c *gin.Context) {
-	id := c.Query("user_id")
-	userId, err := strconv.Atoi(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user_id or was not provided"})
+func (h *Handler) GetEventsForDay(c *gin.Context) {
+	if userId, ok := queryUser(c); ok {
+		h.listForDate(c, userId, h.service.GetEventsForDay)
+	}
+}
+
+func (h *Handler) GetEventsForWeek(c *gin.Context) {
+	if userId, ok := queryUser(c); ok {
+		h.listForDate(c, userId, h.service.GetEventsForWeek)
+	}
+}
+
+func (h *Handler) GetEventsForMonth(c *gin.Context) {
+	if userId, ok := queryUser(c); ok {
+		h.listForDate(c, userId, h.service.GetEventsForMonth)
+	}
+}
+
+// GetEventsInRange возвращает события за произвольный период [from, to)
+func (h *Handler) GetEventsInRange(c *gin.Context) {
+	if userId, ok := queryUser(c); ok {
+		h.listInRange(c, userId)
+	}
+}
+
+func (h *Handler) UpdateUserSettings(c *gin.Context) {
+	var settings model.UserSettings
+
+	userId, ok := queryUser(c)
+	if !ok {
+		return
+	}
+
+	if !bindJSON(c, &settings) {
 		return
 	}
+	settings.UserId = userId
 
-	e := c.Query("event_id")
-	event_id, err := strconv.Atoi(e)
+	if !Validate(c, settings) {
+		return
+	}
+
+	settings, err := h.service.UpdateUserSettings(middleware.Actor(c, userId), settings)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid event_id or was not provided"})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.UserSettings{"result": settings})
+}
+
+func (h *Handler) GetUserSettings(c *gin.Context) {
+	userId, ok := queryUser(c)
+	if !ok {
 		return
 	}
 
-	err = h.service.DeleteEvent(userId, event_id)
+	settings, err := h.service.GetUserSettings(middleware.Actor(c, userId), userId)
 	if err != nil {
-		if errors.Is(err, repository.ErrNoSuchEvent) || errors.Is(err, repository.ErrNoSuchUser) {
-			c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]string{"result": "sucessfully deleted event"})
+	c.JSON(http.StatusOK, map[string]model.UserSettings{"result": settings})
 }
 
-func (h *Handler) GetEventsForDay(c *gin.Context) {
-	id := c.Query("user_id")
-	userId, err := strconv.Atoi(id)
+// выборка за день, неделю или месяц, в который попадает query параметр date
+func (h *Handler) listForDate(c *gin.Context, userId int, get func(int, int, time.Time) ([]*model.Event, error)) {
+	opts, err := parseListOptions(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user_id or was not provided"})
+		c.Error(err)
+		return
+	}
+
+	date, ok := h.queryDate(c, userId)
+	if !ok {
 		return
 	}
 
-	d := c.Query("date")
-	date, err := time.Parse(time.DateOnly, d)
+	events, err := get(middleware.Actor(c, userId), userId, date)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date format"})
+		c.Error(err)
 		return
 	}
 
-	events, err := h.service.GetEventsForDay(userId, date)
+	events, err = h.inCalendars(c, userId, opts.calendars, events)
 	if err != nil {
-		if errors.Is(err, repository.ErrNoSuchEvent) || errors.Is(err, repository.ErrNoSuchUser) {
-			c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string][]*model.Event{"result": events})
+	respondEvents(c, opts, events)
 }
 
-func (h *Handler) GetEventsForWeek(c *gin.Context) {
-	id := c.Query("user_id")
-	userId, err := strconv.Atoi(id)
+// выборка за период из query параметров from и to
+func (h *Handler) listInRange(c *gin.Context, userId int) {
+	opts, err := parseListOptions(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user_id or was not provided"})
+		c.Error(err)
+		return
+	}
+
+	loc, ok := h.queryLocation(c, userId)
+	if !ok {
 		return
 	}
 
-	d := c.Query("date")
-	date, err := time.Parse(time.DateOnly, d)
+	from, to, ok := parseRange(c, c.Query("from"), c.Query("to"), loc)
+	if !ok {
+		return
+	}
+
+	events, err := h.service.GetEventsInRange(middleware.Actor(c, userId), userId, from.In(loc), to.In(loc))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date format"})
+		c.Error(err)
 		return
 	}
 
-	events, err := h.service.GetEventsForWeek(userId, date)
+	events, err = h.inCalendars(c, userId, opts.calendars, events)
 	if err != nil {
-		if errors.Is(err, repository.ErrNoSuchEvent) || errors.Is(err, repository.ErrNoSuchUser) {
-			c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string][]*model.Event{"result": events})
+	respondEvents(c, opts, events)
 }
 
-func (h *Handler) GetEventsForMonth(c *gin.Context) {
-	id := c.Query("user_id")
-	userId, err := strconv.Atoi(id)
+// изменение события: экземпляр и область берутся из query, ожидаемые версии -
+// из If-Match, поля - из тела запроса
+func bindUpdate(c *gin.Context, userId, eventId int) (model.UpdateEvent, bool) {
+	occurrence, scope, ok := queryOccurrence(c)
+	if !ok {
+		return model.UpdateEvent{}, false
+	}
+
+	versions, ok := IfMatch(c)
+	if !ok {
+		return model.UpdateEvent{}, false
+	}
+
+	updateEvent := model.UpdateEvent{
+		EventId:    &eventId,
+		UserId:     &userId,
+		Occurrence: occurrence,
+		Scope:      scope,
+		IfMatch:    versions,
+	}
+
+	if !bindJSON(c, &updateEvent) || !Validate(c, updateEvent) {
+		return model.UpdateEvent{}, false
+	}
+
+	return updateEvent, true
+}
+
+func deleteRequest(c *gin.Context, userId, eventId int) (model.DeleteEvent, bool) {
+	occurrence, scope, ok := queryOccurrence(c)
+	if !ok {
+		return model.DeleteEvent{}, false
+	}
+
+	versions, ok := IfMatch(c)
+	if !ok {
+		return model.DeleteEvent{}, false
+	}
+
+	return model.DeleteEvent{
+		UserId:     userId,
+		EventId:    eventId,
+		Occurrence: occurrence,
+		Scope:      scope,
+		IfMatch:    versions,
+	}, true
+}
+
+func bindJSON(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.Error(apperror.Validation("invalid_json", err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// Validate проверяет obj, кроме полей except (имена полей структуры). Ошибки
+// отдаются через c.Error со всеми нарушенными правилами.
+func Validate(c *gin.Context, obj any, except ...string) bool {
+	var err error
+	if len(except) > 0 {
+		err = validator.Validate.StructExcept(obj, except...)
+	} else {
+		err = validator.Validate.Struct(obj)
+	}
+
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user_id or was not provided"})
-		return
+		fields := validator.FieldErrors(err, validator.Translator(c.GetHeader("Accept-Language")))
+		c.Error(apperror.ValidationFields(validationMessage(fields), fields))
+		return false
 	}
 
-	d := c.Query("date")
-	date, err := time.Parse(time.DateOnly, d)
+	return true
+}
+
+// экземпляр повторяющегося события задается исходным началом в параметре occurrence,
+// область изменения - параметром scope (this, following или all)
+func queryOccurrence(c *gin.Context) (*model.Date, string, bool) {
+	scope := c.Query("scope")
+	if scope != "" && scope != model.ScopeThis && scope != model.ScopeFollowing && scope != model.ScopeAll {
+		c.Error(apperror.Validation("invalid_scope", "invalid scope, must be one of this, following, all"))
+		return nil, "", false
+	}
+
+	o := c.Query("occurrence")
+	if o == "" {
+		return nil, scope, true
+	}
+
+	occurrence, err := model.ParseDate(o)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date format"})
-		return
+		c.Error(apperror.Validation("invalid_occurrence", "invalid occurrence format"))
+		return nil, "", false
 	}
 
-	events, err := h.service.GetEventsForMonth(userId, date)
+	return &occurrence, scope, true
+}
+
+// зона запроса: из query параметра tz или из настроек пользователя
+func (h *Handler) queryLocation(c *gin.Context, userId int) (*time.Location, bool) {
+	loc, err := h.service.Location(userId, c.Query("tz"))
 	if err != nil {
-		if errors.Is(err, repository.ErrNoSuchEvent) || errors.Is(err, repository.ErrNoSuchUser) {
-			c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+		c.Error(err)
+		return nil, false
+	}
+
+	return loc, true
+}
+
+// дата из query параметра date трактуется в зоне tz или в зоне пользователя
+func (h *Handler) queryDate(c *gin.Context, userId int) (time.Time, bool) {
+	loc, ok := h.queryLocation(c, userId)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, c.Query("date"), loc)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_date", "invalid date format"))
+		return time.Time{}, false
+	}
+
+	return date, true
+}
+
+// период [from, to) не длиннее maxRangeSpan, даты без времени считаются в зоне loc
+func parseRange(c *gin.Context, fromValue, toValue string, loc *time.Location) (time.Time, time.Time, bool) {
+	from, err := parseTime(fromValue, loc)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_from", "invalid from format"))
+		return time.Time{}, time.Time{}, false
+	}
+
+	to, err := parseTime(toValue, loc)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_to", "invalid to format"))
+		return time.Time{}, time.Time{}, false
+	}
+
+	if !to.After(from) {
+		c.Error(apperror.Validation("invalid_range", "to must be after from"))
+		return time.Time{}, time.Time{}, false
+	}
+
+	if to.Sub(from) > maxRangeSpan {
+		c.Error(apperror.Validation("range_too_long", "requested range is too long, maximum is 366 days"))
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
+// момент времени в формате RFC3339 или дата YYYY-MM-DD (полночь в зоне loc)
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
+
+// сообщения всех нарушенных правил через "; "
+func validationMessage(fields []apperror.FieldError) string {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// queryUser возвращает пользователя из query параметра user_id, а без него -
+// аутентифицированного пользователя запроса
+func queryUser(c *gin.Context) (int, bool) {
+	if identity, ok := middleware.CurrentIdentity(c); ok && c.Query("user_id") == "" {
+		return identity.UserId, true
+	}
+
+	return queryId(c, "user_id")
+}
+
+// id из query параметра name
+func queryId(c *gin.Context, name string) (int, bool) {
+	id, err := parseId(c.Query(name))
+	if err != nil {
+		c.Error(apperror.Validation("invalid_"+name, "invalid "+name+" or was not provided"))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// id событий и пользователей - положительные целые числа, 0 никогда не выдается
+func parseId(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errInvalidId
 	}
 
-	c.JSON(http.StatusOK, map[string][]*model.Event{"result": events})
+	return id, nil
 }
@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/middleware"
 	"github.com/Komilov31/calendar-service/internal/model"
 	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,48 +26,189 @@ type MockEventsService struct {
 	mock.Mock
 }
 
-func (m *MockEventsService) CreateEvent(event model.Event) model.Event {
-	args := m.Called(event)
-	return args.Get(0).(model.Event)
+func (m *MockEventsService) CreateEvent(actor int, event model.Event) (model.Event, error) {
+	args := m.Called(actor, event)
+	return args.Get(0).(model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) CanCreateEvent(actor int, event model.Event) error {
+	args := m.Called(actor, event)
+	return args.Error(0)
 }
 
-func (m *MockEventsService) UpdateEvent(updateEvent model.UpdateEvent) (model.Event, error) {
-	args := m.Called(updateEvent)
+func (m *MockEventsService) UpdateEvent(actor int, updateEvent model.UpdateEvent) (model.Event, error) {
+	args := m.Called(actor, updateEvent)
 	return args.Get(0).(model.Event), args.Error(1)
 }
 
-func (m *MockEventsService) DeleteEvent(userId int, eventId int) error {
-	args := m.Called(userId, eventId)
+func (m *MockEventsService) DeleteEvent(actor int, deleteEvent model.DeleteEvent) error {
+	args := m.Called(actor, deleteEvent)
 	return args.Error(0)
 }
 
-func (m *MockEventsService) GetEventsForDay(userId int, date time.Time) ([]*model.Event, error) {
-	args := m.Called(userId, date)
+func (m *MockEventsService) GetEvent(actor, userId, eventId int, loc *time.Location) (model.Event, error) {
+	args := m.Called(actor, userId, eventId, loc)
+	return args.Get(0).(model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetEvents(actor, userId int) ([]*model.Event, error) {
+	args := m.Called(actor, userId)
 	return args.Get(0).([]*model.Event), args.Error(1)
 }
 
-func (m *MockEventsService) GetEventsForWeek(userId int, date time.Time) ([]*model.Event, error) {
-	args := m.Called(userId, date)
+func (m *MockEventsService) GetEventsForDay(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
 	return args.Get(0).([]*model.Event), args.Error(1)
 }
 
-func (m *MockEventsService) GetEventsForMonth(userId int, date time.Time) ([]*model.Event, error) {
-	args := m.Called(userId, date)
+func (m *MockEventsService) GetEventsForWeek(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
 	return args.Get(0).([]*model.Event), args.Error(1)
 }
 
+func (m *MockEventsService) GetEventsForISOWeek(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetEventsForMonth(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetEventsForQuarter(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetEventsForYear(actor, userId int, date time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, date)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetEventsInRange(actor, userId int, from, to time.Time) ([]*model.Event, error) {
+	args := m.Called(actor, userId, from, to)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) UpdateUserSettings(actor int, settings model.UserSettings) (model.UserSettings, error) {
+	args := m.Called(actor, settings)
+	return args.Get(0).(model.UserSettings), args.Error(1)
+}
+
+func (m *MockEventsService) GetUserSettings(actor, userId int) (model.UserSettings, error) {
+	args := m.Called(actor, userId)
+	return args.Get(0).(model.UserSettings), args.Error(1)
+}
+
+func (m *MockEventsService) CreateCalendar(actor int, calendar model.Calendar) (model.Calendar, error) {
+	args := m.Called(actor, calendar)
+	return args.Get(0).(model.Calendar), args.Error(1)
+}
+
+func (m *MockEventsService) UpdateCalendar(actor int, calendar model.Calendar) (model.Calendar, error) {
+	args := m.Called(actor, calendar)
+	return args.Get(0).(model.Calendar), args.Error(1)
+}
+
+func (m *MockEventsService) DeleteCalendar(actor, userId, calendarId int) error {
+	args := m.Called(actor, userId, calendarId)
+	return args.Error(0)
+}
+
+func (m *MockEventsService) GetCalendar(actor, userId, calendarId int) (model.Calendar, error) {
+	args := m.Called(actor, userId, calendarId)
+	return args.Get(0).(model.Calendar), args.Error(1)
+}
+
+func (m *MockEventsService) GetCalendars(actor, userId int) ([]model.Calendar, error) {
+	args := m.Called(actor, userId)
+	return args.Get(0).([]model.Calendar), args.Error(1)
+}
+
+func (m *MockEventsService) GetACL(actor, userId, calendarId int) ([]model.ACLEntry, error) {
+	args := m.Called(actor, userId, calendarId)
+	return args.Get(0).([]model.ACLEntry), args.Error(1)
+}
+
+func (m *MockEventsService) PutACL(actor, userId int, entry model.ACLEntry) (model.ACLEntry, error) {
+	args := m.Called(actor, userId, entry)
+	return args.Get(0).(model.ACLEntry), args.Error(1)
+}
+
+func (m *MockEventsService) DeleteACL(actor, userId, calendarId, grantee int) error {
+	args := m.Called(actor, userId, calendarId, grantee)
+	return args.Error(0)
+}
+
+func (m *MockEventsService) CreateUser(actor int, user model.User) (model.User, error) {
+	args := m.Called(actor, user)
+	return args.Get(0).(model.User), args.Error(1)
+}
+
+func (m *MockEventsService) UpdateUser(actor int, user model.User) (model.User, error) {
+	args := m.Called(actor, user)
+	return args.Get(0).(model.User), args.Error(1)
+}
+
+func (m *MockEventsService) GetUser(actor, userId int) (model.User, error) {
+	args := m.Called(actor, userId)
+	return args.Get(0).(model.User), args.Error(1)
+}
+
+func (m *MockEventsService) DeleteUser(actor, userId int, cascade bool) error {
+	args := m.Called(actor, userId, cascade)
+	return args.Error(0)
+}
+
+func (m *MockEventsService) GetInvitations(actor, userId int) ([]*model.Event, error) {
+	args := m.Called(actor, userId)
+	return args.Get(0).([]*model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) Respond(actor, userId, eventId int, status string) (model.Event, error) {
+	args := m.Called(actor, userId, eventId, status)
+	return args.Get(0).(model.Event), args.Error(1)
+}
+
+func (m *MockEventsService) GetFreeBusy(actor, userId int, from, to time.Time) ([]model.Interval, error) {
+	args := m.Called(actor, userId, from, to)
+	return args.Get(0).([]model.Interval), args.Error(1)
+}
+
+func (m *MockEventsService) Location(userId int, tz string) (*time.Location, error) {
+	args := m.Called(userId, tz)
+	loc, _ := args.Get(0).(*time.Location)
+	return loc, args.Error(1)
+}
+
 func setupRouter(h *Handler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(testIdentity)
 	router.POST("/events", h.CreateEvent)
 	router.PUT("/events", h.UpdateEvent)
 	router.DELETE("/events", h.DeleteEvent)
 	router.GET("/events/day", h.GetEventsForDay)
 	router.GET("/events/week", h.GetEventsForWeek)
 	router.GET("/events/month", h.GetEventsForMonth)
+	router.GET("/events/range", h.GetEventsInRange)
+	router.POST("/settings", h.UpdateUserSettings)
+	router.GET("/settings", h.GetUserSettings)
+	router.POST("/freebusy", h.FreeBusy)
+	router.POST("/scheduling/slots", h.FindSlots)
 	return router
 }
 
+// testIdentity заменяет аутентификацию в тестах: пользователь и его права
+// берутся из заголовков X-Test-User и X-Test-Scope
+func testIdentity(c *gin.Context) {
+	if id, err := strconv.Atoi(c.GetHeader("X-Test-User")); err == nil {
+		middleware.SetIdentity(c, auth.Identity{UserId: id, Scopes: strings.Fields(c.GetHeader("X-Test-Scope"))})
+	}
+}
+
 func TestCreateEvent_Success(t *testing.T) {
 	mockService := new(MockEventsService)
 	handler := New(mockService)
@@ -71,12 +218,12 @@ func TestCreateEvent_Success(t *testing.T) {
 	event := model.Event{
 		UserId: 1,
 		Text:   "Test Event",
-		Date:   model.Date(futureDate),
+		Start:  model.Date(futureDate),
 	}
 
-	mockService.On("CreateEvent", mock.MatchedBy(func(e model.Event) bool {
+	mockService.On("CreateEvent", mock.Anything, mock.MatchedBy(func(e model.Event) bool {
 		return e.UserId == 1 && e.Text == "Test Event"
-	})).Return(event)
+	})).Return(event, nil)
 
 	body, _ := json.Marshal(event)
 	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(body))
@@ -110,7 +257,7 @@ func TestCreateEvent_ValidationError(t *testing.T) {
 	event := model.Event{
 		UserId: 1,
 		Text:   "Test Event",
-		Date:   model.Date(pastDate),
+		Start:  model.Date(pastDate),
 	}
 
 	body, _ := json.Marshal(event)
@@ -122,6 +269,131 @@ func TestCreateEvent_ValidationError(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestCreateEvent_AllValidationErrors(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	body := `{"user_id": 1, "start": "2020-01-01T10:00:00Z", "rrule": "FREQ=SOMETIMES", "time_zone": "Mars/Olympus"}`
+
+	send := func(language string) middleware.Problem {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBufferString(body))
+		req.Header.Set("Accept-Language", language)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var problem middleware.Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return problem
+	}
+
+	t.Run("Every failing field is reported with its JSON path", func(t *testing.T) {
+		problem := send("")
+		assert.Equal(t, "validation_failed", problem.Code)
+		assert.Equal(t, []apperror.FieldError{
+			{Field: "text", Rule: "required", Message: "text is a required field"},
+			{Field: "start", Rule: "date_after_now", Message: "start must be a date in the future"},
+			{Field: "rrule", Rule: "rrule", Message: "rrule must be a valid recurrence rule"},
+			{Field: "time_zone", Rule: "timezone", Message: "time_zone must be a valid IANA time zone"},
+		}, problem.Errors)
+	})
+
+	t.Run("Messages follow Accept-Language", func(t *testing.T) {
+		problem := send("de-DE, ru-RU;q=0.9, en;q=0.8")
+		assert.Len(t, problem.Errors, 4)
+		assert.Equal(t, "text обязательное поле", problem.Errors[0].Message)
+		assert.Equal(t, "start должно быть датой в будущем", problem.Errors[1].Message)
+	})
+
+	t.Run("Rule parameters", func(t *testing.T) {
+		start := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+		end := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBufferString(`{"user_id": 1, "text": "Meeting", "start": "`+start+`", "end": "`+end+`"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var problem middleware.Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, []apperror.FieldError{
+			{Field: "end", Rule: "end_after_start", Param: "start", Message: "end must not be before start"},
+		}, problem.Errors)
+	})
+
+	mockService.AssertNotCalled(t, "CreateEvent", mock.Anything)
+}
+
+func TestCreateEvent_EndBeforeStart(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	start := time.Now().Add(48 * time.Hour)
+	event := model.Event{
+		UserId: 1,
+		Text:   "Test Event",
+		Start:  model.Date(start),
+		End:    model.Date(start.Add(-time.Hour)),
+	}
+
+	body, _ := json.Marshal(event)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateEvent", mock.Anything)
+}
+
+func TestCreateEvent_InvalidRecurrenceRule(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	// COUNT и INTERVAL больше допустимого отклоняются так же, как неизвестная частота
+	for _, rule := range []string{"FREQ=SOMETIMES", "FREQ=DAILY;COUNT=1000000000", "FREQ=DAILY;INTERVAL=1000000"} {
+		event := model.Event{
+			UserId:     1,
+			Text:       "Standup",
+			Start:      model.Date(time.Now().Add(48 * time.Hour)),
+			Recurrence: rule,
+		}
+
+		body, _ := json.Marshal(event)
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, rule)
+	}
+
+	mockService.AssertNotCalled(t, "CreateEvent", mock.Anything)
+}
+
+func TestCreateEvent_LegacyDateField(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	date := time.Now().AddDate(0, 0, 2).Format(time.DateOnly)
+
+	mockService.On("CreateEvent", mock.Anything, mock.MatchedBy(func(e model.Event) bool {
+		return e.AllDay && time.Time(e.Start).Format(time.DateOnly) == date
+	})).Return(model.Event{}, nil)
+
+	body := `{"user_id":1,"text":"Test Event","date":"` + date + `"}`
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
 func TestUpdateEvent_Success(t *testing.T) {
 	mockService := new(MockEventsService)
 	handler := New(mockService)
@@ -132,17 +404,17 @@ func TestUpdateEvent_Success(t *testing.T) {
 		EventId: intPtr(1),
 		UserId:  intPtr(1),
 		Text:    stringPtr("Updated Event"),
-		Date:    (*model.Date)(&futureDate),
+		Start:   (*model.Date)(&futureDate),
 	}
 
 	updatedEvent := model.Event{
 		EventId: 1,
 		UserId:  1,
 		Text:    "Updated Event",
-		Date:    model.Date(futureDate),
+		Start:   model.Date(futureDate),
 	}
 
-	mockService.On("UpdateEvent", mock.MatchedBy(func(e model.UpdateEvent) bool {
+	mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(e model.UpdateEvent) bool {
 		return *e.EventId == 1 && *e.UserId == 1 && *e.Text == "Updated Event"
 	})).Return(updatedEvent, nil)
 
@@ -182,6 +454,19 @@ func TestUpdateEvent_InvalidEventID(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestUpdateEvent_NonPositiveEventID(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("PUT", "/events?user_id=1&event_id=0", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestUpdateEvent_ServiceError(t *testing.T) {
 	mockService := new(MockEventsService)
 	handler := New(mockService)
@@ -192,10 +477,10 @@ func TestUpdateEvent_ServiceError(t *testing.T) {
 		EventId: intPtr(1),
 		UserId:  intPtr(1),
 		Text:    stringPtr("Updated Event"),
-		Date:    (*model.Date)(&futureDate),
+		Start:   (*model.Date)(&futureDate),
 	}
 
-	mockService.On("UpdateEvent", mock.MatchedBy(func(e model.UpdateEvent) bool {
+	mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(e model.UpdateEvent) bool {
 		return *e.EventId == 1 && *e.UserId == 1
 	})).Return(model.Event{}, repository.ErrNoSuchEvent)
 
@@ -205,7 +490,7 @@ func TestUpdateEvent_ServiceError(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestDeleteEvent_Success(t *testing.T) {
@@ -213,7 +498,7 @@ func TestDeleteEvent_Success(t *testing.T) {
 	handler := New(mockService)
 	router := setupRouter(handler)
 
-	mockService.On("DeleteEvent", 1, 1).Return(nil)
+	mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 1}).Return(nil)
 
 	req, _ := http.NewRequest("DELETE", "/events?user_id=1&event_id=1", nil)
 	w := httptest.NewRecorder()
@@ -224,6 +509,36 @@ func TestDeleteEvent_Success(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestDeleteEvent_Occurrence(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	occurrence := model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 1, Occurrence: &occurrence, Scope: model.ScopeFollowing}).Return(nil)
+
+	req, _ := http.NewRequest("DELETE", "/events?user_id=1&event_id=1&occurrence=2024-01-15T10:00:00Z&scope=following", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteEvent_InvalidScope(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("DELETE", "/events?user_id=1&event_id=1&occurrence=2024-01-15T10:00:00Z&scope=some", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestDeleteEvent_InvalidUserID(t *testing.T) {
 	mockService := new(MockEventsService)
 	handler := New(mockService)
@@ -255,14 +570,14 @@ func TestDeleteEvent_ServiceError(t *testing.T) {
 	handler := New(mockService)
 	router := setupRouter(handler)
 
-	mockService.On("DeleteEvent", 1, 1).Return(repository.ErrNoSuchEvent)
+	mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 1}).Return(repository.ErrNoSuchEvent)
 
 	req, _ := http.NewRequest("DELETE", "/events?user_id=1&event_id=1", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestGetEventsForDay_Success(t *testing.T) {
@@ -276,11 +591,12 @@ func TestGetEventsForDay_Success(t *testing.T) {
 			EventId: 1,
 			UserId:  1,
 			Text:    "Test Event",
-			Date:    model.Date(date),
+			Start:   model.Date(date),
 		},
 	}
 
-	mockService.On("GetEventsForDay", 1, date).Return(events, nil)
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsForDay", 1, 1, date).Return(events, nil)
 
 	req, _ := http.NewRequest("GET", "/events/day?user_id=1&date=2024-01-15", nil)
 	w := httptest.NewRecorder()
@@ -296,6 +612,8 @@ func TestGetEventsForDay_InvalidDate(t *testing.T) {
 	handler := New(mockService)
 	router := setupRouter(handler)
 
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+
 	req, _ := http.NewRequest("GET", "/events/day?user_id=1&date=invalid", nil)
 	w := httptest.NewRecorder()
 
@@ -315,11 +633,12 @@ func TestGetEventsForWeek_Success(t *testing.T) {
 			EventId: 1,
 			UserId:  1,
 			Text:    "Test Event",
-			Date:    model.Date(date),
+			Start:   model.Date(date),
 		},
 	}
 
-	mockService.On("GetEventsForWeek", 1, date).Return(events, nil)
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsForWeek", 1, 1, date).Return(events, nil)
 
 	req, _ := http.NewRequest("GET", "/events/week?user_id=1&date=2024-01-15", nil)
 	w := httptest.NewRecorder()
@@ -341,11 +660,12 @@ func TestGetEventsForMonth_Success(t *testing.T) {
 			EventId: 1,
 			UserId:  1,
 			Text:    "Test Event",
-			Date:    model.Date(date),
+			Start:   model.Date(date),
 		},
 	}
 
-	mockService.On("GetEventsForMonth", 1, date).Return(events, nil)
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsForMonth", 1, 1, date).Return(events, nil)
 
 	req, _ := http.NewRequest("GET", "/events/month?user_id=1&date=2024-01-15", nil)
 	w := httptest.NewRecorder()
@@ -363,14 +683,200 @@ func TestGetEventsForDay_ServiceError(t *testing.T) {
 
 	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
 
-	mockService.On("GetEventsForDay", 1, date).Return([]*model.Event(nil), repository.ErrNoSuchUser)
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsForDay", 1, 1, date).Return([]*model.Event(nil), repository.ErrNoSuchUser)
 
 	req, _ := http.NewRequest("GET", "/events/day?user_id=1&date=2024-01-15", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetEventsForDay_TimeZone(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	loc, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	date := time.Date(2024, 3, 10, 0, 0, 0, 0, loc)
+
+	mockService.On("Location", 1, "America/New_York").Return(loc, nil)
+	mockService.On("GetEventsForDay", 1, 1, date).Return([]*model.Event{}, nil)
+
+	req, _ := http.NewRequest("GET", "/events/day?user_id=1&date=2024-03-10&tz=America/New_York", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetEventsForDay_InvalidTimeZone(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("Location", 1, "Mars/Olympus").Return(nil, service.ErrInvalidTimeZone)
+
+	req, _ := http.NewRequest("GET", "/events/day?user_id=1&date=2024-03-10&tz=Mars/Olympus", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetEventsInRange_Success(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsInRange", 1, 1, from, to).Return([]*model.Event{}, nil)
+
+	req, _ := http.NewRequest("GET", "/events/range?user_id=1&from=2024-01-15&to=2024-01-20T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetEventsInRange_InvalidRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Missing from", "user_id=1&to=2024-01-20"},
+		{"Invalid to", "user_id=1&from=2024-01-15&to=tomorrow"},
+		{"To before from", "user_id=1&from=2024-01-20&to=2024-01-15"},
+		{"Empty range", "user_id=1&from=2024-01-20&to=2024-01-20"},
+		{"Range too long", "user_id=1&from=2024-01-01&to=2025-06-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockEventsService)
+			handler := New(mockService)
+			router := setupRouter(handler)
+
+			mockService.On("Location", 1, "").Return(time.UTC, nil)
+
+			req, _ := http.NewRequest("GET", "/events/range?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockService.AssertNotCalled(t, "GetEventsInRange", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetEventsForMonth_Pagination(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []*model.Event{
+		{EventId: 3, UserId: 1, Text: "c", Start: model.Date(date.AddDate(0, 0, 3))},
+		{EventId: 1, UserId: 1, Text: "a", Start: model.Date(date.AddDate(0, 0, 5))},
+		{EventId: 2, UserId: 1, Text: "b", Start: model.Date(date.AddDate(0, 0, 1))},
+		{EventId: 5, UserId: 1, Text: "e", Start: model.Date(date.AddDate(0, 0, 3))},
+		{EventId: 4, UserId: 1, Text: "d", Start: model.Date(date.AddDate(0, 0, 2))},
+	}
+
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEventsForMonth", 1, 1, date).Return(events, nil)
+
+	fetch := func(query string) (int, []int, string) {
+		req, _ := http.NewRequest("GET", "/events/month?user_id=1&date=2024-01-01&"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body struct {
+			Result     []model.Event `json:"result"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+
+		var ids []int
+		for _, event := range body.Result {
+			ids = append(ids, event.EventId)
+		}
+		return w.Code, ids, body.NextCursor
+	}
+
+	t.Run("Pages by start with stable tie-break", func(t *testing.T) {
+		code, ids, cursor := fetch("limit=2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int{2, 4}, ids)
+		assert.NotEmpty(t, cursor)
+
+		code, ids, cursor = fetch("limit=2&cursor=" + cursor)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int{3, 5}, ids)
+
+		code, ids, cursor = fetch("limit=2&cursor=" + cursor)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int{1}, ids)
+		assert.Empty(t, cursor)
+	})
+
+	t.Run("Sort by text descending", func(t *testing.T) {
+		code, ids, _ := fetch("sort=-text")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int{5, 4, 3, 2, 1}, ids)
+	})
+
+	t.Run("Cursor from another sort is rejected", func(t *testing.T) {
+		_, _, cursor := fetch("limit=2")
+		code, _, _ := fetch("sort=text&cursor=" + cursor)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, query := range []string{"sort=color", "limit=0", "limit=abc", "cursor=@@@", "fields=color"} {
+			code, _, _ := fetch(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+
+	t.Run("Field selection", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/events/month?user_id=1&date=2024-01-01&limit=1&fields=event_id,text", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Result []map[string]any `json:"result"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, []map[string]any{{"event_id": float64(2), "text": "b"}}, body.Result)
+	})
+}
+
+func TestUpdateUserSettings_InvalidTimeZone(t *testing.T) {
+	mockService := new(MockEventsService)
+	handler := New(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("POST", "/settings?user_id=1", bytes.NewBufferString(`{"time_zone":"Mars/Olympus"}`))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "UpdateUserSettings", mock.Anything)
 }
 
 func intPtr(i int) *int {
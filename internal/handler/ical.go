@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	formatJSON = "json"
+	formatICS  = "ics"
+
+	calendarMIME = "text/calendar"
+)
+
+// responseFormat выбирает формат списка событий: параметр format (json или ics),
+// иначе заголовок Accept. По умолчанию и для неизвестных типов - JSON.
+func responseFormat(c *gin.Context) (string, error) {
+	switch format := c.Query("format"); format {
+	case formatJSON, formatICS:
+		return format, nil
+	case "":
+	default:
+		return "", apperror.Validation("invalid_format", "invalid format, must be one of json, ics")
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, calendarMIME) == calendarMIME {
+		return formatICS, nil
+	}
+
+	return formatJSON, nil
+}
+
+// ExportCalendarV2 отдает события пользователя одним календарем iCalendar:
+// серии с правилами и исключениями, а не развернутые экземпляры. Адрес подходит
+// для подписки в календарных клиентах. С calendar_id выгружаются только
+// события указанных календарей.
+func (h *Handler) ExportCalendarV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	ids, err := queryCalendars(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	events, err := h.service.GetEvents(middleware.Actor(c, userId), userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	events, err = h.inCalendars(c, userId, ids, events)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	name := fmt.Sprintf("calendar-service: user %d", userId)
+	if len(ids) == 1 {
+		calendar, err := h.service.GetCalendar(middleware.Actor(c, userId), userId, ids[0])
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		name = calendar.Name
+	}
+
+	respondCalendar(c, ical.Calendar{Name: name, Events: events})
+}
+
+func respondCalendar(c *gin.Context, cal ical.Calendar) {
+	c.Data(http.StatusOK, ical.ContentType, ical.Marshal(cal))
+}
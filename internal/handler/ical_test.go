@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsAsCalendar(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	events := []*model.Event{{EventId: 1, UserId: 1, Text: "Test Event", Start: model.Date(date.Add(10 * time.Hour)), End: model.Date(date.Add(11 * time.Hour))}}
+
+	tests := []struct {
+		name, query, accept string
+		calendar            bool
+	}{
+		{"Default is JSON", "", "", false},
+		{"Format parameter", "&format=ics", "", true},
+		{"Accept header", "", "text/calendar", true},
+		{"Accept with wildcard", "", "*/*", false},
+		{"Format parameter wins", "&format=json", "text/calendar", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockEventsService)
+			mockService.On("Location", 1, "").Return(time.UTC, nil)
+			mockService.On("GetEventsForDay", 1, 1, date).Return(events, nil)
+
+			req, _ := http.NewRequest(http.MethodGet, "/events/day?user_id=1&date=2024-01-15"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			setupRouter(New(mockService)).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.calendar {
+				assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "UID:1@calendar-service\r\n")
+				assert.Contains(t, w.Body.String(), "DTSTART:20240115T100000Z\r\n")
+			} else {
+				assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+			}
+		})
+	}
+
+	t.Run("Unknown format", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		req, _ := http.NewRequest(http.MethodGet, "/events/day?user_id=1&date=2024-01-15&format=xml", nil)
+		w := httptest.NewRecorder()
+		setupRouter(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_format"`)
+	})
+}
+
+func TestExportCalendarV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockService.On("GetEvents", 1, 1).Return([]*model.Event{
+		{EventId: 1, UserId: 1, Text: "Standup", Recurrence: "FREQ=DAILY", Start: model.Date(start), End: model.Date(start.Add(15 * time.Minute))},
+	}, nil)
+	mockService.On("GetEvents", 2, 2).Return([]*model.Event(nil), repository.ErrNoSuchUser)
+
+	t.Run("Feed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/calendar.ics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "RRULE:FREQ=DAILY\r\n")
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/2/calendar.ics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// максимальный размер импортируемого файла
+const maxImportSize = 10 << 20
+
+var errImportTooLarge = apperror.TooLarge("import_too_large", "calendar file is larger than 10 MB")
+
+// статусы компонентов в отчете об импорте
+const (
+	importCreated = "created"
+	importSkipped = "skipped"
+	importFailed  = "failed"
+)
+
+// importReport - итог импорта по каждому VEVENT файла. В режиме dry_run события
+// не создаются, а статус created означает, что событие было бы создано.
+type importReport struct {
+	DryRun  bool         `json:"dry_run"`
+	Created int          `json:"created"`
+	Skipped int          `json:"skipped"`
+	Failed  int          `json:"failed"`
+	Items   []importItem `json:"items"`
+}
+
+type importItem struct {
+	Line    int                   `json:"line"`
+	UID     string                `json:"uid,omitempty"`
+	Status  string                `json:"status"`
+	EventId int                   `json:"event_id,omitempty"`
+	Reason  string                `json:"reason,omitempty"`
+	Errors  []apperror.FieldError `json:"errors,omitempty"`
+}
+
+// ImportEventsV2 создает события из файла iCalendar. Файл передается телом запроса
+// или полем file формы multipart/form-data. События с UID, который уже есть у
+// пользователя, пропускаются, поэтому повторный импорт того же файла безопасен.
+// Начало в прошлом допустимо: импортируется история.
+func (h *Handler) ImportEventsV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.Error(apperror.Validation("invalid_dry_run", "invalid dry_run, must be true or false"))
+		return
+	}
+
+	// время без зоны трактуется в зоне tz или в зоне пользователя
+	loc, ok := h.queryLocation(c, userId)
+	if !ok {
+		return
+	}
+
+	body, err := importBody(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer body.Close()
+
+	components, err := ical.Parse(body, loc)
+	if err != nil {
+		if tooLarge(err) {
+			c.Error(errImportTooLarge)
+			return
+		}
+
+		c.Error(apperror.Validation("invalid_calendar", err.Error()))
+		return
+	}
+
+	imported, err := h.importedUIDs(c, userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	trans := validator.Translator(c.GetHeader("Accept-Language"))
+	report := importReport{DryRun: dryRun, Items: make([]importItem, len(components))}
+
+	for i, component := range components {
+		item := importItem{Line: component.Line, UID: component.UID}
+
+		switch {
+		case component.Err != nil:
+			item.Status, item.Reason = importFailed, component.Err.Error()
+		case component.Master >= 0:
+			// исключения серии получают статус серии во втором проходе
+		case component.Cancelled:
+			item.Status, item.Reason = importSkipped, "event is cancelled"
+		case component.UID != "" && imported[component.UID]:
+			item.Status, item.Reason = importSkipped, "event with this UID already exists"
+		default:
+			event := component.Event
+			event.UserId = userId
+			for _, override := range event.Overrides {
+				override.UserId = userId
+			}
+
+			if err := validator.Validate.StructExcept(event, "Start"); err != nil {
+				item.Status, item.Errors = importFailed, validator.FieldErrors(err, trans)
+				item.Reason = validationMessage(item.Errors)
+				break
+			}
+
+			// в режиме dry_run выполняются те же проверки прав, что и при создании
+			item.Status = importCreated
+			if dryRun {
+				if err := h.service.CanCreateEvent(middleware.Actor(c, userId), event); err != nil {
+					item.Status, item.Reason = importFailed, apperror.From(err).Message
+					break
+				}
+			} else {
+				created, err := h.service.CreateEvent(middleware.Actor(c, userId), event)
+				if err != nil {
+					item.Status, item.Reason = importFailed, apperror.From(err).Message
+					break
+				}
+				item.EventId = created.EventId
+			}
+
+			if component.UID != "" {
+				imported[component.UID] = true
+			}
+		}
+
+		report.Items[i] = item
+	}
+
+	for i, component := range components {
+		if component.Err != nil || component.Master < 0 {
+			continue
+		}
+
+		master := report.Items[component.Master]
+		report.Items[i].Status, report.Items[i].EventId = master.Status, master.EventId
+		report.Items[i].Reason = fmt.Sprintf("exception of the recurring event on line %d", master.Line)
+	}
+
+	for _, item := range report.Items {
+		switch item.Status {
+		case importCreated:
+			report.Created++
+		case importSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, map[string]importReport{"result": report})
+}
+
+// файл из поля file формы или тело запроса целиком
+func importBody(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, nil
+	}
+
+	header, err := c.FormFile("file")
+	if tooLarge(err) {
+		return nil, errImportTooLarge
+	}
+	if err != nil {
+		return nil, apperror.Validation("invalid_file", "calendar must be sent in the file field: "+err.Error())
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, apperror.Validation("invalid_file", err.Error())
+	}
+
+	return file, nil
+}
+
+// тело запроса оборвано на maxImportSize
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// UID событий пользователя, с которыми совпадет UID из файла: сохраненные при
+// импорте и построенные по id для событий, выгруженных этим сервисом
+func (h *Handler) importedUIDs(c *gin.Context, userId int) (map[string]bool, error) {
+	events, err := h.service.GetEvents(middleware.Actor(c, userId), userId)
+	// у пользователя еще нет событий
+	if err != nil && apperror.From(err).Kind != apperror.KindNotFound {
+		return nil, err
+	}
+
+	uids := make(map[string]bool, len(events))
+	for _, event := range events {
+		uids[ical.UID(event)] = true
+	}
+
+	return uids, nil
+}
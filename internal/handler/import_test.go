@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const importCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:old@example.com\r\n" +
+	"SUMMARY:Kickoff in 2019\r\n" +
+	"DTSTART:20190115T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:series@example.com\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"DTSTART:20240115T090000Z\r\n" +
+	"RRULE:FREQ=DAILY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:series@example.com\r\n" +
+	"RECURRENCE-ID:20240116T090000Z\r\n" +
+	"SUMMARY:Standup (moved)\r\n" +
+	"DTSTART:20240116T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:exists@example.com\r\n" +
+	"SUMMARY:Already here\r\n" +
+	"DTSTART:20240115T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:untitled@example.com\r\n" +
+	"DTSTART:20240115T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:broken@example.com\r\n" +
+	"SUMMARY:Broken\r\n" +
+	"DTSTART:yesterday\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestImportEventsV2(t *testing.T) {
+	setup := func() *MockEventsService {
+		mockService := new(MockEventsService)
+		mockService.On("Location", 1, "").Return(time.UTC, nil)
+		mockService.On("GetEvents", 1, 1).Return([]*model.Event{{EventId: 3, UserId: 1, UID: "exists@example.com"}}, nil)
+		return mockService
+	}
+
+	send := func(mockService *MockEventsService, query, contentType string, body *bytes.Buffer) (*httptest.ResponseRecorder, importReport) {
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users/1/events/import"+query, body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		var response map[string]importReport
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response["result"]
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		mockService := setup()
+		mockService.On("CreateEvent", mock.Anything, mock.MatchedBy(func(e model.Event) bool { return e.UID == "old@example.com" })).
+			Return(model.Event{EventId: 10}, nil)
+		mockService.On("CreateEvent", mock.Anything, mock.MatchedBy(func(e model.Event) bool {
+			return e.UID == "series@example.com" && e.UserId == 1 && len(e.Overrides) == 1 && e.Overrides[0].Text == "Standup (moved)"
+		})).Return(model.Event{EventId: 11}, nil)
+
+		w, report := send(mockService, "", "text/calendar", bytes.NewBufferString(importCalendar))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, report.DryRun)
+		assert.Equal(t, 3, report.Created)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 2, report.Failed)
+
+		require.Len(t, report.Items, 6)
+		assert.Equal(t, importItem{Line: 3, UID: "old@example.com", Status: importCreated, EventId: 10}, report.Items[0])
+		assert.Equal(t, importCreated, report.Items[2].Status)
+		assert.Equal(t, 11, report.Items[2].EventId)
+		assert.Equal(t, importSkipped, report.Items[3].Status)
+		assert.Equal(t, importFailed, report.Items[4].Status)
+		assert.Equal(t, "text", report.Items[4].Errors[0].Field)
+		assert.Equal(t, importFailed, report.Items[5].Status)
+		assert.Contains(t, report.Items[5].Reason, "DTSTART")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Dry run creates nothing", func(t *testing.T) {
+		mockService := setup()
+		mockService.On("CanCreateEvent", 1, mock.Anything).Return(nil)
+
+		w, report := send(mockService, "?dry_run=true", "text/calendar", bytes.NewBufferString(importCalendar))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 3, report.Created)
+		assert.Zero(t, report.Items[0].EventId)
+		mockService.AssertNotCalled(t, "CreateEvent", mock.Anything)
+	})
+
+	t.Run("Multipart upload to a user without events", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("Location", 1, "").Return(time.UTC, nil)
+		mockService.On("GetEvents", 1, 1).Return([]*model.Event{}, nil)
+		mockService.On("CanCreateEvent", 1, mock.Anything).Return(nil)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, _ := form.CreateFormFile("file", "team.ics")
+		file.Write([]byte(importCalendar))
+		form.Close()
+
+		w, report := send(mockService, "?dry_run=1", form.FormDataContentType(), &body)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 4, report.Created)
+		assert.Equal(t, 0, report.Skipped)
+	})
+
+	t.Run("Dry run checks access like import", func(t *testing.T) {
+		s := service.New(repository.New())
+		for _, userId := range []int{1, 2} {
+			_, err := s.CreateUser(userId, model.User{UserId: userId, Name: "User"})
+			require.NoError(t, err)
+		}
+		calendar, err := s.CreateCalendar(1, model.Calendar{UserId: 1, Name: "Personal"})
+		require.NoError(t, err)
+		_, err = s.PutACL(1, 1, model.ACLEntry{CalendarId: calendar.CalendarId, UserId: 2, Role: model.RoleViewer})
+		require.NoError(t, err)
+
+		// зритель календаря: в проверке без записи ответ тот же, что и при импорте
+		var reports []importReport
+		for _, query := range []string{"?dry_run=true", ""} {
+			req, _ := http.NewRequest(http.MethodPost, "/v2/users/1/events/import"+query, bytes.NewBufferString(importCalendar))
+			req.Header.Set("Content-Type", "text/calendar")
+			req.Header.Set("X-Test-User", "2")
+			w := httptest.NewRecorder()
+			setupV2Router(New(s)).ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]importReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			reports = append(reports, response["result"])
+		}
+
+		dryRun, imported := reports[0], reports[1]
+		assert.Zero(t, dryRun.Created)
+		assert.Equal(t, imported.Created, dryRun.Created)
+		assert.Equal(t, imported.Failed, dryRun.Failed)
+		assert.Equal(t, service.ErrAccessDenied.Message, dryRun.Items[0].Reason)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		calendar := importCalendar + strings.Repeat("X-FILLER:"+strings.Repeat("x", 1000)+"\r\n", maxImportSize/1000)
+
+		w, _ := send(setup(), "", "text/calendar", bytes.NewBufferString(calendar))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"import_too_large"`)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, _ := form.CreateFormFile("file", "team.ics")
+		file.Write([]byte(calendar))
+		form.Close()
+
+		w, _ = send(setup(), "", form.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"import_too_large"`)
+	})
+
+	t.Run("Not a calendar", func(t *testing.T) {
+		w, _ := send(setup(), "", "text/calendar", bytes.NewBufferString("hello"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_calendar"`)
+	})
+}
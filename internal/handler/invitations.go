@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Обработчики приглашений /v2/users/:user_id/invitations
+
+// ListInvitationsV2 возвращает события других пользователей, куда приглашен user_id
+func (h *Handler) ListInvitationsV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	events, err := h.service.GetInvitations(middleware.Actor(c, userId), userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string][]*model.Event{"result": events})
+}
+
+// RespondInvitationV2 принимает приглашение, отклоняет его или отвечает "возможно"
+func (h *Handler) RespondInvitationV2(c *gin.Context) {
+	userId, eventId, ok := pathIds(c)
+	if !ok {
+		return
+	}
+
+	var rsvp model.RSVP
+	if !bindJSON(c, &rsvp) {
+		return
+	}
+
+	if !Validate(c, rsvp) {
+		return
+	}
+
+	event, err := h.service.Respond(middleware.Actor(c, userId), userId, eventId, rsvp.Status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.Event{"result": event})
+}
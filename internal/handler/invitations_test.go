@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitationsV2(t *testing.T) {
+	t.Run("List", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetInvitations", 2, 2).Return([]*model.Event{
+			{EventId: 42, UserId: 1, Text: "Sync", Attendees: []model.Attendee{{UserId: 2, Status: model.StatusNeedsAction}}},
+		}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/2/invitations", nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"attendees":[{"user_id":2,"status":"needs-action"}]`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Respond", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("Respond", 2, 2, 42, model.StatusDeclined).Return(model.Event{EventId: 42, UserId: 1}, nil)
+
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/2/invitations/42", bytes.NewBufferString(`{"status":"declined"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid status", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/2/invitations/42", bytes.NewBufferString(`{"status":"maybe"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(new(MockEventsService))).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"status"`)
+	})
+
+	t.Run("Not invited", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("Respond", 3, 3, 42, model.StatusAccepted).Return(model.Event{}, repository.ErrNoSuchInvitation)
+
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/3/invitations/42", bytes.NewBufferString(`{"status":"accepted"}`))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invitation_not_found"`)
+	})
+}
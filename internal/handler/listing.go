@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/ical"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+const maxPageLimit = 1000
+
+var (
+	sortFields  = []string{"start", "created", "updated", "text"}
+	eventFields = jsonFields(reflect.TypeOf(model.Event{}))
+
+	errInvalidCursor = apperror.Validation("invalid_cursor", "invalid cursor")
+)
+
+// listResponse - конверт ответа со списком событий. NextCursor передается
+// в параметре cursor, чтобы получить следующую страницу.
+type listResponse struct {
+	Result     any    `json:"result"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listOptions - параметры выдачи списка: sort (start, created, updated, text,
+// с префиксом "-" по убыванию), limit, cursor, fields, формат ответа и
+// календари, события которых попадают в выдачу
+type listOptions struct {
+	sort      string
+	desc      bool
+	limit     int
+	after     *position
+	fields    []string
+	format    string
+	calendars []int
+}
+
+// position - место события в отсортированной выдаче. Кроме ключа сортировки
+// учитываются id и начало экземпляра, поэтому порядок всегда строгий.
+type position struct {
+	Sort       string    `json:"s"`
+	Time       time.Time `json:"t,omitempty"`
+	Text       string    `json:"x,omitempty"`
+	EventId    int       `json:"id"`
+	Occurrence time.Time `json:"o,omitempty"`
+}
+
+func parseListOptions(c *gin.Context) (listOptions, error) {
+	format, err := responseFormat(c)
+	if err != nil {
+		return listOptions{}, err
+	}
+	calendars, err := queryCalendars(c)
+	if err != nil {
+		return listOptions{}, err
+	}
+	opts := listOptions{sort: "start", format: format, calendars: calendars}
+
+	if s := c.Query("sort"); s != "" {
+		opts.sort, opts.desc = strings.CutPrefix(s, "-")
+		if !slices.Contains(sortFields, opts.sort) {
+			return listOptions{}, apperror.Validation("invalid_sort", "invalid sort, must be one of "+strings.Join(sortFields, ", "))
+		}
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return listOptions{}, apperror.Validation("invalid_limit", fmt.Sprintf("invalid limit, must be between 1 and %d", maxPageLimit))
+		}
+		opts.limit = limit
+	}
+
+	if cur := c.Query("cursor"); cur != "" {
+		after, err := decodeCursor(cur)
+		if err != nil || after.Sort != opts.sortParam() {
+			return listOptions{}, errInvalidCursor
+		}
+		opts.after = &after
+	}
+
+	if f := c.Query("fields"); f != "" {
+		for _, field := range strings.Split(f, ",") {
+			field = strings.TrimSpace(field)
+			if !slices.Contains(eventFields, field) {
+				return listOptions{}, apperror.Validation("unknown_field", "unknown field "+field)
+			}
+			opts.fields = append(opts.fields, field)
+		}
+	}
+
+	return opts, nil
+}
+
+// page сортирует события и возвращает страницу после курсора и курсор следующей страницы
+func (o listOptions) page(events []*model.Event) ([]*model.Event, string) {
+	sorted := slices.Clone(events)
+	slices.SortStableFunc(sorted, func(a, b *model.Event) int {
+		return o.compare(o.positionOf(a), o.positionOf(b))
+	})
+
+	if o.after != nil {
+		i, _ := slices.BinarySearchFunc(sorted, *o.after, func(e *model.Event, p position) int {
+			if o.compare(o.positionOf(e), p) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		sorted = sorted[i:]
+	}
+
+	if o.limit == 0 || len(sorted) <= o.limit {
+		return sorted, ""
+	}
+
+	sorted = sorted[:o.limit]
+	return sorted, encodeCursor(o.positionOf(sorted[len(sorted)-1]))
+}
+
+func (o listOptions) positionOf(event *model.Event) position {
+	p := position{Sort: o.sortParam(), EventId: event.EventId}
+	if event.RecurrenceId != nil {
+		p.Occurrence = time.Time(*event.RecurrenceId)
+	}
+
+	switch o.sort {
+	case "created":
+		p.Time = time.Time(event.CreatedAt)
+	case "updated":
+		p.Time = time.Time(event.UpdatedAt)
+	case "text":
+		p.Text = event.Text
+	default:
+		p.Time = time.Time(event.Start)
+	}
+
+	return p
+}
+
+func (o listOptions) compare(a, b position) int {
+	result := a.Time.Compare(b.Time)
+	if result == 0 {
+		result = strings.Compare(a.Text, b.Text)
+	}
+	if result == 0 {
+		result = cmp.Compare(a.EventId, b.EventId)
+	}
+	if result == 0 {
+		result = a.Occurrence.Compare(b.Occurrence)
+	}
+
+	if o.desc {
+		return -result
+	}
+	return result
+}
+
+func (o listOptions) sortParam() string {
+	if o.desc {
+		return "-" + o.sort
+	}
+	if o.sort == "start" {
+		return ""
+	}
+	return o.sort
+}
+
+func encodeCursor(p position) string {
+	data, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (position, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position{}, errInvalidCursor
+	}
+
+	var p position
+	if err := json.Unmarshal(data, &p); err != nil {
+		return position{}, errInvalidCursor
+	}
+
+	return p, nil
+}
+
+// project оставляет у событий только запрошенные поля
+func project(events []*model.Event, fields []string) ([]map[string]json.RawMessage, error) {
+	result := make([]map[string]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				selected[field] = value
+			}
+		}
+		result = append(result, selected)
+	}
+
+	return result, nil
+}
+
+// respondEvents отдает список событий с учетом сортировки, пагинации и выбора полей.
+// Календарь iCalendar содержит все события периода, параметры выдачи к нему не применяются.
+func respondEvents(c *gin.Context, opts listOptions, events []*model.Event) {
+	if opts.format == formatICS {
+		respondCalendar(c, ical.Calendar{Events: events})
+		return
+	}
+
+	page, next := opts.page(events)
+
+	if len(opts.fields) == 0 {
+		if page == nil {
+			page = []*model.Event{}
+		}
+		c.JSON(http.StatusOK, listResponse{Result: page, NextCursor: next})
+		return
+	}
+
+	projected, err := project(page, opts.fields)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listResponse{Result: projected, NextCursor: next})
+}
+
+// имена полей структуры в JSON
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
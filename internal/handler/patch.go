@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/jsonpatch"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// поля, которые задает сервер: патч не может их менять
+var readOnlyFields = []string{"event_id", "user_id", "uid", "version", "recurrence_id", "exdates", "overrides", "created_at", "updated_at"}
+
+// patchEvent применяет JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902) к сохраненному
+// событию в UTC. Результат проверяется заново и заменяет событие целиком: поле, удаленное
+// патчем, сбрасывается. Замена применяется только к той версии, к которой применялся патч,
+// поэтому параллельное изменение между чтением и записью дает 412, а не теряется.
+func (h *Handler) patchEvent(c *gin.Context, userId, eventId int, contentType string) {
+	if c.Query("occurrence") != "" {
+		c.Error(apperror.Validation("patch_occurrence_unsupported", "patch documents apply to the whole event, use application/json to change an occurrence"))
+		return
+	}
+
+	versions, ok := IfMatch(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_patch", err.Error()))
+		return
+	}
+
+	stored, err := h.service.GetEvent(middleware.Actor(c, userId), userId, eventId, time.UTC)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(versions) > 0 && !slices.Contains(versions, stored.Version) {
+		c.Error(errVersionMismatch)
+		return
+	}
+
+	doc, err := json.Marshal(stored)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	patched, err := applyPatch(doc, body, contentType)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if field, changed := changedReadOnlyField(doc, patched); changed {
+		c.Error(apperror.Validation("read_only_field", field+" cannot be changed"))
+		return
+	}
+
+	var event model.Event
+	if err := json.Unmarshal(patched, &event); err != nil {
+		c.Error(apperror.Validation("invalid_patch", "patched event is not valid: "+err.Error()))
+		return
+	}
+
+	// начало, которое уже прошло, не мешает менять остальные поля
+	var except []string
+	if time.Time(event.Start).Equal(time.Time(stored.Start)) {
+		except = append(except, "Start")
+	}
+	if !Validate(c, event, except...) {
+		return
+	}
+
+	updateEvent := replacement(userId, eventId, event)
+	updateEvent.IfMatch = []int{stored.Version}
+
+	// в отличие от PUT, отсутствие attendees в результате патча означает, что их убрали
+	if updateEvent.Attendees == nil {
+		updateEvent.Attendees = &[]model.Attendee{}
+	}
+
+	updated, err := h.service.UpdateEvent(middleware.Actor(c, userId), updateEvent)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, updated)
+	c.JSON(http.StatusOK, map[string]model.Event{"result": updated})
+}
+
+func applyPatch(doc, body []byte, contentType string) ([]byte, error) {
+	var (
+		patched []byte
+		err     error
+	)
+
+	if contentType == mergePatchType {
+		patched, err = jsonpatch.MergePatch(doc, body)
+	} else {
+		var patch jsonpatch.Patch
+		if patch, err = jsonpatch.Decode(body); err == nil {
+			patched, err = patch.Apply(doc)
+		}
+	}
+
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return nil, apperror.Conflict("patch_test_failed", err.Error())
+	case err != nil:
+		return nil, apperror.Validation("invalid_patch", err.Error())
+	}
+
+	return patched, nil
+}
+
+func changedReadOnlyField(doc, patched []byte) (string, bool) {
+	var before, after map[string]any
+	if json.Unmarshal(doc, &before) != nil || json.Unmarshal(patched, &after) != nil {
+		return "", false
+	}
+
+	for _, field := range readOnlyFields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			return field, true
+		}
+	}
+
+	return "", false
+}
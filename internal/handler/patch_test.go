@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPatchEventV2_Documents(t *testing.T) {
+	start := time.Date(2020, 1, 15, 10, 0, 0, 0, time.UTC) // уже прошло
+	stored := model.Event{
+		EventId:    42,
+		UserId:     1,
+		Text:       "Standup",
+		Start:      model.Date(start),
+		End:        model.Date(start.Add(15 * time.Minute)),
+		Recurrence: "FREQ=DAILY",
+	}
+
+	send := func(mockService *MockEventsService, contentType, body string) *httptest.ResponseRecorder {
+		mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(stored, nil)
+
+		req, _ := http.NewRequest(http.MethodPatch, "/v2/users/1/events/42", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Merge patch changes and clears fields", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return *u.EventId == 42 && *u.Text == "Daily sync" && *u.Recurrence == "" &&
+				time.Time(*u.Start).Equal(start) && time.Time(*u.End).Equal(start.Add(15*time.Minute))
+		})).Return(model.Event{EventId: 42, UserId: 1, Text: "Daily sync"}, nil)
+
+		w := send(mockService, "application/merge-patch+json", `{"text": "Daily sync", "rrule": null}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("JSON patch", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return *u.Text == "Daily sync" && *u.Recurrence == "FREQ=DAILY"
+		})).Return(model.Event{EventId: 42, UserId: 1, Text: "Daily sync"}, nil)
+
+		w := send(mockService, "application/json-patch+json",
+			`[{"op": "test", "path": "/text", "value": "Standup"}, {"op": "replace", "path": "/text", "value": "Daily sync"}]`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Failed test operation is a conflict", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, "application/json-patch+json",
+			`[{"op": "test", "path": "/text", "value": "Retro"}, {"op": "replace", "path": "/text", "value": "Daily sync"}]`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"patch_test_failed"`)
+		mockService.AssertNotCalled(t, "UpdateEvent", mock.Anything)
+	})
+
+	t.Run("Ids cannot be changed", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, "application/merge-patch+json", `{"user_id": 2}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"read_only_field"`)
+		mockService.AssertNotCalled(t, "UpdateEvent", mock.Anything)
+	})
+
+	t.Run("Patched event is validated again", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, "application/merge-patch+json", `{"text": null, "end": "2020-01-15T09:00:00Z"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"text"`)
+		assert.Contains(t, w.Body.String(), `"field":"end"`)
+		assert.NotContains(t, w.Body.String(), `"field":"start"`)
+		mockService.AssertNotCalled(t, "UpdateEvent", mock.Anything)
+	})
+
+	t.Run("Moved start must be in the future", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, "application/merge-patch+json", `{"start": "2020-01-16T10:00:00Z", "end": "2020-01-16T10:15:00Z"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"rule":"date_after_now"`)
+	})
+
+	t.Run("Malformed patch", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, "application/json-patch+json", `[{"op": "jump", "path": "/text"}]`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_patch"`)
+	})
+
+	t.Run("Occurrence is not supported", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		req, _ := http.NewRequest(http.MethodPatch, "/v2/users/1/events/42?occurrence=2020-01-16T10:00:00Z", bytes.NewBufferString(`{"text": "Sync"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "GetEvent", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestPatchEventV2_Attendees(t *testing.T) {
+	start := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	stored := model.Event{
+		EventId:   42,
+		UserId:    1,
+		Text:      "Planning",
+		Start:     model.Date(start),
+		End:       model.Date(start.Add(time.Hour)),
+		Attendees: []model.Attendee{{UserId: 2, Status: model.StatusNeedsAction}},
+	}
+
+	send := func(contentType, body string, attendees []model.Attendee) {
+		mockService := new(MockEventsService)
+		mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(stored, nil)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return u.Attendees != nil && assert.ObjectsAreEqual(attendees, *u.Attendees)
+		})).Return(model.Event{EventId: 42, UserId: 1, Text: "Planning"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPatch, "/v2/users/1/events/42", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		mockService.AssertExpectations(t)
+	}
+
+	t.Run("Merge patch null removes attendees", func(t *testing.T) {
+		send("application/merge-patch+json", `{"attendees": null}`, []model.Attendee{})
+	})
+
+	t.Run("JSON patch remove removes attendees", func(t *testing.T) {
+		send("application/json-patch+json", `[{"op": "remove", "path": "/attendees"}]`, []model.Attendee{})
+	})
+
+	t.Run("Other fields keep attendees", func(t *testing.T) {
+		send("application/merge-patch+json", `{"text": "Planning"}`, stored.Attendees)
+	})
+}
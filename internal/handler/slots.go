@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/scheduling"
+	"github.com/gin-gonic/gin"
+)
+
+// по умолчанию предлагается пять слотов в рабочие часы с 9 до 18
+const (
+	defaultSlotLimit = 5
+	defaultWorkStart = "09:00"
+	defaultWorkEnd   = "18:00"
+)
+
+// FindSlots подбирает время встречи, свободное у всех user_ids, см. scheduling.Find.
+// Занятость участников берется из /freebusy с теми же правами доступа.
+func (h *Handler) FindSlots(c *gin.Context) {
+	var query model.SlotQuery
+	if !bindJSON(c, &query) {
+		return
+	}
+
+	if !Validate(c, query) {
+		return
+	}
+
+	loc, err := time.LoadLocation(query.TimeZone)
+	if err != nil {
+		c.Error(apperror.Validation("invalid_time_zone", "unknown time zone"))
+		return
+	}
+
+	from, to, ok := parseRange(c, query.From, query.To, loc)
+	if !ok {
+		return
+	}
+
+	workStart, workEnd, ok := parseWorkingHours(c, query.WorkingHours)
+	if !ok {
+		return
+	}
+
+	buffer := time.Duration(query.Buffer) * time.Minute
+
+	// занятость с запасом на buffer, чтобы учесть события сразу за границами периода
+	busy := make([][]scheduling.Interval, 0, len(query.UserIds))
+	for _, userId := range query.UserIds {
+		intervals, err := h.service.GetFreeBusy(middleware.Actor(c, userId), userId, from.Add(-buffer).In(loc), to.Add(buffer).In(loc))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		userBusy := make([]scheduling.Interval, 0, len(intervals))
+		for _, interval := range intervals {
+			userBusy = append(userBusy, scheduling.Interval{Start: time.Time(interval.Start), End: time.Time(interval.End)})
+		}
+		busy = append(busy, userBusy)
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultSlotLimit
+	}
+
+	slots := scheduling.Find(scheduling.Options{
+		From:      from.In(loc),
+		To:        to.In(loc),
+		Duration:  time.Duration(query.Duration) * time.Minute,
+		Buffer:    buffer,
+		WorkStart: workStart,
+		WorkEnd:   workEnd,
+		WorkDays:  weekdays(query.WorkingDays),
+		Limit:     limit,
+	}, busy...)
+
+	result := make([]model.Interval, 0, len(slots))
+	for _, slot := range slots {
+		result = append(result, model.Interval{Start: model.Date(slot.Start), End: model.Date(slot.End)})
+	}
+
+	c.JSON(http.StatusOK, map[string][]model.Interval{"result": result})
+}
+
+// рабочие часы как смещение от полуночи, конец должен быть позже начала
+func parseWorkingHours(c *gin.Context, hours model.WorkingHours) (time.Duration, time.Duration, bool) {
+	start, end := cmp.Or(hours.Start, defaultWorkStart), cmp.Or(hours.End, defaultWorkEnd)
+
+	startTime, startErr := time.Parse("15:04", start)
+	endTime, endErr := time.Parse("15:04", end)
+	if startErr != nil || endErr != nil || !endTime.After(startTime) {
+		c.Error(apperror.Validation("invalid_working_hours", "working_hours end must be after start"))
+		return 0, 0, false
+	}
+
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return startTime.Sub(midnight), endTime.Sub(midnight), true
+}
+
+func weekdays(names []string) []time.Weekday {
+	var days []time.Weekday
+	for _, name := range names {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String(), name) {
+				days = append(days, day)
+			}
+		}
+	}
+
+	return days
+}
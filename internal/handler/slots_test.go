@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/Komilov31/calendar-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSlots(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		moscow, _ := time.LoadLocation("Europe/Moscow")
+		at := func(hour, minute int) model.Date {
+			return model.Date(time.Date(2024, 1, 15, hour, minute, 0, 0, moscow))
+		}
+
+		// занятость запрашивается с запасом на buffer
+		from := time.Date(2024, 1, 14, 23, 50, 0, 0, moscow)
+		to := time.Date(2024, 1, 16, 0, 10, 0, 0, moscow)
+		mockService := new(MockEventsService)
+		mockService.On("GetFreeBusy", 1, 1, mock.MatchedBy(from.Equal), mock.MatchedBy(to.Equal)).
+			Return([]model.Interval{{Start: at(10, 0), End: at(11, 0)}}, nil)
+		mockService.On("GetFreeBusy", 2, 2, mock.MatchedBy(from.Equal), mock.MatchedBy(to.Equal)).
+			Return([]model.Interval{{Start: at(11, 30), End: at(11, 45)}}, nil)
+
+		body := `{"user_ids":[1,2],"from":"2024-01-15","to":"2024-01-16","time_zone":"Europe/Moscow",
+			"duration":30,"buffer":10,"working_hours":{"start":"10:00","end":"13:00"},"limit":2}`
+		req, _ := http.NewRequest(http.MethodPost, "/scheduling/slots", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupRouter(New(mockService)).ServeHTTP(w, req)
+
+		// свободно с 11:55 до 13:00: слот в 12:15 оставил бы по 15 минут с обеих сторон
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"result":[
+			{"start":"2024-01-15T12:00:00+03:00","end":"2024-01-15T12:30:00+03:00"},
+			{"start":"2024-01-15T12:30:00+03:00","end":"2024-01-15T13:00:00+03:00"}
+		]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for body, code := range map[string]string{
+			`{"user_ids":[1],"from":"2024-01-15","to":"2024-01-16"}`:                                                               "validation_failed",
+			`{"user_ids":[1],"from":"2024-01-15","to":"2024-01-16","duration":30,"working_days":["funday"]}`:                       "validation_failed",
+			`{"user_ids":[1],"from":"2024-01-15","to":"2024-01-16","duration":30,"working_hours":{"start":"9am"}}`:                 "validation_failed",
+			`{"user_ids":[1],"from":"2024-01-15","to":"2024-01-16","duration":30,"working_hours":{"start":"18:00","end":"09:00"}}`: "invalid_working_hours",
+			`{"user_ids":[1],"from":"2024-01-16","to":"2024-01-15","duration":30}`:                                                 "invalid_range",
+		} {
+			req, _ := http.NewRequest(http.MethodPost, "/scheduling/slots", bytes.NewBufferString(body))
+			w := httptest.NewRecorder()
+			setupRouter(New(new(MockEventsService))).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), `"code":"`+code+`"`, body)
+		}
+	})
+}
+
+// слоты по занятости из настоящего сервиса: интервалы участников пересекаются
+func TestFindSlotsWithService(t *testing.T) {
+	s := service.New(repository.New())
+	for _, userId := range []int{1, 2, 3} {
+		_, err := s.CreateUser(userId, model.User{UserId: userId, Name: "User", Email: fmt.Sprintf("user%d@example.com", userId)})
+		require.NoError(t, err)
+	}
+
+	// ближайший понедельник не раньше чем через неделю
+	monday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	at := func(hour, minute int) model.Date {
+		return model.Date(monday.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute))
+	}
+
+	for _, event := range []model.Event{
+		{UserId: 1, Text: "Standup", Start: at(9, 0), End: at(10, 30)},
+		{UserId: 2, Text: "Review", Start: at(10, 0), End: at(11, 15)},
+		{UserId: 2, Text: "Focus", Start: at(11, 15), End: at(12, 0), Transparency: model.TransparencyTransparent},
+		{UserId: 3, Text: "Interview", Start: at(11, 0), End: at(12, 0)},
+		{UserId: 3, Text: "Lunch", Start: at(12, 30), End: at(13, 30)},
+	} {
+		_, err := s.CreateEvent(event.UserId, event)
+		require.NoError(t, err)
+	}
+
+	date := monday.Format(time.DateOnly)
+	body := `{"user_ids":[1,2,3],"from":"` + date + `","to":"` + monday.AddDate(0, 0, 1).Format(time.DateOnly) + `",
+		"duration":30,"working_hours":{"start":"09:00","end":"15:00"},"limit":3}`
+	req, _ := http.NewRequest(http.MethodPost, "/scheduling/slots", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	setupRouter(New(s)).ServeHTTP(w, req)
+
+	// занято 9:00-12:00 подряд у разных участников и 12:30-13:30, прозрачное событие не мешает
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"result":[
+		{"start":"`+date+`T12:00:00Z","end":"`+date+`T12:30:00Z"},
+		{"start":"`+date+`T13:30:00Z","end":"`+date+`T14:00:00Z"},
+		{"start":"`+date+`T14:00:00Z","end":"`+date+`T14:30:00Z"}
+	]}`, w.Body.String())
+}
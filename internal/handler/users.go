@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Обработчики пользователей /v2/users
+
+// CreateUserV2 регистрирует пользователя и возвращает 201 с адресом в Location.
+// Без user_id в теле регистрируется аутентифицированный пользователь запроса, а
+// администратору и без аутентификации выдается новый id.
+func (h *Handler) CreateUserV2(c *gin.Context) {
+	var user model.User
+	if !bindJSON(c, &user) {
+		return
+	}
+
+	if identity, ok := middleware.CurrentIdentity(c); ok && user.UserId == 0 && !identity.HasScope(auth.ScopeAdmin) {
+		user.UserId = identity.UserId
+	}
+
+	if !Validate(c, user) {
+		return
+	}
+
+	user, err := h.service.CreateUser(middleware.Actor(c, user.UserId), user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", userLocation(user.UserId))
+	c.JSON(http.StatusCreated, map[string]model.User{"result": user})
+}
+
+func (h *Handler) GetUserV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(middleware.Actor(c, userId), userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.User{"result": user})
+}
+
+// ReplaceUserV2 заменяет имя, почту, зону, язык и первый день недели
+func (h *Handler) ReplaceUserV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var user model.User
+	if !bindJSON(c, &user) {
+		return
+	}
+	user.UserId = userId
+
+	if !Validate(c, user) {
+		return
+	}
+
+	user, err := h.service.UpdateUser(middleware.Actor(c, userId), user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]model.User{"result": user})
+}
+
+// DeleteUserV2 отключает пользователя, его календари остаются в архиве. С cascade=true
+// пользователь удаляется вместе с календарями, событиями и доступами.
+func (h *Handler) DeleteUserV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	cascade, err := strconv.ParseBool(c.DefaultQuery("cascade", "false"))
+	if err != nil {
+		c.Error(apperror.Validation("invalid_cascade", "cascade must be true or false"))
+		return
+	}
+
+	if err := h.service.DeleteUser(middleware.Actor(c, userId), userId, cascade); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func userLocation(userId int) string {
+	return fmt.Sprintf("/v2/users/%d", userId)
+}
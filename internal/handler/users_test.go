@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUsersV2(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("CreateUser", 0, mock.MatchedBy(func(u model.User) bool {
+			return u.Name == "Alice" && u.WeekStart == model.WeekStartSunday
+		})).Return(model.User{UserId: 4, Name: "Alice", Email: "alice@example.com"}, nil)
+
+		body := `{"name":"Alice","email":"alice@example.com","locale":"en-US","week_start":"sunday"}`
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/v2/users/4", w.Header().Get("Location"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Register authenticated user", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("CreateUser", 8, mock.MatchedBy(func(u model.User) bool {
+			return u.UserId == 8
+		})).Return(model.User{UserId: 8, Name: "Bob", Email: "bob@example.com"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users", bytes.NewBufferString(`{"name":"Bob","email":"bob@example.com"}`))
+		req.Header.Set("X-Test-User", "8")
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Admin registers another user", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("CreateUser", 0, mock.MatchedBy(func(u model.User) bool {
+			return u.UserId == 0
+		})).Return(model.User{UserId: 9, Name: "Carol", Email: "carol@example.com"}, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users", bytes.NewBufferString(`{"name":"Carol","email":"carol@example.com"}`))
+		req.Header.Set("X-Test-User", "1")
+		req.Header.Set("X-Test-Scope", auth.ScopeAdmin)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/v2/users/9", w.Header().Get("Location"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		body := `{"email":"not-an-email","time_zone":"Mars/Olympus","locale":"??","week_start":"friday"}`
+		req, _ := http.NewRequest(http.MethodPost, "/v2/users", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(new(MockEventsService))).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		for _, field := range []string{"name", "email", "time_zone", "locale", "week_start"} {
+			assert.Contains(t, w.Body.String(), `"field":"`+field+`"`)
+		}
+	})
+
+	t.Run("Get unknown", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("GetUser", 9, 9).Return(model.User{}, repository.ErrNoSuchUser)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/9", nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"user_not_found"`)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		user := model.User{UserId: 4, Name: "Alice B.", Email: "alice@example.com", TimeZone: "Europe/Moscow"}
+		mockService.On("UpdateUser", 4, user).Return(user, nil)
+
+		body := `{"name":"Alice B.","email":"alice@example.com","time_zone":"Europe/Moscow"}`
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/4", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("DeleteUser", 4, 4, false).Return(nil)
+		mockService.On("DeleteUser", 4, 4, true).Return(nil)
+
+		for _, url := range []string{"/v2/users/4", "/v2/users/4?cascade=true"} {
+			req, _ := http.NewRequest(http.MethodDelete, url, nil)
+			w := httptest.NewRecorder()
+			setupV2Router(New(mockService)).ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+		}
+		mockService.AssertExpectations(t)
+
+		req, _ := http.NewRequest(http.MethodDelete, "/v2/users/4?cascade=maybe", nil)
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Обработчики ресурсного API /v2/users/:user_id/events. Пользователь и событие
+// задаются в пути.
+
+// ListEventsV2 возвращает события за период: from и to, либо date и period (day, week,
+// isoweek, month, quarter, year)
+func (h *Handler) ListEventsV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	if c.Query("date") == "" {
+		h.listInRange(c, userId)
+		return
+	}
+
+	switch c.DefaultQuery("period", "day") {
+	case "day":
+		h.listForDate(c, userId, h.service.GetEventsForDay)
+	case "week":
+		h.listForDate(c, userId, h.service.GetEventsForWeek)
+	case "isoweek":
+		h.listForDate(c, userId, h.service.GetEventsForISOWeek)
+	case "month":
+		h.listForDate(c, userId, h.service.GetEventsForMonth)
+	case "quarter":
+		h.listForDate(c, userId, h.service.GetEventsForQuarter)
+	case "year":
+		h.listForDate(c, userId, h.service.GetEventsForYear)
+	default:
+		c.Error(apperror.Validation("invalid_period", "invalid period, must be one of day, week, isoweek, month, quarter, year"))
+	}
+}
+
+func (h *Handler) GetEventV2(c *gin.Context) {
+	userId, eventId, ok := pathIds(c)
+	if !ok {
+		return
+	}
+
+	loc, ok := h.queryLocation(c, userId)
+	if !ok {
+		return
+	}
+
+	event, err := h.service.GetEvent(middleware.Actor(c, userId), userId, eventId, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	body, err := json.Marshal(map[string]model.Event{"result": event})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// представление зависит от зоны и от учетных данных читателя
+	tag := RepresentationETag(event, body)
+	c.Header("ETag", tag)
+	c.Header("Vary", "Authorization, X-API-Key")
+	if NotModified(c, tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// CreateEventV2 создает событие и возвращает 201 с адресом нового ресурса в Location
+func (h *Handler) CreateEventV2(c *gin.Context) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var event model.Event
+	if !bindJSON(c, &event) {
+		return
+	}
+	event.UserId = userId
+
+	if !Validate(c, event) {
+		return
+	}
+
+	event, err := h.service.CreateEvent(middleware.Actor(c, userId), event)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", eventLocation(event.UserId, event.EventId))
+	setETag(c, event)
+	c.JSON(http.StatusCreated, map[string]model.Event{"result": event})
+}
+
+// ReplaceEventV2 заменяет событие целиком: поля, которых нет в теле, сбрасываются
+func (h *Handler) ReplaceEventV2(c *gin.Context) {
+	userId, eventId, ok := pathIds(c)
+	if !ok {
+		return
+	}
+
+	occurrence, scope, ok := queryOccurrence(c)
+	if !ok {
+		return
+	}
+
+	versions, ok := IfMatch(c)
+	if !ok {
+		return
+	}
+
+	var event model.Event
+	if !bindJSON(c, &event) {
+		return
+	}
+	event.UserId = userId
+
+	// начало, которое уже прошло, не мешает заменить остальные поля, если оно не менялось
+	var except []string
+	if !time.Time(event.Start).After(time.Now()) {
+		original := occurrence
+		if original == nil {
+			stored, err := h.service.GetEvent(middleware.Actor(c, userId), userId, eventId, time.UTC)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			original = &stored.Start
+		}
+
+		if time.Time(event.Start).Equal(time.Time(*original)) {
+			except = append(except, "Start")
+		}
+	}
+	if !Validate(c, event, except...) {
+		return
+	}
+
+	updateEvent := replacement(userId, eventId, event)
+	updateEvent.Occurrence = occurrence
+	updateEvent.Scope = scope
+	updateEvent.IfMatch = versions
+
+	updated, err := h.service.UpdateEvent(middleware.Actor(c, userId), updateEvent)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, updated)
+	c.JSON(http.StatusOK, map[string]model.Event{"result": updated})
+}
+
+// PatchEventV2 меняет только переданные поля. Кроме application/json принимает
+// application/merge-patch+json и application/json-patch+json, см. patchEvent
+func (h *Handler) PatchEventV2(c *gin.Context) {
+	userId, eventId, ok := pathIds(c)
+	if !ok {
+		return
+	}
+
+	if contentType := c.ContentType(); contentType == mergePatchType || contentType == jsonPatchType {
+		h.patchEvent(c, userId, eventId, contentType)
+		return
+	}
+
+	updateEvent, ok := bindUpdate(c, userId, eventId)
+	if !ok {
+		return
+	}
+
+	event, err := h.service.UpdateEvent(middleware.Actor(c, userId), updateEvent)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, event)
+	c.JSON(http.StatusOK, map[string]model.Event{"result": event})
+}
+
+func (h *Handler) DeleteEventV2(c *gin.Context) {
+	userId, eventId, ok := pathIds(c)
+	if !ok {
+		return
+	}
+
+	deleteEvent, ok := deleteRequest(c, userId, eventId)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteEvent(middleware.Actor(c, userId), deleteEvent); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// изменение, заменяющее все редактируемые поля события значениями из event
+func replacement(userId, eventId int, event model.Event) model.UpdateEvent {
+	event.Normalize()
+
+	updateEvent := model.UpdateEvent{
+		EventId:      &eventId,
+		UserId:       &userId,
+		Text:         &event.Text,
+		Start:        &event.Start,
+		End:          &event.End,
+		AllDay:       &event.AllDay,
+		Recurrence:   &event.Recurrence,
+		TimeZone:     &event.TimeZone,
+		Transparency: &event.Transparency,
+	}
+
+	// без calendar_id событие остается в своем календаре
+	if event.CalendarId != 0 {
+		updateEvent.CalendarId = &event.CalendarId
+	}
+
+	// без attendees участники остаются прежними, пустой список убирает всех.
+	// patchEvent передает пустой список сам: у патча отсутствие поля означает сброс
+	if event.Attendees != nil {
+		updateEvent.Attendees = &event.Attendees
+	}
+
+	return updateEvent
+}
+
+func eventLocation(userId, eventId int) string {
+	return fmt.Sprintf("/v2/users/%d/events/%d", userId, eventId)
+}
+
+// id из параметра пути name
+func pathId(c *gin.Context, name string) (int, bool) {
+	id, err := parseId(c.Param(name))
+	if err != nil {
+		c.Error(apperror.Validation("invalid_"+name, "invalid "+name))
+		return 0, false
+	}
+
+	return id, true
+}
+
+func pathIds(c *gin.Context) (int, int, bool) {
+	userId, ok := pathId(c, "user_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	eventId, ok := pathId(c, "event_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	return userId, eventId, true
+}
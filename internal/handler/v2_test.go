@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/middleware"
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupV2Router(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware())
+	router.Use(testIdentity)
+	router.POST("/v2/users", h.CreateUserV2)
+	router.GET("/v2/users/:user_id", h.GetUserV2)
+	router.PUT("/v2/users/:user_id", h.ReplaceUserV2)
+	router.DELETE("/v2/users/:user_id", h.DeleteUserV2)
+	events := router.Group("/v2/users/:user_id/events")
+	events.GET("", h.ListEventsV2)
+	events.POST("", h.CreateEventV2)
+	events.GET("/:event_id", h.GetEventV2)
+	events.PUT("/:event_id", h.ReplaceEventV2)
+	events.PATCH("/:event_id", h.PatchEventV2)
+	events.DELETE("/:event_id", h.DeleteEventV2)
+	events.POST("/import", h.ImportEventsV2)
+	router.GET("/v2/users/:user_id/calendar.ics", h.ExportCalendarV2)
+	router.GET("/v2/users/:user_id/invitations", h.ListInvitationsV2)
+	router.PUT("/v2/users/:user_id/invitations/:event_id", h.RespondInvitationV2)
+	calendars := router.Group("/v2/users/:user_id/calendars")
+	calendars.GET("", h.ListCalendarsV2)
+	calendars.POST("", h.CreateCalendarV2)
+	calendars.GET("/:calendar_id", h.GetCalendarV2)
+	calendars.PUT("/:calendar_id", h.ReplaceCalendarV2)
+	calendars.DELETE("/:calendar_id", h.DeleteCalendarV2)
+	calendars.GET("/:calendar_id/acl", h.ListACLV2)
+	calendars.PUT("/:calendar_id/acl/:grantee_id", h.PutACLV2)
+	calendars.DELETE("/:calendar_id/acl/:grantee_id", h.DeleteACLV2)
+	return router
+}
+
+func TestCreateEventV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	start := model.Date(time.Now().Add(48 * time.Hour))
+	mockService.On("CreateEvent", mock.Anything, mock.MatchedBy(func(e model.Event) bool {
+		return e.UserId == 7 && e.Text == "Meeting"
+	})).Return(model.Event{EventId: 42, UserId: 7, Text: "Meeting", Start: start}, nil)
+
+	// user_id берется из пути, а не из тела
+	body, _ := json.Marshal(map[string]any{"user_id": 1, "text": "Meeting", "start": start})
+	req, _ := http.NewRequest(http.MethodPost, "/v2/users/7/events", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v2/users/7/events/42", w.Header().Get("Location"))
+	mockService.AssertExpectations(t)
+}
+
+func TestGetEventV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(model.Event{EventId: 42, UserId: 1, Text: "Meeting"}, nil)
+	mockService.On("GetEvent", 1, 1, 43, time.UTC).Return(model.Event{}, repository.ErrNoSuchEvent)
+
+	t.Run("Found", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events/42", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]model.Event
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Meeting", response["result"].Text)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events/43", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+		var problem middleware.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, middleware.Problem{
+			Type:     "about:blank",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "no such event in database",
+			Instance: "/v2/users/1/events/43",
+			Code:     "event_not_found",
+		}, problem)
+	})
+
+	t.Run("Invalid id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_event_id"`)
+	})
+
+	t.Run("Internal error details are hidden", func(t *testing.T) {
+		mockService.On("GetEvent", 1, 1, 44, time.UTC).Return(model.Event{}, errors.New("disk is on fire"))
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events/44", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"internal"`)
+		assert.NotContains(t, w.Body.String(), "disk is on fire")
+	})
+}
+
+func TestReplaceEventV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	start := model.Date(time.Now().Add(48 * time.Hour).Truncate(time.Second))
+	mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+		// поля, которых нет в теле, сбрасываются, конец мгновенного события равен началу
+		return *u.UserId == 1 && *u.EventId == 42 && *u.Text == "Replaced" &&
+			*u.Recurrence == "" && *u.AllDay == false && time.Time(*u.End).Equal(time.Time(start))
+	})).Return(model.Event{EventId: 42, UserId: 1, Text: "Replaced"}, nil)
+
+	body, _ := json.Marshal(map[string]any{"text": "Replaced", "start": start})
+	req, _ := http.NewRequest(http.MethodPut, "/v2/users/1/events/42", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestReplaceEventV2_PastStart(t *testing.T) {
+	start := model.Date(time.Date(2020, 1, 15, 10, 0, 0, 0, time.UTC)) // уже прошло
+	stored := model.Event{EventId: 42, UserId: 1, Text: "Standup", Start: start, End: start}
+
+	send := func(mockService *MockEventsService, start model.Date) *httptest.ResponseRecorder {
+		mockService.On("GetEvent", 1, 1, 42, time.UTC).Return(stored, nil)
+
+		body, _ := json.Marshal(map[string]any{"text": "Replaced", "start": start})
+		req, _ := http.NewRequest(http.MethodPut, "/v2/users/1/events/42", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		setupV2Router(New(mockService)).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unchanged start", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+			return *u.Text == "Replaced" && time.Time(*u.Start).Equal(time.Time(start))
+		})).Return(model.Event{EventId: 42, UserId: 1, Text: "Replaced"}, nil)
+
+		w := send(mockService, start)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Start moved into the past", func(t *testing.T) {
+		mockService := new(MockEventsService)
+		w := send(mockService, model.Date(time.Time(start).Add(time.Hour)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"rule":"date_after_now"`)
+		mockService.AssertNotCalled(t, "UpdateEvent", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPatchEventV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+		return *u.UserId == 1 && *u.EventId == 42 && *u.Text == "Patched" && u.Start == nil && u.Recurrence == nil
+	})).Return(model.Event{EventId: 42, UserId: 1, Text: "Patched"}, nil)
+	mockService.On("UpdateEvent", mock.Anything, mock.MatchedBy(func(u model.UpdateEvent) bool {
+		return *u.EventId == 43
+	})).Return(model.Event{}, repository.ErrNoSuchEvent)
+
+	t.Run("Success", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPatch, "/v2/users/1/events/42", bytes.NewBufferString(`{"text": "Patched"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPatch, "/v2/users/1/events/43", bytes.NewBufferString(`{"text": "Patched"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestDeleteEventV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 42}).Return(nil)
+	mockService.On("DeleteEvent", mock.Anything, model.DeleteEvent{UserId: 1, EventId: 43}).Return(repository.ErrNoSuchEvent)
+
+	t.Run("Success", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, "/v2/users/1/events/42", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, "/v2/users/1/events/43", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestListEventsV2(t *testing.T) {
+	mockService := new(MockEventsService)
+	router := setupV2Router(New(mockService))
+
+	mockService.On("Location", 1, "").Return(time.UTC, nil)
+	mockService.On("Location", 2, "").Return(time.UTC, nil)
+
+	t.Run("Range", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		mockService.On("GetEventsInRange", 1, 1, from, to).Return([]*model.Event{{EventId: 1, UserId: 1}}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events?from=2024-01-01&to=2024-02-01", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Week by date", func(t *testing.T) {
+		date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		mockService.On("GetEventsForWeek", 1, 1, date).Return([]*model.Event{}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events?date=2024-01-10&period=week", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Quarter, year and ISO week by date", func(t *testing.T) {
+		date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		mockService.On("GetEventsForQuarter", 1, 1, date).Return([]*model.Event{}, nil)
+		mockService.On("GetEventsForYear", 1, 1, date).Return([]*model.Event{}, nil)
+		mockService.On("GetEventsForISOWeek", 1, 1, date).Return([]*model.Event{}, nil)
+
+		for _, period := range []string{"quarter", "year", "isoweek"} {
+			req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events?date=2024-01-10&period="+period, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, period)
+		}
+	})
+
+	t.Run("Invalid period", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/1/events?date=2024-01-10&period=decade", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		mockService.On("GetEventsForDay", 2, 2, date).Return([]*model.Event(nil), repository.ErrNoSuchUser)
+
+		req, _ := http.NewRequest(http.MethodGet, "/v2/users/2/events?date=2024-01-10", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,229 @@
+// Package ical формирует календарь в формате iCalendar (RFC 5545) из событий.
+// Отдельное событие и серия становятся VEVENT, измененные экземпляры серии -
+// VEVENT с тем же UID и RECURRENCE-ID. Экземпляр, развернутый из серии при
+// выборке за период, выгружается как отдельное событие со своим UID.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	prodId    = "-//Komilov31//calendar-service//RU"
+	uidDomain = "calendar-service"
+
+	// максимальная длина строки в октетах без CRLF
+	maxLineLength = 75
+
+	utcLayout   = "20060102T150405Z"
+	localLayout = "20060102T150405"
+	dateLayout  = "20060102"
+)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "")
+
+// Calendar - календарь для выгрузки. Name показывается клиентами как название
+// календаря (X-WR-CALNAME), может быть пустым.
+type Calendar struct {
+	Name   string
+	Events []*model.Event
+}
+
+// Marshal возвращает календарь в формате iCalendar со строками, разделенными CRLF
+func Marshal(cal Calendar) []byte {
+	w := &writer{}
+
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodId)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		w.line("X-WR-CALNAME:" + escapeText(cal.Name))
+	}
+
+	for _, tz := range timezones(cal.Events) {
+		w.timezone(tz)
+	}
+
+	for _, event := range cal.Events {
+		w.event(event)
+	}
+
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// UID события не меняется при его изменениях: у импортированного события это
+// UID из исходного календаря, у остальных он строится по id. У развернутого
+// экземпляра UID строится по id серии и исходному началу.
+func UID(event *model.Event) string {
+	if event.RecurrenceId != nil {
+		return fmt.Sprintf("%d-%s@%s", event.EventId, time.Time(*event.RecurrenceId).UTC().Format(utcLayout), uidDomain)
+	}
+
+	if event.UID != "" {
+		return event.UID
+	}
+
+	return fmt.Sprintf("%d@%s", event.EventId, uidDomain)
+}
+
+// EventId возвращает id события из UID, построенного по id. Импортированное
+// событие может иметь такой же UID, поэтому найденное событие нужно сверить с UID.
+func EventId(uid string) (int, bool) {
+	value, ok := strings.CutSuffix(uid, "@"+uidDomain)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) event(event *model.Event) {
+	w.component(event, UID(event), nil)
+
+	// измененные экземпляры серии идут отдельными компонентами с UID серии
+	for _, override := range event.Overrides {
+		instance := *override
+		instance.TimeZone, instance.AllDay, instance.Version = event.TimeZone, event.AllDay, event.Version
+		w.component(&instance, UID(event), override.RecurrenceId)
+	}
+}
+
+func (w *writer) component(event *model.Event, uid string, recurrenceId *model.Date) {
+	loc := location(event)
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + uid)
+	w.line("DTSTAMP:" + stamp(event).Format(utcLayout))
+	if !event.CreatedAt.IsZero() {
+		w.line("CREATED:" + time.Time(event.CreatedAt).UTC().Format(utcLayout))
+	}
+	if !event.UpdatedAt.IsZero() {
+		w.line("LAST-MODIFIED:" + time.Time(event.UpdatedAt).UTC().Format(utcLayout))
+	}
+	if event.Version > 1 {
+		w.line("SEQUENCE:" + strconv.Itoa(event.Version-1))
+	}
+
+	w.line("DTSTART" + dateTime(time.Time(event.Start), event.AllDay, loc))
+	// у события нулевой длительности DTEND не указывается (RFC 5545, 3.6.1)
+	if time.Time(event.End).After(time.Time(event.Start)) {
+		w.line("DTEND" + dateTime(time.Time(event.End), event.AllDay, loc))
+	}
+
+	if recurrenceId != nil {
+		w.line("RECURRENCE-ID" + dateTime(time.Time(*recurrenceId), event.AllDay, loc))
+	} else if event.IsRecurring() && event.RecurrenceId == nil {
+		w.line("RRULE:" + recurrenceRule(event))
+		for _, exdate := range event.ExDates {
+			w.line("EXDATE" + dateTime(time.Time(exdate), event.AllDay, loc))
+		}
+	}
+
+	w.line("SUMMARY:" + escapeText(event.Text))
+	if event.Transparency == model.TransparencyTransparent {
+		w.line("TRANSP:TRANSPARENT")
+	}
+	w.line("END:VEVENT")
+}
+
+// line пишет строку содержимого, складывая ее по 75 октетов: продолжение
+// начинается с пробела, многобайтные символы UTF-8 не разрываются
+func (w *writer) line(s string) {
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		limit = maxLineLength - 1
+	}
+
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
+
+// dateTime - параметры и значение DTSTART, DTEND и подобных свойств вместе с ":".
+// Событие на весь день задается датой, событие в зоне - местным временем с TZID,
+// остальные - временем в UTC.
+func dateTime(t time.Time, allDay bool, loc *time.Location) string {
+	switch {
+	case allDay:
+		return ";VALUE=DATE:" + t.Format(dateLayout)
+	case loc != nil:
+		return ";TZID=" + loc.String() + ":" + t.In(loc).Format(localLayout)
+	default:
+		return ":" + t.UTC().Format(utcLayout)
+	}
+}
+
+// зона события для TZID, nil - время выгружается в UTC
+func location(event *model.Event) *time.Location {
+	if event.AllDay || event.TimeZone == "" || event.TimeZone == "UTC" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(event.TimeZone)
+	if err != nil {
+		return nil
+	}
+
+	return loc
+}
+
+// правило в каноническом виде. UNTIL серии на весь день должен быть датой,
+// как и ее DTSTART
+func recurrenceRule(event *model.Event) string {
+	rule, err := rrule.Parse(event.Recurrence)
+	if err != nil {
+		return strings.TrimPrefix(event.Recurrence, "RRULE:")
+	}
+
+	if event.AllDay && !rule.Until.IsZero() {
+		until := rule.Until
+		rule.Until = time.Time{}
+		return rule.String() + ";UNTIL=" + until.Format(dateLayout)
+	}
+
+	return rule.String()
+}
+
+// DTSTAMP - время последнего изменения события, чтобы выгрузка не менялась без изменений
+func stamp(event *model.Event) time.Time {
+	switch {
+	case !event.UpdatedAt.IsZero():
+		return time.Time(event.UpdatedAt).UTC()
+	case !event.CreatedAt.IsZero():
+		return time.Time(event.CreatedAt).UTC()
+	default:
+		return time.Now().UTC()
+	}
+}
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
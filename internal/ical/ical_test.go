@@ -0,0 +1,202 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unfold собирает сложенные строки обратно (RFC 5545, 3.1)
+func unfold(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+// свойства компонентов VEVENT по порядку
+func vevents(data []byte) [][]string {
+	var (
+		result  [][]string
+		current []string
+	)
+	for _, line := range unfold(data) {
+		switch line {
+		case "BEGIN:VEVENT":
+			current = []string{}
+		case "END:VEVENT":
+			result = append(result, current)
+			current = nil
+		default:
+			if current != nil {
+				current = append(current, line)
+			}
+		}
+	}
+
+	return result
+}
+
+func TestMarshal(t *testing.T) {
+	updated := model.Date(time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC))
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	t.Run("Timed event in UTC", func(t *testing.T) {
+		data := Marshal(Calendar{Events: []*model.Event{{
+			EventId:   1,
+			Text:      "Meeting",
+			Start:     model.Date(time.Date(2025, 8, 18, 13, 0, 0, 0, time.FixedZone("MSK", 3*3600))),
+			End:       model.Date(time.Date(2025, 8, 18, 14, 0, 0, 0, time.FixedZone("MSK", 3*3600))),
+			CreatedAt: updated,
+			UpdatedAt: updated,
+			Version:   3,
+		}}})
+
+		assert.True(t, strings.HasPrefix(string(data), "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:"))
+		assert.True(t, strings.HasSuffix(string(data), "END:VCALENDAR\r\n"))
+		assert.Equal(t, [][]string{{
+			"UID:1@calendar-service",
+			"DTSTAMP:20250801T120000Z",
+			"CREATED:20250801T120000Z",
+			"LAST-MODIFIED:20250801T120000Z",
+			"SEQUENCE:2",
+			"DTSTART:20250818T100000Z",
+			"DTEND:20250818T110000Z",
+			"SUMMARY:Meeting",
+		}}, vevents(data))
+	})
+
+	t.Run("All-day event uses dates", func(t *testing.T) {
+		data := Marshal(Calendar{Events: []*model.Event{{
+			EventId:   2,
+			Text:      "Vacation",
+			AllDay:    true,
+			TimeZone:  "Europe/Berlin",
+			Start:     model.Date(time.Date(2025, 8, 20, 0, 0, 0, 0, berlin)),
+			End:       model.Date(time.Date(2025, 8, 22, 0, 0, 0, 0, berlin)),
+			UpdatedAt: updated,
+		}}})
+
+		event := vevents(data)[0]
+		assert.Contains(t, event, "DTSTART;VALUE=DATE:20250820")
+		assert.Contains(t, event, "DTEND;VALUE=DATE:20250822")
+		assert.NotContains(t, string(data), "VTIMEZONE")
+	})
+
+	t.Run("Zero duration event has no DTEND", func(t *testing.T) {
+		start := model.Date(time.Date(2025, 8, 18, 10, 0, 0, 0, time.UTC))
+		data := Marshal(Calendar{Events: []*model.Event{{EventId: 3, Text: "Reminder", Start: start, End: start, UpdatedAt: updated}}})
+
+		assert.NotContains(t, string(data), "DTEND")
+	})
+
+	t.Run("Series with exceptions", func(t *testing.T) {
+		moved := model.Date(time.Date(2025, 8, 20, 10, 0, 0, 0, berlin))
+		data := Marshal(Calendar{Events: []*model.Event{{
+			EventId:    4,
+			Text:       "Standup",
+			TimeZone:   "Europe/Berlin",
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20251231T000000Z",
+			Start:      model.Date(time.Date(2025, 8, 18, 10, 0, 0, 0, berlin)),
+			End:        model.Date(time.Date(2025, 8, 18, 10, 15, 0, 0, berlin)),
+			ExDates:    []model.Date{model.Date(time.Date(2025, 8, 25, 10, 0, 0, 0, berlin))},
+			Overrides: []*model.Event{{
+				EventId:      4,
+				Text:         "Standup, later",
+				RecurrenceId: &moved,
+				Start:        model.Date(time.Date(2025, 8, 20, 11, 0, 0, 0, berlin)),
+				End:          model.Date(time.Date(2025, 8, 20, 11, 15, 0, 0, berlin)),
+			}},
+			UpdatedAt: updated,
+			Version:   2,
+		}}})
+
+		events := vevents(data)
+		require.Len(t, events, 2)
+		assert.Equal(t, []string{
+			"UID:4@calendar-service",
+			"DTSTAMP:20250801T120000Z",
+			"LAST-MODIFIED:20250801T120000Z",
+			"SEQUENCE:1",
+			"DTSTART;TZID=Europe/Berlin:20250818T100000",
+			"DTEND;TZID=Europe/Berlin:20250818T101500",
+			"RRULE:FREQ=WEEKLY;UNTIL=20251231T000000Z;BYDAY=MO,WE",
+			"EXDATE;TZID=Europe/Berlin:20250825T100000",
+			"SUMMARY:Standup",
+		}, events[0])
+		assert.Contains(t, events[1], "UID:4@calendar-service")
+		assert.Contains(t, events[1], "SEQUENCE:1")
+		assert.Contains(t, events[1], "RECURRENCE-ID;TZID=Europe/Berlin:20250820T100000")
+		assert.Contains(t, events[1], `SUMMARY:Standup\, later`)
+		assert.NotContains(t, events[1], "RRULE:FREQ=WEEKLY;UNTIL=20251231T000000Z;BYDAY=MO,WE")
+
+		lines := unfold(data)
+		assert.Contains(t, lines, "TZID:Europe/Berlin")
+		assert.Contains(t, lines, "DTSTART:20250330T020000")
+		assert.Contains(t, lines, "TZOFFSETFROM:+0100")
+		assert.Contains(t, lines, "TZOFFSETTO:+0200")
+		assert.Equal(t, 1, strings.Count(string(data), "BEGIN:VTIMEZONE"))
+	})
+
+	t.Run("All-day series has date UNTIL", func(t *testing.T) {
+		data := Marshal(Calendar{Events: []*model.Event{{
+			EventId:    5,
+			Text:       "Birthday",
+			AllDay:     true,
+			Recurrence: "FREQ=YEARLY;UNTIL=20300101",
+			Start:      model.Date(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+			End:        model.Date(time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)),
+			UpdatedAt:  updated,
+		}}})
+
+		assert.Contains(t, vevents(data)[0], "RRULE:FREQ=YEARLY;UNTIL=20300101")
+	})
+
+	t.Run("Occurrence of a series is a separate event", func(t *testing.T) {
+		original := model.Date(time.Date(2025, 8, 20, 8, 0, 0, 0, time.UTC))
+		data := Marshal(Calendar{Events: []*model.Event{{
+			EventId:      4,
+			Text:         "Standup",
+			Recurrence:   "FREQ=DAILY",
+			RecurrenceId: &original,
+			Start:        original,
+			End:          model.Date(time.Time(original).Add(15 * time.Minute)),
+			UpdatedAt:    updated,
+		}}})
+
+		event := vevents(data)[0]
+		assert.Equal(t, "UID:4-20250820T080000Z@calendar-service", event[0])
+		assert.NotContains(t, strings.Join(event, "\n"), "RRULE")
+		assert.NotContains(t, strings.Join(event, "\n"), "RECURRENCE-ID")
+	})
+}
+
+func TestEscapingAndFolding(t *testing.T) {
+	text := "Планирование; релиз, ретро\nи заметки \\ " + strings.Repeat("очень длинный текст ", 10)
+	data := Marshal(Calendar{Name: "Team", Events: []*model.Event{{
+		EventId:   1,
+		Text:      text,
+		Start:     model.Date(time.Date(2025, 8, 18, 10, 0, 0, 0, time.UTC)),
+		End:       model.Date(time.Date(2025, 8, 18, 11, 0, 0, 0, time.UTC)),
+		UpdatedAt: model.Date(time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)),
+	}}})
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+		assert.True(t, utf8.ValidString(line), line)
+	}
+
+	want := `SUMMARY:Планирование\; релиз\, ретро\nи заметки \\ ` + strings.Repeat("очень длинный текст ", 10)
+	assert.Contains(t, unfold(data), want)
+	assert.Contains(t, unfold(data), "X-WR-CALNAME:Team")
+}
+
+func TestFormatOffset(t *testing.T) {
+	assert.Equal(t, "+0530", formatOffset(5*3600+30*60))
+	assert.Equal(t, "-0800", formatOffset(-8*3600))
+	assert.Equal(t, "+0000", formatOffset(0))
+	assert.Equal(t, "+003730", formatOffset(37*60+30))
+}
@@ -0,0 +1,451 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+var (
+	ErrInvalidCalendar = errors.New("invalid calendar")
+
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+// Component - разобранный VEVENT. Line - номер строки BEGIN:VEVENT в файле. Если
+// компонент не удалось разобрать, Err содержит причину, а Event не заполнен.
+//
+// Измененный экземпляр серии (VEVENT с RECURRENCE-ID) переносится в Overrides
+// серии с тем же UID, а отмененный (STATUS:CANCELLED) - в ее ExDates. Master у
+// такого компонента - индекс серии в результате, у остальных -1.
+type Component struct {
+	UID       string
+	Line      int
+	Event     model.Event
+	Cancelled bool
+	Master    int
+	Err       error
+}
+
+// property - строка содержимого: имя, параметры и значение (RFC 5545, 3.1)
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+	line   int
+}
+
+// Parse разбирает события из VCALENDAR. Время без зоны (floating) трактуется
+// в зоне loc. TZID сначала ищется в базе IANA, затем среди VTIMEZONE файла.
+func Parse(r io.Reader, loc *time.Location) ([]Component, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stack    []string
+		calendar bool
+		event    []property
+		eventAt  int
+		events   [][]property
+		starts   []int
+		zones    = map[string]*vtimezone{}
+		zone     *vtimezone
+		rule     *observanceRule
+	)
+
+	for _, l := range lines {
+		prop, err := parseProperty(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, l.number, err)
+		}
+		prop.line = l.number
+
+		switch prop.name {
+		case "BEGIN":
+			name := strings.ToUpper(prop.value)
+			stack = append(stack, name)
+			switch {
+			case name == "VCALENDAR":
+				calendar = true
+			case name == "VEVENT" && len(stack) == 2:
+				event, eventAt = nil, l.number
+			case name == "VTIMEZONE" && len(stack) == 2:
+				zone = &vtimezone{}
+			case (name == "STANDARD" || name == "DAYLIGHT") && zone != nil:
+				rule = &observanceRule{}
+			}
+			continue
+		case "END":
+			name := strings.ToUpper(prop.value)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, l.number, prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			switch {
+			case name == "VEVENT" && len(stack) == 1:
+				events, starts = append(events, event), append(starts, eventAt)
+			case name == "VTIMEZONE" && zone != nil:
+				if zone.id != "" {
+					zones[zone.id] = zone
+				}
+				zone = nil
+			case (name == "STANDARD" || name == "DAYLIGHT") && rule != nil:
+				zone.observances = append(zone.observances, *rule)
+				rule = nil
+			}
+			continue
+		}
+
+		switch {
+		case len(stack) == 2 && stack[1] == "VEVENT":
+			event = append(event, prop)
+		case len(stack) == 2 && stack[1] == "VTIMEZONE" && prop.name == "TZID":
+			zone.id = prop.value
+		case len(stack) == 3 && rule != nil:
+			rule.set(prop)
+		}
+	}
+
+	if !calendar {
+		return nil, fmt.Errorf("%w: no VCALENDAR", ErrInvalidCalendar)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: %s is not closed", ErrInvalidCalendar, stack[len(stack)-1])
+	}
+
+	p := parser{loc: loc, zones: zones}
+	components := make([]Component, 0, len(events))
+	for i, props := range events {
+		components = append(components, p.component(props, starts[i]))
+	}
+
+	attachExceptions(components)
+	return components, nil
+}
+
+type line struct {
+	text   string
+	number int
+}
+
+// unfoldLines склеивает сложенные строки: продолжение начинается с пробела или табуляции
+func unfoldLines(r io.Reader) ([]line, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []line
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, line{text: text, number: number})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCalendar, err)
+	}
+
+	return lines, nil
+}
+
+// parseProperty разбирает строку вида NAME;PARAM=value;PARAM="quoted:value":VALUE
+func parseProperty(s string) (property, error) {
+	prop := property{params: map[string]string{}}
+
+	end := strings.IndexAny(s, ";:")
+	if end <= 0 {
+		return property{}, fmt.Errorf("malformed line %q", s)
+	}
+	prop.name = strings.ToUpper(s[:end])
+	s = s[end:]
+
+	for strings.HasPrefix(s, ";") {
+		s = s[1:]
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return property{}, fmt.Errorf("malformed parameter in %s", prop.name)
+		}
+		key := strings.ToUpper(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			closing := strings.IndexByte(s[1:], '"')
+			if closing < 0 {
+				return property{}, fmt.Errorf("unterminated quote in %s", prop.name)
+			}
+			value, s = s[1:closing+1], s[closing+2:]
+		} else {
+			end := strings.IndexAny(s, ";:")
+			if end < 0 {
+				return property{}, fmt.Errorf("malformed parameter in %s", prop.name)
+			}
+			value, s = s[:end], s[end:]
+		}
+		prop.params[key] = value
+	}
+
+	if !strings.HasPrefix(s, ":") {
+		return property{}, fmt.Errorf("no value in %s", prop.name)
+	}
+	prop.value = s[1:]
+
+	return prop, nil
+}
+
+type parser struct {
+	loc   *time.Location
+	zones map[string]*vtimezone
+}
+
+func (p parser) component(props []property, start int) Component {
+	c := Component{Line: start, Master: -1}
+	if err := p.fill(&c, props); err != nil {
+		c.Err = err
+		c.Event = model.Event{}
+	}
+
+	return c
+}
+
+func (p parser) fill(c *Component, props []property) error {
+	event := &c.Event
+	var (
+		hasStart, hasEnd bool
+		duration         *time.Duration
+	)
+
+	for _, prop := range props {
+		var err error
+		switch prop.name {
+		case "UID":
+			c.UID = prop.value
+			event.UID = prop.value
+		case "SUMMARY":
+			event.Text = textUnescaper.Replace(prop.value)
+		case "DTSTART":
+			var start time.Time
+			start, event.AllDay, event.TimeZone, err = p.dateTime(prop)
+			event.Start, hasStart = model.Date(start), true
+		case "DTEND":
+			var end time.Time
+			end, _, _, err = p.dateTime(prop)
+			event.End, hasEnd = model.Date(end), true
+		case "DURATION":
+			var d time.Duration
+			d, err = parseDuration(prop.value)
+			duration = &d
+		case "RRULE":
+			event.Recurrence = prop.value
+		case "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				var exdate time.Time
+				exdate, _, _, err = p.dateTime(property{name: prop.name, params: prop.params, value: value})
+				if err != nil {
+					break
+				}
+				event.ExDates = append(event.ExDates, model.Date(exdate))
+			}
+		case "RECURRENCE-ID":
+			var original time.Time
+			original, _, _, err = p.dateTime(prop)
+			recurrenceId := model.Date(original)
+			event.RecurrenceId = &recurrenceId
+		case "TRANSP":
+			if strings.EqualFold(prop.value, "TRANSPARENT") {
+				event.Transparency = model.TransparencyTransparent
+			}
+		case "STATUS":
+			c.Cancelled = strings.EqualFold(prop.value, "CANCELLED")
+		}
+
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", prop.line, prop.name, err)
+		}
+	}
+
+	if !hasStart {
+		return errors.New("DTSTART is missing")
+	}
+
+	if !hasEnd && duration != nil {
+		start := time.Time(event.Start)
+		// длительность в днях у событий на весь день считается по календарю
+		if event.AllDay && *duration%(24*time.Hour) == 0 {
+			event.End = model.Date(start.AddDate(0, 0, int(*duration/(24*time.Hour))))
+		} else {
+			event.End = model.Date(start.Add(*duration))
+		}
+	}
+
+	return nil
+}
+
+// dateTime разбирает DATE (событие на весь день), DATE-TIME в UTC, с TZID или без
+// зоны. Возвращает имя зоны IANA, если TZID ей соответствует.
+func (p parser) dateTime(prop property) (time.Time, bool, string, error) {
+	value := prop.value
+
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, p.loc)
+		return t, true, "", err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, "", err
+	}
+
+	local, err := time.Parse(localLayout, value)
+	if err != nil {
+		return time.Time{}, false, "", err
+	}
+
+	tzid, ok := prop.params["TZID"]
+	if !ok {
+		return inZone(local, p.loc), false, "", nil
+	}
+
+	if loc, ok := loadLocation(tzid); ok {
+		return inZone(local, loc), false, loc.String(), nil
+	}
+
+	if zone, ok := p.zones[tzid]; ok {
+		return zone.in(local), false, "", nil
+	}
+
+	return time.Time{}, false, "", fmt.Errorf("unknown time zone %q", tzid)
+}
+
+// loadLocation ищет TZID в базе IANA. Некоторые клиенты добавляют к имени
+// префикс, например /mozilla.org/20050126_1/Europe/Berlin, его отбрасываем.
+func loadLocation(tzid string) (*time.Location, bool) {
+	name := strings.Trim(tzid, "/")
+	for name != "" {
+		if loc, err := time.LoadLocation(name); err == nil && name != "Local" {
+			return loc, true
+		}
+
+		_, rest, found := strings.Cut(name, "/")
+		if !found {
+			break
+		}
+		name = rest
+	}
+
+	return nil, false
+}
+
+// настенное время local (разобранное в UTC) в зоне loc
+func inZone(local time.Time, loc *time.Location) time.Time {
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, loc)
+}
+
+// parseDuration разбирает длительность RFC 5545: [+-]P[nW] или [+-]P[nD][T[nH][nM][nS]]
+func parseDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+
+	var (
+		total     time.Duration
+		number    string
+		inTime    bool
+		timeUnits int
+	)
+	for i := 0; i < len(rest); i++ {
+		ch := rest[i]
+		switch {
+		case ch >= '0' && ch <= '9':
+			number += string(ch)
+		case ch == 'T' && !inTime && number == "":
+			inTime = true
+		default:
+			unit, ok := units[ch]
+			// H, M и S допустимы только после T, W и D - только до него
+			if !ok || number == "" || inTime != (ch == 'H' || ch == 'M' || ch == 'S') {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			total += time.Duration(n) * unit
+			number = ""
+			if inTime {
+				timeUnits++
+			}
+		}
+	}
+
+	if number != "" || (inTime && timeUnits == 0) {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return sign * total, nil
+}
+
+// attachExceptions переносит измененные и отмененные экземпляры в серии с тем же UID
+func attachExceptions(components []Component) {
+	masters := map[string]int{}
+	for i, c := range components {
+		if _, ok := masters[c.UID]; !ok && c.Event.RecurrenceId == nil && c.UID != "" {
+			masters[c.UID] = i
+		}
+	}
+
+	for i := range components {
+		c := &components[i]
+		if c.Err != nil || c.Event.RecurrenceId == nil {
+			continue
+		}
+
+		master, ok := masters[c.UID]
+		switch {
+		case ok && components[master].Err != nil:
+			c.Err = errors.New("recurring event with the same UID is invalid")
+			continue
+		case !ok || !components[master].Event.IsRecurring():
+			c.Err = errors.New("RECURRENCE-ID without a recurring event with the same UID")
+			continue
+		}
+		c.Master = master
+
+		series := &components[master].Event
+		if c.Cancelled {
+			series.ExDates = append(series.ExDates, *c.Event.RecurrenceId)
+			continue
+		}
+
+		override := c.Event
+		override.UID = ""
+		series.Overrides = append(series.Overrides, &override)
+	}
+}
@@ -0,0 +1,255 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func calendar(lines ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+}
+
+func TestParse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	t.Run("Timed, all-day and floating events", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VEVENT",
+			"UID:a@example.com",
+			`SUMMARY:Planning\, Q3\; room 5\nbring laptops`,
+			"DTSTART:20240115T100000Z",
+			"DTEND:20240115T110000Z",
+			"BEGIN:VALARM",
+			"TRIGGER:-PT15M",
+			"END:VALARM",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:b@example.com",
+			"SUMMARY:Vacation",
+			"DTSTART;VALUE=DATE:20240301",
+			"DURATION:P3D",
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:c@example.com",
+			"SUMMARY:Floating",
+			"DTSTART:20240115T090000",
+			"DURATION:PT1H30M",
+			"END:VEVENT",
+		)), berlin)
+		require.NoError(t, err)
+		require.Len(t, components, 3)
+
+		timed := components[0]
+		assert.NoError(t, timed.Err)
+		assert.Equal(t, 3, timed.Line)
+		assert.Equal(t, "a@example.com", timed.UID)
+		assert.Equal(t, "Planning, Q3; room 5\nbring laptops", timed.Event.Text)
+		assert.True(t, time.Time(timed.Event.Start).Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)))
+		assert.True(t, time.Time(timed.Event.End).Equal(time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)))
+		assert.False(t, timed.Event.AllDay)
+
+		allDay := components[1].Event
+		assert.True(t, allDay.AllDay)
+		assert.Equal(t, "2024-03-01", time.Time(allDay.Start).Format(time.DateOnly))
+		assert.Equal(t, "2024-03-04", time.Time(allDay.End).Format(time.DateOnly))
+		assert.Equal(t, model.TransparencyTransparent, allDay.Transparency)
+		assert.Empty(t, components[0].Event.Transparency)
+
+		floating := components[2].Event
+		assert.True(t, time.Time(floating.Start).Equal(time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)))
+		assert.True(t, time.Time(floating.End).Equal(time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)))
+	})
+
+	t.Run("Folded lines", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VEVENT",
+			"UID:folded@example.com",
+			"SUMMARY:Очень длинное наз",
+			" вание события",
+			"DTSTART:20240115T100000Z",
+			"END:VEVENT",
+		)), time.UTC)
+		require.NoError(t, err)
+		assert.Equal(t, "Очень длинное название события", components[0].Event.Text)
+	})
+
+	t.Run("TZID from IANA with prefix", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VEVENT",
+			"UID:tz@example.com",
+			"SUMMARY:Standup",
+			`DTSTART;TZID="/mozilla.org/20050126_1/Europe/Berlin":20240715T100000`,
+			"DTEND;TZID=Europe/Berlin:20240715T101500",
+			"RRULE:FREQ=WEEKLY;BYDAY=MO",
+			"EXDATE;TZID=Europe/Berlin:20240722T100000,20240729T100000",
+			"END:VEVENT",
+		)), time.UTC)
+		require.NoError(t, err)
+
+		event := components[0].Event
+		assert.Equal(t, "Europe/Berlin", event.TimeZone)
+		assert.True(t, time.Time(event.Start).Equal(time.Date(2024, 7, 15, 8, 0, 0, 0, time.UTC)))
+		assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", event.Recurrence)
+		require.Len(t, event.ExDates, 2)
+		assert.True(t, time.Time(event.ExDates[1]).Equal(time.Date(2024, 7, 29, 8, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("TZID from VTIMEZONE", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VTIMEZONE",
+			"TZID:W. Europe Standard Time",
+			"BEGIN:STANDARD",
+			"DTSTART:16010101T030000",
+			"TZOFFSETFROM:+0200",
+			"TZOFFSETTO:+0100",
+			"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10",
+			"END:STANDARD",
+			"BEGIN:DAYLIGHT",
+			"DTSTART:16010101T020000",
+			"TZOFFSETFROM:+0100",
+			"TZOFFSETTO:+0200",
+			"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3",
+			"END:DAYLIGHT",
+			"END:VTIMEZONE",
+			"BEGIN:VEVENT",
+			"UID:summer@example.com",
+			"SUMMARY:Summer",
+			"DTSTART;TZID=W. Europe Standard Time:20240715T100000",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:winter@example.com",
+			"SUMMARY:Winter",
+			"DTSTART;TZID=W. Europe Standard Time:20240115T100000",
+			"END:VEVENT",
+		)), time.UTC)
+		require.NoError(t, err)
+
+		assert.True(t, time.Time(components[0].Event.Start).Equal(time.Date(2024, 7, 15, 8, 0, 0, 0, time.UTC)))
+		assert.True(t, time.Time(components[1].Event.Start).Equal(time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)))
+		assert.Empty(t, components[0].Event.TimeZone)
+	})
+
+	t.Run("Exceptions are attached to the series", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VEVENT",
+			"UID:series@example.com",
+			"SUMMARY:Standup",
+			"DTSTART:20240115T090000Z",
+			"DTEND:20240115T091500Z",
+			"RRULE:FREQ=DAILY",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:series@example.com",
+			"RECURRENCE-ID:20240116T090000Z",
+			"SUMMARY:Standup (moved)",
+			"DTSTART:20240116T100000Z",
+			"DTEND:20240116T101500Z",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:series@example.com",
+			"RECURRENCE-ID:20240117T090000Z",
+			"STATUS:CANCELLED",
+			"DTSTART:20240117T090000Z",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:orphan@example.com",
+			"RECURRENCE-ID:20240117T090000Z",
+			"DTSTART:20240117T090000Z",
+			"END:VEVENT",
+		)), time.UTC)
+		require.NoError(t, err)
+		require.Len(t, components, 4)
+
+		series := components[0].Event
+		require.Len(t, series.Overrides, 1)
+		assert.Equal(t, "Standup (moved)", series.Overrides[0].Text)
+		assert.Equal(t, []model.Date{model.Date(time.Date(2024, 1, 17, 9, 0, 0, 0, time.UTC))}, series.ExDates)
+
+		assert.Equal(t, 0, components[1].Master)
+		assert.Equal(t, 0, components[2].Master)
+		assert.Error(t, components[3].Err)
+	})
+
+	t.Run("Invalid components are reported", func(t *testing.T) {
+		components, err := Parse(strings.NewReader(calendar(
+			"BEGIN:VEVENT",
+			"UID:nostart@example.com",
+			"SUMMARY:No start",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:badzone@example.com",
+			"DTSTART;TZID=Mars/Olympus:20240115T100000",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:ok@example.com",
+			"SUMMARY:Fine",
+			"DTSTART:20240115T100000Z",
+			"END:VEVENT",
+		)), time.UTC)
+		require.NoError(t, err)
+
+		assert.ErrorContains(t, components[0].Err, "DTSTART")
+		assert.ErrorContains(t, components[1].Err, "unknown time zone")
+		assert.NoError(t, components[2].Err)
+	})
+
+	t.Run("Broken calendar", func(t *testing.T) {
+		for _, data := range []string{
+			"BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nnot a content line\r\nEND:VCALENDAR\r\n",
+		} {
+			_, err := Parse(strings.NewReader(data), time.UTC)
+			assert.ErrorIs(t, err, ErrInvalidCalendar, data)
+		}
+	})
+
+	t.Run("Export and import round trip", func(t *testing.T) {
+		start := time.Date(2024, 7, 15, 10, 0, 0, 0, berlin)
+		original := &model.Event{EventId: 7, Text: "Sync, weekly", TimeZone: "Europe/Berlin", Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+			Start: model.Date(start), End: model.Date(start.Add(30 * time.Minute)), UpdatedAt: model.Date(start)}
+
+		components, err := Parse(strings.NewReader(string(Marshal(Calendar{Events: []*model.Event{original}}))), time.UTC)
+		require.NoError(t, err)
+		require.Len(t, components, 1)
+
+		event := components[0].Event
+		assert.Equal(t, "7@calendar-service", components[0].UID)
+		assert.Equal(t, original.Text, event.Text)
+		assert.Equal(t, original.TimeZone, event.TimeZone)
+		assert.Equal(t, original.Recurrence, event.Recurrence)
+		assert.True(t, time.Time(event.Start).Equal(start))
+		assert.True(t, time.Time(event.End).Equal(start.Add(30*time.Minute)))
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"PT15M", 15 * time.Minute},
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+		{"P2W", 14 * 24 * time.Hour},
+		{"-PT10S", -10 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	for _, value := range []string{"", "P", "PT", "15M", "P1H", "PT1D", "P1DT"} {
+		_, err := parseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,230 @@
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+)
+
+// сколько лет после начала последнего события описывается в VTIMEZONE: клиенты,
+// которые не знают зону по имени IANA, берут переходы отсюда
+const timezoneYears = 10
+
+// zone - зона, на которую ссылаются TZID, и годы, переходы которых нужно описать
+type zone struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// observance - период с одним смещением зоны, начинающийся переходом в момент start
+type observance struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	daylight   bool
+}
+
+// timezones собирает зоны событий, которые выгружаются с TZID, в порядке появления
+func timezones(events []*model.Event) []zone {
+	var zones []zone
+	for _, event := range events {
+		loc := location(event)
+		if loc == nil {
+			continue
+		}
+
+		start := time.Time(event.Start).In(loc)
+		from := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		to := time.Date(start.Year()+timezoneYears, time.January, 1, 0, 0, 0, 0, loc)
+
+		i := slices.IndexFunc(zones, func(z zone) bool { return z.loc.String() == loc.String() })
+		if i < 0 {
+			zones = append(zones, zone{loc: loc, from: from, to: to})
+			continue
+		}
+		if from.Before(zones[i].from) {
+			zones[i].from = from
+		}
+		if to.After(zones[i].to) {
+			zones[i].to = to
+		}
+	}
+
+	return zones
+}
+
+func (w *writer) timezone(z zone) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + z.loc.String())
+
+	for _, o := range observances(z) {
+		kind := "STANDARD"
+		if o.daylight {
+			kind = "DAYLIGHT"
+		}
+
+		w.line("BEGIN:" + kind)
+		// начало периода - местное время до перехода
+		w.line("DTSTART:" + o.start.In(time.FixedZone("", o.offsetFrom)).Format(localLayout))
+		w.line("TZOFFSETFROM:" + formatOffset(o.offsetFrom))
+		w.line("TZOFFSETTO:" + formatOffset(o.offsetTo))
+		if o.name != "" {
+			w.line("TZNAME:" + escapeText(o.name))
+		}
+		w.line("END:" + kind)
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+// observances - смещение зоны в начале периода и все переходы внутри него. Переход
+// ищется по дням, а точный момент - двоичным поиском по секундам.
+func observances(z zone) []observance {
+	name, offset := z.from.Zone()
+	result := []observance{{start: z.from, offsetFrom: offset, offsetTo: offset, name: name, daylight: z.from.IsDST()}}
+
+	for day := z.from; day.Before(z.to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.Zone(); nextOffset == offset {
+			continue
+		}
+
+		lo, hi := day.Unix(), next.Unix()
+		for hi-lo > 1 {
+			mid := (lo + hi) / 2
+			if _, o := time.Unix(mid, 0).In(z.loc).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		transition := time.Unix(hi, 0).In(z.loc)
+		name, nextOffset := transition.Zone()
+		result = append(result, observance{
+			start:      transition,
+			offsetFrom: offset,
+			offsetTo:   nextOffset,
+			name:       name,
+			daylight:   transition.IsDST(),
+		})
+		offset = nextOffset
+	}
+
+	return result
+}
+
+// смещение в виде +HHMM или +HHMMSS
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+
+	if seconds%60 != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// vtimezone - зона из VTIMEZONE импортируемого файла для TZID, которых нет в базе
+// IANA (например, имена зон Windows от Outlook)
+type vtimezone struct {
+	id          string
+	observances []observanceRule
+}
+
+// observanceRule - STANDARD или DAYLIGHT: начиная с DTSTART (настенное время до
+// перехода) действует смещение TZOFFSETTO, RRULE повторяет переход
+type observanceRule struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	rule       string
+}
+
+func (o *observanceRule) set(prop property) {
+	switch prop.name {
+	case "DTSTART":
+		o.start, _ = time.Parse(localLayout, prop.value)
+	case "TZOFFSETFROM":
+		o.offsetFrom, _ = parseOffset(prop.value)
+	case "TZOFFSETTO":
+		o.offsetTo, _ = parseOffset(prop.value)
+	case "RRULE":
+		o.rule = prop.value
+	}
+}
+
+// in переводит настенное время local в момент по смещению последнего перехода
+// не позже него. До первого перехода действует его TZOFFSETFROM.
+func (z *vtimezone) in(local time.Time) time.Time {
+	var (
+		latest time.Time
+		offset int
+		found  bool
+	)
+
+	for _, o := range z.observances {
+		onset, ok := o.lastOnset(local)
+		if ok && (!found || onset.After(latest)) {
+			latest, offset, found = onset, o.offsetTo, true
+		}
+	}
+
+	if !found && len(z.observances) > 0 {
+		first := slices.MinFunc(z.observances, func(a, b observanceRule) int { return a.start.Compare(b.start) })
+		offset = first.offsetFrom
+	}
+
+	return inZone(local, time.FixedZone(z.id, offset))
+}
+
+func (o observanceRule) lastOnset(local time.Time) (time.Time, bool) {
+	if o.start.IsZero() || o.start.After(local) {
+		return time.Time{}, false
+	}
+
+	rule, err := rrule.Parse(o.rule)
+	if o.rule == "" || err != nil {
+		return o.start, true
+	}
+
+	last := o.start
+	rule.Iterate(o.start, func(t time.Time) bool {
+		if t.After(local) {
+			return false
+		}
+		last = t
+		return true
+	})
+
+	return last, true
+}
+
+// parseOffset разбирает смещение +HHMM или +HHMMSS в секунды
+func parseOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+
+	var parts [3]int
+	for i := 0; i*2+1 < len(s); i++ {
+		n, err := strconv.Atoi(s[i*2+1 : i*2+3])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		parts[i] = n
+	}
+
+	seconds := parts[0]*3600 + parts[1]*60 + parts[2]
+	if s[0] == '-' {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
@@ -0,0 +1,364 @@
+// Package jsonpatch применяет к JSON документам JSON Merge Patch (RFC 7396)
+// и JSON Patch (RFC 6902). Документ разбирается в дерево map[string]any / []any,
+// изменяется и сериализуется обратно.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrInvalidPath  = errors.New("invalid path")
+	ErrTestFailed   = errors.New("test operation failed")
+
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// MergePatch применяет JSON Merge Patch: объекты сливаются рекурсивно,
+// null удаляет ключ, любое другое значение заменяет целевое целиком
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// Operation - одна операция JSON Patch. Value - сырой JSON, чтобы отличать
+// отсутствующее значение от null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type Patch []Operation
+
+// Decode разбирает JSON Patch и проверяет, что у операций есть нужные поля
+func Decode(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) has no value", ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) has invalid from", ErrInvalidPatch, i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d (%s) has invalid path", ErrInvalidPatch, i, op.Op)
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply применяет операции по порядку. Патч атомарен: при ошибке в любой
+// операции возвращается ошибка, а doc не меняется.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		var err error
+		root, err = apply(root, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func apply(root any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "remove":
+		return remove(root, path)
+	case "replace":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return modify(root, path, func(node any, key string) (any, error) {
+			switch n := node.(type) {
+			case map[string]any:
+				if _, ok := n[key]; !ok {
+					return nil, ErrInvalidPath
+				}
+				n[key] = value
+				return n, nil
+			case []any:
+				i, err := arrayIndex(key, len(n)-1)
+				if err != nil {
+					return nil, err
+				}
+				n[i] = value
+				return n, nil
+			}
+			return nil, ErrInvalidPath
+		})
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		// нельзя переместить значение внутрь него самого
+		if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+			return nil, ErrInvalidPath
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if root, err = remove(root, from); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "test":
+		expected, err := decodeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	}
+
+	return nil, ErrInvalidPatch
+}
+
+func add(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return modify(root, path, func(node any, key string) (any, error) {
+		switch n := node.(type) {
+		case map[string]any:
+			n[key] = value
+			return n, nil
+		case []any:
+			if key == "-" {
+				return append(n, value), nil
+			}
+			i, err := arrayIndex(key, len(n))
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(n, i, value), nil
+		}
+		return nil, ErrInvalidPath
+	})
+}
+
+func remove(root any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, ErrInvalidPath
+	}
+
+	return modify(root, path, func(node any, key string) (any, error) {
+		switch n := node.(type) {
+		case map[string]any:
+			if _, ok := n[key]; !ok {
+				return nil, ErrInvalidPath
+			}
+			delete(n, key)
+			return n, nil
+		case []any:
+			i, err := arrayIndex(key, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Delete(n, i, i+1), nil
+		}
+		return nil, ErrInvalidPath
+	})
+}
+
+// modify спускается по пути до родителя последнего токена, вызывает для него fn
+// и возвращает обновленный узел: массивы при вставке и удалении пересоздаются
+func modify(node any, path []string, fn func(node any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, ErrInvalidPath
+		}
+		updated, err := modify(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := modify(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+
+	return nil, ErrInvalidPath
+}
+
+func get(node any, path []string) (any, error) {
+	for _, key := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[key]
+			if !ok {
+				return nil, ErrInvalidPath
+			}
+			node = child
+		case []any:
+			i, err := arrayIndex(key, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, ErrInvalidPath
+		}
+	}
+
+	return node, nil
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901), пустая строка - весь документ
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidPath
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// индекс массива от 0 до max без ведущих нулей
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrInvalidPath
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, ErrInvalidPath
+	}
+
+	return i, nil
+}
+
+func decodeValue(raw json.RawMessage) (any, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return value, nil
+}
+
+func deepCopy(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeValue(data)
+}
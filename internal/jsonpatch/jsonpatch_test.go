@@ -0,0 +1,121 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// примеры из приложения A RFC 7396
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, tt.want, string(got), "%s + %s", tt.doc, tt.patch)
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+// примеры из приложения A RFC 6902
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"Add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"Add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"Append to array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"Add null value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"foo":"bar","baz":null}`},
+		{"Remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"Remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"Replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"Move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"Move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"Copy value", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`, `{"foo":{"bar":1},"baz":{"bar":2}}`},
+		{"Test value", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"Escaped keys", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`},
+		{"Replace whole document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Decode([]byte(tt.patch))
+			require.NoError(t, err)
+
+			got, err := patch.Apply([]byte(tt.doc))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	t.Run("Invalid patch documents", func(t *testing.T) {
+		invalid := []string{
+			`{"op":"add"}`,
+			`[{"op":"frobnicate","path":"/a"}]`,
+			`[{"op":"add","path":"/a"}]`,
+			`[{"op":"replace","path":"a","value":1}]`,
+			`[{"op":"move","from":"a","path":"/b"}]`,
+		}
+		for _, patch := range invalid {
+			_, err := Decode([]byte(patch))
+			assert.ErrorIs(t, err, ErrInvalidPatch, patch)
+		}
+	})
+
+	tests := []struct {
+		name, doc, patch string
+		err              error
+	}{
+		{"Test fails", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestFailed},
+		{"Add to missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrInvalidPath},
+		{"Remove missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrInvalidPath},
+		{"Replace missing member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ErrInvalidPath},
+		{"Array index out of range", `{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":1}]`, ErrInvalidPath},
+		{"Leading zero index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/01"}]`, ErrInvalidPath},
+		{"Move into own child", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Decode([]byte(tt.patch))
+			require.NoError(t, err)
+
+			_, err = patch.Apply([]byte(tt.doc))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("Failed patch is atomic", func(t *testing.T) {
+		doc := []byte(`{"foo":"bar"}`)
+		patch, err := Decode([]byte(`[{"op":"replace","path":"/foo","value":"baz"},{"op":"test","path":"/foo","value":"bar"}]`))
+		require.NoError(t, err)
+
+		_, err = patch.Apply(doc)
+		assert.ErrorIs(t, err, ErrTestFailed)
+		assert.JSONEq(t, `{"foo":"bar"}`, string(doc))
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem - тело ответа об ошибке по RFC 7807, Code - стабильный код из apperror
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+
+	// нарушенные правила валидации
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
+
+var statuses = map[apperror.Kind]int{
+	apperror.KindNotFound:   http.StatusNotFound,
+	apperror.KindConflict:   http.StatusConflict,
+	apperror.KindValidation: http.StatusBadRequest,
+	apperror.KindForbidden:  http.StatusForbidden,
+	apperror.KindInternal:   http.StatusInternalServerError,
+
+	apperror.KindPreconditionFailed: http.StatusPreconditionFailed,
+	apperror.KindUnauthorized:       http.StatusUnauthorized,
+	apperror.KindTooLarge:           http.StatusRequestEntityTooLarge,
+}
+
+// ErrorMiddleware превращает ошибку, добавленную обработчиком через c.Error,
+// в ответ application/problem+json. Если обработчик уже ответил, ничего не делает.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		appErr := apperror.From(c.Errors.Last().Err)
+		status, ok := statuses[appErr.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+
+		problem := Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   appErr.Message,
+			Instance: c.Request.URL.Path,
+			Code:     appErr.Code,
+			Errors:   appErr.Fields,
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.JSON(status, problem)
+	}
+}
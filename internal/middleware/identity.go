@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/Komilov31/calendar-service/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// ключ контекста gin с пользователем, от имени которого выполняется запрос
+const identityKey = "identity"
+
+// Authenticate проверяет учетные данные запроса и сохраняет пользователя в контексте.
+// Без настроенных ключей и секрета JWT все запросы отклоняются, nil выключает проверку.
+func Authenticate(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticator == nil {
+			c.Next()
+			return
+		}
+
+		identity, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="calendar", Basic realm="calendar"`)
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		SetIdentity(c, identity)
+		c.Next()
+	}
+}
+
+func SetIdentity(c *gin.Context, identity auth.Identity) {
+	c.Set(identityKey, identity)
+}
+
+// CurrentIdentity возвращает аутентифицированного пользователя запроса
+func CurrentIdentity(c *gin.Context) (auth.Identity, bool) {
+	identity, ok := c.Get(identityKey)
+	if !ok {
+		return auth.Identity{}, false
+	}
+
+	return identity.(auth.Identity), true
+}
+
+// Actor возвращает id пользователя, от имени которого выполняется запрос к данным
+// пользователя userId. Администратор действует от имени владельца данных, без
+// аутентификации запрос выполняется от имени userId, как до ее появления.
+func Actor(c *gin.Context, userId int) int {
+	identity, ok := CurrentIdentity(c)
+	if !ok || identity.HasScope(auth.ScopeAdmin) {
+		return userId
+	}
+
+	return identity.UserId
+}
@@ -1,29 +1,437 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// Event занимает полуинтервал [Start, End). Событие на весь день (AllDay)
+// начинается в полночь и заканчивается в полночь дня, следующего за последним.
+// Повторяющееся событие (серия) задается правилом Recurrence в формате RRULE,
+// его экземпляры строятся при чтении и отличаются от серии полем RecurrenceId -
+// исходным началом экземпляра.
 type Event struct {
-	EventId int    `json:"event_id"`
-	UserId  int    `json:"user_id" validate:"required"`
-	Text    string `json:"text" validate:"required"`
-	Date    Date   `json:"date" validate:"required,date_after_now"`
+	EventId      int    `json:"event_id"`
+	UserId       int    `json:"user_id" validate:"required"`
+	CalendarId   int    `json:"calendar_id,omitempty"`
+	Text         string `json:"text" validate:"required"`
+	Start        Date   `json:"start" validate:"required,date_after_now"`
+	End          Date   `json:"end"`
+	AllDay       bool   `json:"all_day"`
+	Recurrence   string `json:"rrule,omitempty" validate:"omitempty,rrule"`
+	TimeZone     string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+	RecurrenceId *Date  `json:"recurrence_id,omitempty"`
+
+	// прозрачные события (TRANSP:TRANSPARENT) не занимают время в free/busy
+	Transparency string `json:"transparency,omitempty" validate:"omitempty,oneof=opaque transparent"`
+
+	// участники встречи. Организатор - владелец события UserId, участники видят
+	// событие в своих выборках и отвечают на приглашение.
+	Attendees []Attendee `json:"attendees,omitempty" validate:"omitempty,max=100,unique=UserId,dive"`
+
+	// исключения серии: отмененные экземпляры (EXDATE) и измененные экземпляры,
+	// у которых RecurrenceId указывает на исходное начало
+	ExDates   []Date   `json:"exdates,omitempty"`
+	Overrides []*Event `json:"overrides,omitempty"`
+
+	// UID из импортированного календаря, по нему повторный импорт находит событие.
+	// У остальных событий UID строится по EventId при выгрузке.
+	UID string `json:"uid,omitempty"`
+
+	// Version увеличивается при каждом сохранении, по нему строится ETag
+	Version   int  `json:"version"`
+	CreatedAt Date `json:"created_at"`
+	UpdatedAt Date `json:"updated_at"`
+}
+
+// Область изменения повторяющегося события: один экземпляр, экземпляр и все
+// следующие за ним, или вся серия
+const (
+	ScopeThis      = "this"
+	ScopeFollowing = "following"
+	ScopeAll       = "all"
+)
+
+// Прозрачность события: opaque (по умолчанию) занимает время, transparent - нет
+const (
+	TransparencyOpaque      = "opaque"
+	TransparencyTransparent = "transparent"
+)
+
+// Ответ участника на приглашение
+const (
+	StatusNeedsAction = "needs-action"
+	StatusAccepted    = "accepted"
+	StatusDeclined    = "declined"
+	StatusTentative   = "tentative"
+)
+
+// Attendee - участник встречи и его ответ. Ответ меняет только сам участник,
+// новые участники и участники перенесенной встречи получают needs-action.
+type Attendee struct {
+	UserId int    `json:"user_id" validate:"required,min=1"`
+	Status string `json:"status,omitempty" validate:"omitempty,oneof=needs-action accepted declined tentative"`
+}
+
+// RSVP - ответ на приглашение в PUT /v2/users/:user_id/invitations/:event_id
+type RSVP struct {
+	Status string `json:"status" validate:"required,oneof=accepted declined tentative"`
+}
+
+// Busy сообщает, занято ли время пользователя userId событием. Прозрачные события
+// время не занимают, а приглашение занимает его, только если участник его принял
+// или ответил tentative.
+func (e Event) Busy(userId int) bool {
+	if e.Transparency == TransparencyTransparent {
+		return false
+	}
+
+	if e.UserId == userId {
+		return true
+	}
+
+	attendee, ok := e.Attendee(userId)
+	return ok && (attendee.Status == StatusAccepted || attendee.Status == StatusTentative)
+}
+
+// Attendee возвращает участника userId
+func (e Event) Attendee(userId int) (Attendee, bool) {
+	for _, attendee := range e.Attendees {
+		if attendee.UserId == userId {
+			return attendee, true
+		}
+	}
+
+	return Attendee{}, false
 }
 
 type UpdateEvent struct {
-	EventId *int    `json:"event_id"`
-	UserId  *int    `json:"user_id"`
-	Text    *string `json:"text"`
-	Date    *Date   `json:"date" validate:"date_after_now"`
+	EventId    *int    `json:"event_id"`
+	UserId     *int    `json:"user_id"`
+	CalendarId *int    `json:"calendar_id"`
+	Text       *string `json:"text"`
+	Start      *Date   `json:"start" validate:"omitempty,date_after_now"`
+	End        *Date   `json:"end"`
+	AllDay     *bool   `json:"all_day"`
+	Recurrence *string `json:"rrule" validate:"omitempty,rrule"`
+	TimeZone   *string `json:"time_zone" validate:"omitempty,timezone"`
+
+	Transparency *string `json:"transparency" validate:"omitempty,oneof=opaque transparent"`
+
+	// список участников заменяется целиком, ответы оставшихся участников сохраняются
+	Attendees *[]Attendee `json:"attendees" validate:"omitempty,max=100,unique=UserId,dive"`
+
+	// экземпляр серии и область изменения передаются в query параметрах
+	Occurrence *Date  `json:"-"`
+	Scope      string `json:"-" validate:"omitempty,oneof=this following all"`
+
+	// версии из If-Match: событие меняется, только если его текущая версия
+	// среди них. Пустой список версию не проверяет.
+	IfMatch []int `json:"-"`
+
+	// исключения серии целиком, например из ресурса CalDAV. В JSON API
+	// исключения меняются через occurrence и scope.
+	ExDates   *[]Date   `json:"-"`
+	Overrides *[]*Event `json:"-"`
+}
+
+type DeleteEvent struct {
+	UserId     int
+	EventId    int
+	Occurrence *Date
+	Scope      string `validate:"omitempty,oneof=this following all"`
+	IfMatch    []int
+}
+
+// UserSettings - настройки пользователя для старых маршрутов, TimeZone - имя зоны
+// из базы IANA. Хранятся в User.
+type UserSettings struct {
+	UserId   int    `json:"user_id"`
+	TimeZone string `json:"time_zone" validate:"required,timezone"`
+}
+
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// User - пользователь сервиса. Отключенный пользователь (DisabledAt) остается вместе
+// с календарями в архиве: события можно читать, но не создавать и не менять.
+type User struct {
+	UserId     int    `json:"user_id"`
+	Name       string `json:"name" validate:"required,max=100"`
+	Email      string `json:"email" validate:"required,email,max=254"`
+	TimeZone   string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+	Locale     string `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag"`
+	WeekStart  string `json:"week_start,omitempty" validate:"omitempty,oneof=monday sunday saturday"`
+	CreatedAt  Date   `json:"created_at"`
+	UpdatedAt  Date   `json:"updated_at"`
+	DisabledAt *Date  `json:"disabled_at,omitempty"`
+}
+
+func (u User) Active() bool {
+	return u.DisabledAt == nil
+}
+
+// FirstWeekday - первый день недели для выборок за неделю, по умолчанию понедельник
+func (u User) FirstWeekday() time.Weekday {
+	switch u.WeekStart {
+	case WeekStartSunday:
+		return time.Sunday
+	case WeekStartSaturday:
+		return time.Saturday
+	default:
+		return time.Monday
+	}
+}
+
+// Settings - настройки пользователя в формате старых маршрутов
+func (u User) Settings() UserSettings {
+	tz := u.TimeZone
+	if tz == "" {
+		tz = time.UTC.String()
+	}
+
+	return UserSettings{UserId: u.UserId, TimeZone: tz}
+}
+
+// Calendar - именованный календарь пользователя. События без calendar_id попадают
+// в календарь по умолчанию: им становится первый календарь пользователя, а если
+// календарей нет, он создается вместе с первым событием. TimeZone получают новые
+// события календаря, у которых зона не указана.
+type Calendar struct {
+	CalendarId  int    `json:"calendar_id"`
+	UserId      int    `json:"user_id" validate:"required"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Color       string `json:"color,omitempty" validate:"omitempty,hexcolor"`
+	TimeZone    string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+	Description string `json:"description,omitempty" validate:"max=1000"`
+	Default     bool   `json:"default"`
+	CreatedAt   Date   `json:"created_at"`
+	UpdatedAt   Date   `json:"updated_at"`
+}
+
+// Роли доступа к календарю в порядке возрастания прав: freebusy видит только
+// занятое время, viewer - события целиком, editor может менять события, owner -
+// также календарь и его доступы. Владелец календаря всегда имеет роль owner.
+const (
+	RoleFreeBusy = "freebusy"
+	RoleViewer   = "viewer"
+	RoleEditor   = "editor"
+	RoleOwner    = "owner"
+)
+
+var roleRanks = map[string]int{RoleFreeBusy: 1, RoleViewer: 2, RoleEditor: 3, RoleOwner: 4}
+
+// RoleAllows сообщает, дает ли роль role права роли required. Пустая роль - нет доступа
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// ACLEntry - доступ пользователя UserId к календарю CalendarId
+type ACLEntry struct {
+	CalendarId int    `json:"calendar_id"`
+	UserId     int    `json:"user_id" validate:"required,min=1"`
+	Role       string `json:"role" validate:"required,oneof=owner editor viewer freebusy"`
+}
+
+// FreeBusyQuery - запрос занятости POST /freebusy. from и to в формате RFC3339 или
+// YYYY-MM-DD, даты без времени считаются в зоне time_zone (по умолчанию UTC).
+type FreeBusyQuery struct {
+	UserIds  []int  `json:"user_ids" validate:"required,min=1,max=50,unique,dive,min=1"`
+	From     string `json:"from" validate:"required"`
+	To       string `json:"to" validate:"required"`
+	TimeZone string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+}
+
+// FreeBusy - занятость пользователя: непересекающиеся интервалы по возрастанию.
+// Если занятость недоступна, Busy пустой, а Error - код ошибки (access_denied,
+// user_not_found).
+type FreeBusy struct {
+	UserId int        `json:"user_id"`
+	Busy   []Interval `json:"busy"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// Interval - полуинтервал времени [Start, End)
+type Interval struct {
+	Start Date `json:"start"`
+	End   Date `json:"end"`
+}
+
+// SlotQuery - поиск времени встречи POST /scheduling/slots. Период и зона задаются
+// как в FreeBusyQuery, длительность и запас между встречами - в минутах.
+type SlotQuery struct {
+	UserIds      []int        `json:"user_ids" validate:"required,min=1,max=50,unique,dive,min=1"`
+	From         string       `json:"from" validate:"required"`
+	To           string       `json:"to" validate:"required"`
+	TimeZone     string       `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+	Duration     int          `json:"duration" validate:"required,min=5,max=1440"`
+	Buffer       int          `json:"buffer,omitempty" validate:"min=0,max=240"`
+	WorkingHours WorkingHours `json:"working_hours"`
+	WorkingDays  []string     `json:"working_days,omitempty" validate:"omitempty,max=7,unique,dive,oneof=monday tuesday wednesday thursday friday saturday sunday"`
+	Limit        int          `json:"limit,omitempty" validate:"omitempty,min=1,max=50"`
+}
+
+// WorkingHours - рабочие часы в формате HH:MM, по умолчанию с 09:00 до 18:00
+type WorkingHours struct {
+	Start string `json:"start,omitempty" validate:"omitempty,datetime=15:04"`
+	End   string `json:"end,omitempty" validate:"omitempty,datetime=15:04"`
+}
+
+// поддерживаем старый формат запросов, где было только поле date
+func (e *Event) UnmarshalJSON(b []byte) error {
+	type plain Event
+	aux := struct {
+		*plain
+		Date json.RawMessage `json:"date"`
+	}{plain: (*plain)(e)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if aux.Date == nil || !e.Start.IsZero() {
+		return nil
+	}
+
+	dateOnly, err := e.Start.unmarshalLegacy(aux.Date)
+	if err != nil {
+		return err
+	}
+	if dateOnly {
+		e.AllDay = true
+	}
+
+	return nil
+}
+
+func (u *UpdateEvent) UnmarshalJSON(b []byte) error {
+	type plain UpdateEvent
+	aux := struct {
+		*plain
+		Date json.RawMessage `json:"date"`
+	}{plain: (*plain)(u)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if aux.Date == nil || u.Start != nil {
+		return nil
+	}
+
+	var start Date
+	dateOnly, err := start.unmarshalLegacy(aux.Date)
+	if err != nil {
+		return err
+	}
+	u.Start = &start
+	if dateOnly {
+		u.AllDay = &dateOnly
+	}
+
+	return nil
+}
+
+// Normalize приводит границы события к каноническому виду: у события без конца
+// End совпадает со Start, у события на весь день границы выровнены по полуночи.
+func (e *Event) Normalize() {
+	start, end := time.Time(e.Start), time.Time(e.End)
+
+	if !e.AllDay {
+		if end.IsZero() {
+			e.End = e.Start
+		}
+		return
+	}
+
+	start = midnight(start)
+	if end.IsZero() || !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	} else if !end.Equal(midnight(end)) {
+		end = midnight(end).AddDate(0, 0, 1)
+	}
+
+	e.Start, e.End = Date(start), Date(end)
+}
+
+// Overlaps сообщает, пересекается ли событие с полуинтервалом [from, to).
+// Событие нулевой длительности считается точкой.
+func (e Event) Overlaps(from, to time.Time) bool {
+	start, end := time.Time(e.Start), time.Time(e.End)
+	if !end.After(start) {
+		return !start.Before(from) && start.Before(to)
+	}
+
+	return start.Before(to) && end.After(from)
+}
+
+// FreeBusy возвращает копию события только со временем: без описания, UID и
+// с такими же урезанными измененными экземплярами серии
+func (e Event) FreeBusy() Event {
+	busy := Event{
+		EventId:      e.EventId,
+		UserId:       e.UserId,
+		CalendarId:   e.CalendarId,
+		Start:        e.Start,
+		End:          e.End,
+		AllDay:       e.AllDay,
+		Recurrence:   e.Recurrence,
+		TimeZone:     e.TimeZone,
+		RecurrenceId: e.RecurrenceId,
+		Transparency: e.Transparency,
+		ExDates:      e.ExDates,
+		Version:      e.Version,
+	}
+	for _, override := range e.Overrides {
+		o := override.FreeBusy()
+		busy.Overrides = append(busy.Overrides, &o)
+	}
+
+	return busy
+}
+
+func (e Event) IsRecurring() bool {
+	return e.Recurrence != ""
+}
+
+// In возвращает копию события во временной зоне loc. События на весь день
+// не привязаны к зоне: они остаются на тех же календарных датах.
+func (e Event) In(loc *time.Location) Event {
+	start, end := time.Time(e.Start), time.Time(e.End)
+
+	if e.AllDay {
+		e.Start = Date(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc))
+		e.End = Date(time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc))
+		return e
+	}
+
+	e.Start, e.End = Date(start.In(loc)), Date(end.In(loc))
+	return e
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
 
 type Date time.Time
 
 func (d *Date) UnmarshalJSON(b []byte) error {
-	s := strings.Trim(string(b), "\"")
+	date, err := ParseDate(strings.Trim(string(b), "\""))
+	if err != nil {
+		return err
+	}
+
+	*d = date
+	return nil
+}
+
+// ParseDate принимает дату в формате YYYY-MM-DD или RFC3339
+func ParseDate(s string) (Date, error) {
 	layouts := []string{
 		"2006-01-02",
 		time.RFC3339,
@@ -33,15 +441,27 @@ func (d *Date) UnmarshalJSON(b []byte) error {
 	for _, layout := range layouts {
 		t, err = time.Parse(layout, s)
 		if err == nil {
-			*d = Date(t)
-			return nil
+			return Date(t), nil
 		}
 	}
-	return err
+	return Date{}, err
 }
 
 func (d Date) MarshalJSON() ([]byte, error) {
 	t := time.Time(d)
-	formatted := fmt.Sprintf("\"%s\"", t.Format(time.DateOnly))
+	formatted := fmt.Sprintf("\"%s\"", t.Format(time.RFC3339))
 	return []byte(formatted), nil
 }
+
+func (d Date) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
+// возвращает true, если дата была передана без времени
+func (d *Date) unmarshalLegacy(b []byte) (bool, error) {
+	if err := d.UnmarshalJSON(b); err != nil {
+		return false, err
+	}
+
+	return len(strings.Trim(string(b), "\"")) == len(time.DateOnly), nil
+}
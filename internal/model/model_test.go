@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	event := Event{
+		EventId: 1,
+		UserId:  1,
+		Text:    "Standup",
+		Start:   Date(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)),
+		End:     Date(time.Date(2024, 1, 15, 10, 45, 0, 0, time.UTC)),
+	}
+
+	body, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	var decoded Event
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, event, decoded)
+}
+
+func TestEventNormalize(t *testing.T) {
+	t.Run("Timed event without end", func(t *testing.T) {
+		event := Event{Start: Date(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))}
+		event.Normalize()
+		assert.Equal(t, event.Start, event.End)
+	})
+
+	t.Run("All-day event is aligned to midnight", func(t *testing.T) {
+		event := Event{Start: Date(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)), AllDay: true}
+		event.Normalize()
+		assert.Equal(t, Date(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)), event.Start)
+		assert.Equal(t, Date(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)), event.End)
+	})
+
+	t.Run("All-day end with time covers the whole last day", func(t *testing.T) {
+		event := Event{
+			Start:  Date(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)),
+			End:    Date(time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)),
+			AllDay: true,
+		}
+		event.Normalize()
+		assert.Equal(t, Date(time.Date(2024, 1, 18, 0, 0, 0, 0, time.UTC)), event.End)
+	})
+}
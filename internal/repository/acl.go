@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+var (
+	ErrNoSuchACL = apperror.NotFound("acl_not_found", "user has no access to this calendar")
+	ErrOwnerACL  = apperror.Validation("owner_acl", "calendar owner always has the owner role")
+)
+
+// PutACL выдает роль в календаре владельца userId зарегистрированному пользователю
+// или меняет ее. Чужой календарь считается ненайденным.
+func (r *Repository) PutACL(userId int, entry model.ACLEntry) (model.ACLEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calendar, ok := r.getCalendarByUserId(userId, entry.CalendarId)
+	if !ok {
+		return model.ACLEntry{}, ErrNoSuchCalendar
+	}
+
+	if calendar.UserId == entry.UserId {
+		return model.ACLEntry{}, ErrOwnerACL
+	}
+
+	if _, ok := r.users[entry.UserId]; !ok {
+		return model.ACLEntry{}, ErrNoSuchUser
+	}
+
+	if err := r.commit(record{Op: opPutACL, ACL: &entry}); err != nil {
+		return model.ACLEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// DeleteACL отзывает доступ grantee к календарю владельца userId
+func (r *Repository) DeleteACL(userId, calendarId, grantee int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.getCalendarByUserId(userId, calendarId); !ok {
+		return ErrNoSuchCalendar
+	}
+
+	if _, ok := r.acl[calendarId][grantee]; !ok {
+		return ErrNoSuchACL
+	}
+
+	return r.commit(record{Op: opDeleteACL, CalendarId: calendarId, UserId: grantee})
+}
+
+// GetACL возвращает доступы к календарю владельца userId, выданные другим пользователям,
+// по возрастанию их id
+func (r *Repository) GetACL(userId, calendarId int) ([]model.ACLEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.getCalendarByUserId(userId, calendarId); !ok {
+		return nil, ErrNoSuchCalendar
+	}
+
+	entries := []model.ACLEntry{}
+	for userId, role := range r.acl[calendarId] {
+		entries = append(entries, model.ACLEntry{CalendarId: calendarId, UserId: userId, Role: role})
+	}
+
+	slices.SortFunc(entries, func(a, b model.ACLEntry) int {
+		return cmp.Compare(a.UserId, b.UserId)
+	})
+	return entries, nil
+}
+
+func (r *Repository) putACL(entry *model.ACLEntry) {
+	roles, ok := r.acl[entry.CalendarId]
+	if !ok {
+		roles = make(map[int]string)
+		r.acl[entry.CalendarId] = roles
+	}
+
+	roles[entry.UserId] = entry.Role
+}
+
+func (r *Repository) removeACL(calendarId, userId int) {
+	delete(r.acl[calendarId], userId)
+}
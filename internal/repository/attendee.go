@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+var ErrNoSuchInvitation = apperror.NotFound("invitation_not_found", "user is not invited to this event")
+
+// Respond сохраняет ответ участника userId на приглашение. Ответ на серию относится
+// ко всем ее экземплярам.
+func (r *Repository) Respond(userId, eventId int, status string) (model.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.getInvitation(userId, eventId)
+	if !ok {
+		return model.Event{}, ErrNoSuchInvitation
+	}
+
+	event := cloneSeries(stored)
+	event.Attendees = withStatus(event.Attendees, userId, status)
+	for i, override := range event.Overrides {
+		instance := *override
+		instance.Attendees = withStatus(instance.Attendees, userId, status)
+		event.Overrides[i] = &instance
+	}
+
+	return r.saveEvent(event)
+}
+
+// GetInvitations возвращает события других пользователей, в которых userId участник,
+// по началу
+func (r *Repository) GetInvitations(userId int) ([]*model.Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.users[userId]; !ok {
+		return nil, ErrNoSuchUser
+	}
+
+	invitations := append([]*model.Event{}, r.invitations(userId)...)
+
+	slices.SortFunc(invitations, func(a, b *model.Event) int {
+		return cmp.Or(time.Time(a.Start).Compare(time.Time(b.Start)), cmp.Compare(a.EventId, b.EventId))
+	})
+	return invitations, nil
+}
+
+func (r *Repository) getInvitation(userId, eventId int) (*model.Event, bool) {
+	index, ok := r.index[userId]
+	if !ok {
+		return nil, false
+	}
+
+	event, ok := index.get(eventId)
+	if !ok || event.UserId == userId {
+		return nil, false
+	}
+
+	return event, true
+}
+
+// события других пользователей в индексе userId
+func (r *Repository) invitations(userId int) []*model.Event {
+	index, ok := r.index[userId]
+	if !ok {
+		return nil
+	}
+
+	var events []*model.Event
+	for _, node := range index.nodes {
+		if node.event.UserId != userId {
+			events = append(events, node.event)
+		}
+	}
+
+	return events
+}
+
+// indexAttendees добавляет событие в индексы участников, чтобы оно попадало в их
+// выборки, и убирает из индексов участников, которых больше нет в списке
+func (r *Repository) indexAttendees(previous, event *model.Event) {
+	if previous != nil {
+		for _, attendee := range previous.Attendees {
+			if _, ok := event.Attendee(attendee.UserId); !ok {
+				r.unindex(attendee.UserId, event.EventId)
+			}
+		}
+	}
+
+	for _, attendee := range event.Attendees {
+		// удаленные пользователи событий больше не видят
+		if _, ok := r.users[attendee.UserId]; !ok {
+			continue
+		}
+
+		index, ok := r.index[attendee.UserId]
+		if !ok {
+			index = newTimeIndex()
+			r.index[attendee.UserId] = index
+		}
+		index.put(event)
+	}
+}
+
+// withExistingAttendees убирает из события участников, которых нет среди пользователей.
+// Такие остаются в данных, записанных до того, как удаление пользователя стало убирать
+// его из чужих событий: иначе новый пользователь с тем же id получил бы их приглашения.
+func (r *Repository) withExistingAttendees(event *model.Event) *model.Event {
+	exists := func(attendee model.Attendee) bool {
+		_, ok := r.users[attendee.UserId]
+		return ok
+	}
+
+	stale := !all(event.Attendees, exists)
+	for _, override := range event.Overrides {
+		stale = stale || !all(override.Attendees, exists)
+	}
+	if !stale {
+		return event
+	}
+
+	return filterAttendees(event, exists)
+}
+
+// filterAttendees - копия события и его измененных экземпляров только с участниками,
+// для которых keep возвращает true
+func filterAttendees(series *model.Event, keep func(model.Attendee) bool) *model.Event {
+	filter := func(attendees []model.Attendee) []model.Attendee {
+		return slices.DeleteFunc(slices.Clone(attendees), func(attendee model.Attendee) bool {
+			return !keep(attendee)
+		})
+	}
+
+	event := cloneSeries(series)
+	event.Attendees = filter(event.Attendees)
+	for i, override := range event.Overrides {
+		instance := *override
+		instance.Attendees = filter(instance.Attendees)
+		event.Overrides[i] = &instance
+	}
+
+	return &event
+}
+
+func all(attendees []model.Attendee, ok func(model.Attendee) bool) bool {
+	return !slices.ContainsFunc(attendees, func(attendee model.Attendee) bool {
+		return !ok(attendee)
+	})
+}
+
+func (r *Repository) unindex(userId, eventId int) {
+	if index, ok := r.index[userId]; ok {
+		index.remove(eventId)
+	}
+}
+
+// mergeAttendees строит новый список участников: организатор в него не входит,
+// ответы оставшихся участников сохраняются, если встреча не перенесена
+func mergeAttendees(previous, next []model.Attendee, organizer int, rescheduled bool) []model.Attendee {
+	var result []model.Attendee
+	for _, attendee := range next {
+		if attendee.UserId == organizer {
+			continue
+		}
+
+		status := model.StatusNeedsAction
+		if i := slices.IndexFunc(previous, func(a model.Attendee) bool { return a.UserId == attendee.UserId }); i >= 0 && !rescheduled {
+			status = previous[i].Status
+		}
+		result = append(result, model.Attendee{UserId: attendee.UserId, Status: status})
+	}
+
+	return result
+}
+
+func withStatus(attendees []model.Attendee, userId int, status string) []model.Attendee {
+	result := slices.Clone(attendees)
+	for i := range result {
+		if result[i].UserId == userId {
+			result[i].Status = status
+		}
+	}
+
+	return result
+}
+
+// встреча перенесена, если изменилось ее время или правило повторения
+func rescheduled(before, after *model.Event) bool {
+	return !time.Time(before.Start).Equal(time.Time(after.Start)) ||
+		!time.Time(before.End).Equal(time.Time(after.End)) ||
+		before.Recurrence != after.Recurrence
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+// имя календаря, который создается вместе с первым событием пользователя без календарей
+const defaultCalendarName = "Default"
+
+// CreateCalendar создает календарь. Первый календарь пользователя становится
+// календарем по умолчанию.
+func (r *Repository) CreateCalendar(calendar model.Calendar) (model.Calendar, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createCalendar(calendar)
+}
+
+// UpdateCalendar заменяет название, цвет, зону и описание календаря
+func (r *Repository) UpdateCalendar(calendar model.Calendar) (model.Calendar, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.getCalendarByUserId(calendar.UserId, calendar.CalendarId)
+	if !ok {
+		return model.Calendar{}, ErrNoSuchCalendar
+	}
+
+	updated := *stored
+	updated.Name = calendar.Name
+	updated.Color = calendar.Color
+	updated.TimeZone = calendar.TimeZone
+	updated.Description = calendar.Description
+
+	return r.saveCalendar(updated)
+}
+
+// DeleteCalendar удаляет календарь вместе со всеми его событиями
+func (r *Repository) DeleteCalendar(userId, calendarId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calendar, ok := r.getCalendarByUserId(userId, calendarId)
+	if !ok {
+		return ErrNoSuchCalendar
+	}
+
+	if calendar.Default {
+		return ErrDefaultCalendar
+	}
+
+	return r.commit(record{Op: opDeleteCalendar, UserId: userId, CalendarId: calendarId})
+}
+
+func (r *Repository) GetCalendar(userId, calendarId int) (model.Calendar, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	calendar, ok := r.getCalendarByUserId(userId, calendarId)
+	if !ok {
+		return model.Calendar{}, ErrNoSuchCalendar
+	}
+
+	return *calendar, nil
+}
+
+// GetCalendars возвращает календари пользователя в порядке создания
+func (r *Repository) GetCalendars(userId int) ([]model.Calendar, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.userCalendars(userId), nil
+}
+
+// вызывается под r.mu
+func (r *Repository) createCalendar(calendar model.Calendar) (model.Calendar, error) {
+	return r.saveCalendar(r.newCalendar(calendar))
+}
+
+// вызывается под r.mu. Новый календарь с id, но еще не сохраненный
+func (r *Repository) newCalendar(calendar model.Calendar) model.Calendar {
+	calendar.CalendarId = r.lastCalendarId + 1
+	calendar.Default = len(r.userCalendars(calendar.UserId)) == 0
+	calendar.CreatedAt = model.Date{}
+
+	return calendar
+}
+
+// вызывается под r.mu
+func (r *Repository) saveCalendar(calendar model.Calendar) (model.Calendar, error) {
+	now := model.Date(time.Now().UTC())
+	if calendar.CreatedAt.IsZero() {
+		calendar.CreatedAt = now
+	}
+	calendar.UpdatedAt = now
+
+	if err := r.commit(record{Op: opPutCalendar, Calendar: &calendar}); err != nil {
+		return model.Calendar{}, err
+	}
+
+	return calendar, nil
+}
+
+// вызывается под r.mu. Календарь нового события: указанный календарь пользователя,
+// а без calendarId - календарь по умолчанию. Если его еще нет, возвращается новый
+// несохраненный календарь и true: он пишется в журнал вместе с событием.
+func (r *Repository) eventCalendar(userId, calendarId int) (model.Calendar, bool, error) {
+	if calendarId != 0 {
+		calendar, ok := r.getCalendarByUserId(userId, calendarId)
+		if !ok {
+			return model.Calendar{}, false, ErrNoSuchCalendar
+		}
+		return *calendar, false, nil
+	}
+
+	for _, calendar := range r.userCalendars(userId) {
+		if calendar.Default {
+			return calendar, false, nil
+		}
+	}
+
+	return r.newCalendar(model.Calendar{UserId: userId, Name: defaultCalendarName}), true, nil
+}
+
+func (r *Repository) getCalendarByUserId(userId, calendarId int) (*model.Calendar, bool) {
+	calendar, ok := r.calendars[calendarId]
+	if !ok || calendar.UserId != userId {
+		return nil, false
+	}
+
+	return calendar, true
+}
+
+// календарей у пользователя мало, поэтому они ищутся перебором
+func (r *Repository) userCalendars(userId int) []model.Calendar {
+	calendars := []model.Calendar{}
+	for _, calendar := range r.calendars {
+		if calendar.UserId == userId {
+			calendars = append(calendars, *calendar)
+		}
+	}
+
+	slices.SortFunc(calendars, func(a, b model.Calendar) int {
+		return cmp.Compare(a.CalendarId, b.CalendarId)
+	})
+	return calendars
+}
+
+func (r *Repository) putCalendar(calendar *model.Calendar) {
+	if calendar.CalendarId > r.lastCalendarId {
+		r.lastCalendarId = calendar.CalendarId
+	}
+
+	r.calendars[calendar.CalendarId] = calendar
+}
+
+// события и доступы календаря удаляются вместе с ним
+func (r *Repository) removeCalendar(userId, calendarId int) {
+	delete(r.calendars, calendarId)
+	delete(r.acl, calendarId)
+
+	for _, event := range slices.Clone(r.events[userId]) {
+		if event.CalendarId == calendarId {
+			r.removeEvent(userId, event.EventId)
+		}
+	}
+}
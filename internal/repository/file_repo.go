@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	defaultSnapshotEvery = 1000
+)
+
+// ErrWALFailed - журнал не удалось вернуть к последней целой записи после ошибки
+// записи. Дальнейшие изменения отклоняются до перезапуска.
+var ErrWALFailed = errors.New("wal is in unknown state after a failed write, restart required")
+
+// walFile - файл журнала, *os.File. Интерфейс позволяет подменить файл в тестах.
+type walFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// FileRepository - хранилище в памяти, которое пишет каждое изменение в журнал (WAL)
+// с fsync и периодически сворачивает журнал в снапшот. При старте состояние
+// восстанавливается из снапшота и журнала.
+type FileRepository struct {
+	*Repository
+	dir           string
+	wal           walFile
+	records       int
+	snapshotEvery int
+
+	// failed - ошибка, после которой журнал только для чтения
+	failed error
+}
+
+func NewFile(dir string, snapshotEvery int) (*FileRepository, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create data dir: %w", err)
+	}
+
+	f := &FileRepository{
+		Repository:    New(),
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := f.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	f.Repository.journal = f
+
+	return f, nil
+}
+
+func (f *FileRepository) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.wal.Close()
+}
+
+// вызывается под f.mu, поэтому все предыдущие записи уже применены в памяти.
+// При ошибке записи или fsync журнал обрезается до начала записи, чтобы в нем не
+// остались ни оборванная строка, ни операция, о которой клиенту сообщили ошибку.
+func (f *FileRepository) append(rec record) error {
+	if f.failed != nil {
+		return f.failed
+	}
+
+	if f.records >= f.snapshotEvery {
+		if err := f.compact(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("could not encode wal record: %w", err)
+	}
+
+	offset, err := f.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("could not get wal offset: %w", err)
+	}
+
+	if _, err := f.wal.Write(append(line, '\n')); err != nil {
+		return f.rollback(offset, fmt.Errorf("could not write wal record: %w", err))
+	}
+
+	if err := f.wal.Sync(); err != nil {
+		return f.rollback(offset, fmt.Errorf("could not sync wal: %w", err))
+	}
+
+	f.records++
+	return nil
+}
+
+// rollback возвращает журнал к offset после неудачной записи. Новый размер файла
+// сохранится на диске вместе со следующей записью и ее fsync. Если вернуть журнал не
+// удалось, состояние файла неизвестно, и репозиторий переходит в режим только для чтения.
+func (f *FileRepository) rollback(offset int64, cause error) error {
+	err := f.wal.Truncate(offset)
+	if err == nil {
+		_, err = f.wal.Seek(offset, io.SeekStart)
+	}
+
+	if err != nil {
+		f.failed = fmt.Errorf("%w: %v, rollback: %v", ErrWALFailed, cause, err)
+		return f.failed
+	}
+
+	return cause
+}
+
+// compact записывает текущее состояние в снапшот и очищает журнал
+func (f *FileRepository) compact() error {
+	data, err := json.Marshal(f.Repository.snapshot())
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+
+	tmpPath := filepath.Join(f.dir, snapshotFileName+".tmp")
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(f.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("could not replace snapshot: %w", err)
+	}
+
+	if err := syncDir(f.dir); err != nil {
+		return fmt.Errorf("could not sync data dir: %w", err)
+	}
+
+	// если упадем до очистки журнала, при старте он просто применится повторно
+	if err := f.wal.Truncate(0); err != nil {
+		return fmt.Errorf("could not truncate wal: %w", err)
+	}
+
+	if _, err := f.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("could not truncate wal: %w", err)
+	}
+
+	f.records = 0
+	return nil
+}
+
+func (f *FileRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(f.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("could not decode snapshot: %w", err)
+	}
+
+	f.Repository.restore(s)
+	return nil
+}
+
+func (f *FileRepository) replayWAL() error {
+	wal, err := os.OpenFile(filepath.Join(f.dir, walFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open wal: %w", err)
+	}
+
+	var offset int64
+	reader := bufio.NewReader(wal)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// недописанная последняя строка после падения - отбрасываем ее
+			break
+		}
+		if err != nil {
+			wal.Close()
+			return fmt.Errorf("could not read wal: %w", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			// испорченной может быть только последняя запись, за которой ничего нет.
+			// Если после нее есть данные, журнал поврежден и обрезать его нельзя.
+			if _, peekErr := reader.Peek(1); peekErr == nil {
+				wal.Close()
+				return fmt.Errorf("corrupt wal record at offset %d: %w", offset, err)
+			}
+			break
+		}
+
+		f.Repository.replay(rec)
+		offset += int64(len(line))
+		f.records++
+	}
+
+	if err := wal.Truncate(offset); err != nil {
+		wal.Close()
+		return fmt.Errorf("could not truncate wal: %w", err)
+	}
+
+	if _, err := wal.Seek(offset, io.SeekStart); err != nil {
+		wal.Close()
+		return fmt.Errorf("could not seek wal: %w", err)
+	}
+
+	f.wal = wal
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,529 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRepository_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+
+	_, err = repo.CreateEvent(model.Event{UserId: 1, Text: "Event 1", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	require.NoError(t, err)
+	_, err = repo.CreateEvent(model.Event{UserId: 1, Text: "Event 2", Start: model.Date(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))})
+	require.NoError(t, err)
+
+	newText := "Updated Event 1"
+	_, err = repo.UpdateEvent(model.UpdateEvent{EventId: intPtr(1), UserId: intPtr(1), Text: &newText})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 2}))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	events, err := reopened.GetEventsForDay(1, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Updated Event 1", events[0].Text)
+}
+
+func TestFileRepository_IdsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 1)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 1, 15, i, 0, 0, 0, time.UTC))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 3}))
+	require.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 2}))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 1)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	event, err := reopened.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))})
+	require.NoError(t, err)
+	assert.Equal(t, 4, event.EventId)
+}
+
+func TestFileRepository_Calendars(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+
+	start := model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	_, err = repo.CreateEvent(model.Event{UserId: 1, Text: "Default", Start: start})
+	require.NoError(t, err)
+	work, err := repo.CreateCalendar(model.Calendar{UserId: 1, Name: "Work"})
+	require.NoError(t, err)
+	_, err = repo.CreateEvent(model.Event{UserId: 1, CalendarId: work.CalendarId, Text: "Work", Start: start})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteCalendar(1, work.CalendarId))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	calendars, err := reopened.GetCalendars(1)
+	assert.NoError(t, err)
+	assert.Len(t, calendars, 1)
+
+	events, err := reopened.GetEvents(1)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Default", events[0].Text)
+
+	// id календарей не переиспользуются
+	personal, err := reopened.CreateCalendar(model.Calendar{UserId: 1, Name: "Personal"})
+	require.NoError(t, err)
+	assert.Equal(t, work.CalendarId+1, personal.CalendarId)
+}
+
+func TestFileRepository_ACL(t *testing.T) {
+	dir := t.TempDir()
+
+	// снимок после третьей записи: доступы восстанавливаются и из снимка, и из журнала
+	repo, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1, 2, 3, 4)
+
+	work, err := repo.CreateCalendar(model.Calendar{UserId: 1, Name: "Work"})
+	require.NoError(t, err)
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 2, Role: model.RoleViewer})
+	require.NoError(t, err)
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 3, Role: model.RoleFreeBusy})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteACL(1, work.CalendarId, 2))
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 4, Role: model.RoleEditor})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	entries, err := reopened.GetACL(1, work.CalendarId)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ACLEntry{
+		{CalendarId: work.CalendarId, UserId: 3, Role: model.RoleFreeBusy},
+		{CalendarId: work.CalendarId, UserId: 4, Role: model.RoleEditor},
+	}, entries)
+}
+
+func TestFileRepository_Users(t *testing.T) {
+	dir := t.TempDir()
+
+	// журнал до появления пользователей: пользователи появлялись с событиями
+	legacy := `{"op":"put_event","event":{"event_id":1,"user_id":3,"text":"Event","start":"2024-01-15T10:00:00Z"}}` + "\n" +
+		`{"op":"put_event","event":{"event_id":2,"user_id":4,"text":"Event","start":"2024-01-16T10:00:00Z"}}` + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, walFileName), []byte(legacy), 0644))
+
+	repo, err := NewFile(dir, 3)
+	require.NoError(t, err)
+
+	for _, userId := range []int{3, 4} {
+		_, err := repo.GetUser(userId)
+		assert.NoError(t, err)
+	}
+	alice, err := repo.CreateUser(model.User{Name: "Alice", Email: "alice@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 5, alice.UserId)
+	_, err = repo.DisableUser(alice.UserId)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteUser(3))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	restored, err := reopened.GetUser(alice.UserId)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", restored.Name)
+	assert.False(t, restored.Active())
+
+	_, err = reopened.GetUser(3)
+	assert.Equal(t, ErrNoSuchUser, err)
+
+	bob, err := reopened.CreateUser(model.User{Name: "Bob", Email: "bob@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 6, bob.UserId)
+}
+
+func TestFileRepository_Invitations(t *testing.T) {
+	dir := t.TempDir()
+
+	// снимок после третьей записи: приглашения восстанавливаются по событиям организатора
+	repo, err := NewFile(dir, 3)
+	require.NoError(t, err)
+
+	for _, user := range []model.User{{UserId: 1, Name: "Alice"}, {UserId: 2, Name: "Bob"}} {
+		_, err = repo.CreateUser(user)
+		require.NoError(t, err)
+	}
+	meeting, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Sync", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)), Attendees: []model.Attendee{{UserId: 2}}})
+	require.NoError(t, err)
+	_, err = repo.Respond(2, meeting.EventId, model.StatusTentative)
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 3)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	invitations, err := reopened.GetInvitations(2)
+	assert.NoError(t, err)
+	if assert.Len(t, invitations, 1) {
+		assert.Equal(t, []model.Attendee{{UserId: 2, Status: model.StatusTentative}}, invitations[0].Attendees)
+	}
+
+	t.Run("Deleted attendee after restart", func(t *testing.T) {
+		require.NoError(t, reopened.DeleteUser(2))
+		require.NoError(t, reopened.Close())
+
+		again, err := NewFile(dir, 3)
+		require.NoError(t, err)
+		defer again.Close()
+
+		_, err = again.CreateUser(model.User{UserId: 2, Name: "Dave"})
+		require.NoError(t, err)
+
+		invitations, err := again.GetInvitations(2)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+
+		event, err := again.GetEvent(1, meeting.EventId)
+		assert.NoError(t, err)
+		assert.Empty(t, event.Attendees)
+	})
+}
+
+func TestFileRepository_StaleAttendees(t *testing.T) {
+	dir := t.TempDir()
+
+	// снимок старой версии: пользователь 2 удален, но остался участником встречи
+	snapshot := `{"users":[{"user_id":1,"name":"Alice"}],"last_user_id":2,
+		"events":[{"event_id":1,"user_id":1,"text":"Sync","start":"2024-01-15T10:00:00Z","attendees":[{"user_id":2,"status":"accepted"}]}],
+		"last_event_id":1,"calendars":[],"acl":[]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(snapshot), 0644))
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	_, err = repo.CreateUser(model.User{UserId: 2, Name: "Bob"})
+	require.NoError(t, err)
+
+	invitations, err := repo.GetInvitations(2)
+	assert.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	_, err = repo.Respond(2, 1, model.StatusDeclined)
+	assert.Equal(t, ErrNoSuchInvitation, err)
+
+	event, err := repo.GetEvent(1, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, event.Attendees)
+}
+
+func TestFileRepository_Compaction(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 2)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+
+	for i := 0; i < 5; i++ {
+		_, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 1, 15, i, 0, 0, 0, time.UTC))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	_, err = os.Stat(filepath.Join(dir, snapshotFileName))
+	assert.NoError(t, err)
+
+	reopened, err := NewFile(dir, 2)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	events, err := reopened.GetEventsForDay(1, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, events, 5)
+}
+
+func TestFileRepository_TornWALRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+	_, err = repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = wal.WriteString(`{"op":"put_event","event":{"user_id":1`)
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	reopened, err := NewFile(dir, 100)
+	require.NoError(t, err)
+
+	_, err = reopened.CreateEvent(model.Event{UserId: 1, Text: "After crash", Start: model.Date(time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC))})
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+
+	again, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	defer again.Close()
+
+	events, err := again.GetEventsForDay(1, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestFileRepository_CorruptWALRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+	for i := 0; i < 2; i++ {
+		_, err = repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 1, 15, 10+i, 0, 0, 0, time.UTC))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	// испорченная запись в середине журнала, за ней остаются целые
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	require.GreaterOrEqual(t, len(lines), 2)
+	lines[0] = `{"op":"put_event","event":{"user_id":1` + "\n"
+	corrupted := strings.Join(lines, "")
+	require.NoError(t, os.WriteFile(path, []byte(corrupted), 0644))
+
+	_, err = NewFile(dir, 100)
+	assert.ErrorContains(t, err, "corrupt wal record")
+
+	// журнал не обрезан, записи после испорченной сохранились
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, corrupted, string(data))
+}
+
+// файл журнала с отказами: запись обрывается на половине, fsync и обрезка падают
+type faultyWAL struct {
+	*os.File
+	failWrite, failSync, failTruncate bool
+}
+
+func (w *faultyWAL) Write(p []byte) (int, error) {
+	if w.failWrite {
+		n, _ := w.File.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return w.File.Write(p)
+}
+
+func (w *faultyWAL) Sync() error {
+	if w.failSync {
+		return errors.New("sync failed")
+	}
+	return w.File.Sync()
+}
+
+func (w *faultyWAL) Truncate(size int64) error {
+	if w.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return w.File.Truncate(size)
+}
+
+func TestFileRepository_FailedWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+	wal := &faultyWAL{File: repo.wal.(*os.File)}
+	repo.wal = wal
+
+	create := func(text string) error {
+		_, err := repo.CreateEvent(model.Event{UserId: 1, Text: text, Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+		return err
+	}
+
+	wal.failWrite = true
+	assert.Error(t, create("Torn"))
+	wal.failWrite = false
+
+	wal.failSync = true
+	assert.Error(t, create("Not synced"))
+	wal.failSync = false
+
+	// после отката журнал пишется дальше
+	require.NoError(t, create("Saved"))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	events, err := reopened.GetEvents(1)
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "Saved", events[0].Text)
+	}
+
+	t.Run("Rollback fails", func(t *testing.T) {
+		wal := &faultyWAL{File: reopened.wal.(*os.File), failWrite: true, failTruncate: true}
+		reopened.wal = wal
+
+		_, err := reopened.CreateEvent(model.Event{UserId: 1, Text: "Lost", Start: model.Date(time.Now())})
+		assert.ErrorIs(t, err, ErrWALFailed)
+
+		// журнал больше не принимает записи
+		wal.failWrite, wal.failTruncate = false, false
+		_, err = reopened.CreateEvent(model.Event{UserId: 1, Text: "Rejected", Start: model.Date(time.Now())})
+		assert.ErrorIs(t, err, ErrWALFailed)
+
+		events, err := reopened.GetEvents(1)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+}
+
+func TestFileRepository_SplitSeriesIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+
+	series, err := repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Standup",
+		Start:      model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+		End:        model.Date(time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)),
+		Recurrence: "FREQ=WEEKLY",
+	})
+	require.NoError(t, err)
+
+	wal := &faultyWAL{File: repo.wal.(*os.File)}
+	repo.wal = wal
+
+	occurrence := model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	split := func() (model.Event, error) {
+		return repo.UpdateEvent(model.UpdateEvent{
+			EventId:    &series.EventId,
+			UserId:     intPtr(1),
+			Text:       stringPtr("Standup v2"),
+			Occurrence: &occurrence,
+			Scope:      model.ScopeFollowing,
+		})
+	}
+
+	// серия не обрезается, если новую часть не удалось записать
+	wal.failSync = true
+	_, err = split()
+	assert.Error(t, err)
+	wal.failSync = false
+
+	events, err := repo.GetEvents(1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "FREQ=WEEKLY", events[0].Recurrence)
+
+	// обе части серии - одна запись журнала
+	records := func() int {
+		data, err := os.ReadFile(wal.Name())
+		require.NoError(t, err)
+		return strings.Count(string(data), "\n")
+	}
+	before := records()
+	tail, err := split()
+	require.NoError(t, err)
+	assert.Equal(t, before+1, records())
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	events, err = reopened.GetEvents(1)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "FREQ=WEEKLY;UNTIL=20240115T095959Z", events[0].Recurrence)
+	assert.Equal(t, tail.EventId, events[1].EventId)
+	assert.Equal(t, "Standup v2", events[1].Text)
+}
+
+func TestFileRepository_DefaultCalendarWithFirstEvent(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	registerUsers(t, repo, 1)
+	wal := &faultyWAL{File: repo.wal.(*os.File)}
+	repo.wal = wal
+
+	create := func() (model.Event, error) {
+		return repo.CreateEvent(model.Event{UserId: 1, Text: "First", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	}
+
+	// календарь по умолчанию не создается без события
+	wal.failSync = true
+	_, err = create()
+	assert.Error(t, err)
+	wal.failSync = false
+
+	calendars, err := repo.GetCalendars(1)
+	require.NoError(t, err)
+	assert.Empty(t, calendars)
+
+	// календарь и событие - одна запись журнала
+	data, err := os.ReadFile(wal.Name())
+	require.NoError(t, err)
+	before := strings.Count(string(data), "\n")
+
+	event, err := create()
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(wal.Name())
+	require.NoError(t, err)
+	assert.Equal(t, before+1, strings.Count(string(data), "\n"))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFile(dir, 100)
+	require.NoError(t, err)
+	calendars, err = reopened.GetCalendars(1)
+	require.NoError(t, err)
+	require.Len(t, calendars, 1)
+	assert.True(t, calendars[0].Default)
+	assert.Equal(t, calendars[0].CalendarId, event.CalendarId)
+
+	events, err := reopened.GetEvents(1)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
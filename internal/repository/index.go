@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+)
+
+// события на весь день сравниваются по датам в зоне запроса, поэтому их моменты
+// времени могут отличаться от границ окна на смещение зоны (от -12 до +14 часов)
+const maxZoneOffset = 14 * time.Hour
+
+// конец серии без COUNT и UNTIL
+var endOfTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// timeIndex - интервальное дерево событий одного пользователя: декартово дерево
+// по (start, event_id), в каждом узле хранится максимальный конец в поддереве.
+// Поиск пересечений с окном занимает O(log n + k).
+type timeIndex struct {
+	root  *indexNode
+	nodes map[int]*indexNode
+}
+
+// ключ узла: события с одинаковым началом упорядочены по id
+type indexKey struct {
+	start   time.Time
+	eventId int
+}
+
+type indexNode struct {
+	key      indexKey
+	event    *model.Event
+	end      time.Time
+	maxEnd   time.Time
+	priority uint64
+	left     *indexNode
+	right    *indexNode
+}
+
+func newTimeIndex() *timeIndex {
+	return &timeIndex{nodes: make(map[int]*indexNode)}
+}
+
+func (idx *timeIndex) put(event *model.Event) {
+	idx.remove(event.EventId)
+
+	start, end := indexBounds(event)
+	node := &indexNode{
+		key:      indexKey{start: start, eventId: event.EventId},
+		event:    event,
+		end:      end,
+		maxEnd:   end,
+		priority: rand.Uint64(),
+	}
+
+	left, right := split(idx.root, node.key)
+	idx.root = merge(merge(left, node), right)
+	idx.nodes[event.EventId] = node
+}
+
+func (idx *timeIndex) remove(eventId int) {
+	node, ok := idx.nodes[eventId]
+	if !ok {
+		return
+	}
+
+	left, rest := split(idx.root, node.key)
+	_, right := split(rest, indexKey{start: node.key.start, eventId: eventId + 1})
+	idx.root = merge(left, right)
+	delete(idx.nodes, eventId)
+}
+
+func (idx *timeIndex) get(eventId int) (*model.Event, bool) {
+	node, ok := idx.nodes[eventId]
+	if !ok {
+		return nil, false
+	}
+
+	return node.event, true
+}
+
+// between вызывает fn для событий, чьи границы в индексе пересекают [from, to]
+func (idx *timeIndex) between(from, to time.Time, fn func(*model.Event)) {
+	search(idx.root, from, to, fn)
+}
+
+func search(node *indexNode, from, to time.Time, fn func(*model.Event)) {
+	if node == nil || node.maxEnd.Before(from) {
+		return
+	}
+
+	search(node.left, from, to, fn)
+
+	// правее только события, начинающиеся еще позже
+	if node.key.start.After(to) {
+		return
+	}
+
+	if !node.end.Before(from) {
+		fn(node.event)
+	}
+
+	search(node.right, from, to, fn)
+}
+
+// split делит дерево на узлы с ключом меньше key и не меньше key
+func split(node *indexNode, key indexKey) (*indexNode, *indexNode) {
+	if node == nil {
+		return nil, nil
+	}
+
+	if node.key.less(key) {
+		left, right := split(node.right, key)
+		node.right = left
+		node.update()
+		return node, right
+	}
+
+	left, right := split(node.left, key)
+	node.left = right
+	node.update()
+	return left, node
+}
+
+// merge объединяет деревья, если все ключи left меньше ключей right
+func merge(left, right *indexNode) *indexNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	if left.priority > right.priority {
+		left.right = merge(left.right, right)
+		left.update()
+		return left
+	}
+
+	right.left = merge(left, right.left)
+	right.update()
+	return right
+}
+
+func (k indexKey) less(other indexKey) bool {
+	if !k.start.Equal(other.start) {
+		return k.start.Before(other.start)
+	}
+
+	return k.eventId < other.eventId
+}
+
+func (n *indexNode) update() {
+	n.maxEnd = n.end
+	if n.left != nil && n.left.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.left.maxEnd
+	}
+	if n.right != nil && n.right.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.right.maxEnd
+	}
+}
+
+// indexBounds - промежуток, который событие может занимать: для серии от начала
+// первого до конца последнего повторения с учетом перенесенных экземпляров
+func indexBounds(event *model.Event) (time.Time, time.Time) {
+	start, end := time.Time(event.Start), time.Time(event.End)
+	if !event.IsRecurring() {
+		return start, end
+	}
+
+	// запас в сутки покрывает разницу UNTIL и начала последнего повторения в зоне серии
+	if last := seriesLastStart(event); last.Equal(endOfTime) {
+		end = endOfTime
+	} else {
+		end = last.Add(end.Sub(start) + 24*time.Hour)
+	}
+
+	for _, override := range event.Overrides {
+		if time.Time(override.Start).Before(start) {
+			start = time.Time(override.Start)
+		}
+		if time.Time(override.End).After(end) {
+			end = time.Time(override.End)
+		}
+	}
+
+	return start, end
+}
+
+func seriesLastStart(series *model.Event) time.Time {
+	rule, err := rrule.Parse(series.Recurrence)
+	if err != nil {
+		return time.Time(series.Start)
+	}
+
+	switch {
+	case !rule.Until.IsZero():
+		return rule.UntilIn(seriesStart(series).Location())
+	case rule.Count > 0:
+		// вызывается под r.mu: Last перебирает периоды, только если число повторений
+		// в них меняется, и не дальше rrule.MaxCountYears лет. Серия, не закончившаяся
+		// за это время (такие отклоняет checkRecurrence), индексируется без конца.
+		last, ok := rule.Last(seriesStart(series))
+		if !ok {
+			return endOfTime
+		}
+		return last
+	default:
+		return endOfTime
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// заполняет хранилище событиями одного пользователя, разбросанными по нескольким годам:
+// короткие встречи, многодневные и события на весь день
+func fillRepository(t testing.TB, repo *Repository, count int, seed uint64) {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < count; i++ {
+		start := base.Add(time.Duration(rnd.Int64N(int64(5 * 365 * 24 * time.Hour)))).Truncate(time.Minute)
+		event := model.Event{UserId: 1, Text: "Event", Start: model.Date(start)}
+
+		switch rnd.IntN(10) {
+		case 0:
+			event.End = model.Date(start.Add(time.Duration(rnd.IntN(20*24)) * time.Hour))
+		case 1:
+			event.AllDay = true
+			event.End = model.Date(start.AddDate(0, 0, 1+rnd.IntN(3)))
+		default:
+			event.End = model.Date(start.Add(time.Duration(rnd.IntN(180)) * time.Minute))
+		}
+
+		_, err := repo.CreateEvent(event)
+		require.NoError(t, err)
+	}
+}
+
+func TestTimeIndexMatchesScan(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+	fillRepository(t, repo, 2000, 1)
+
+	_, err := repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Weekly",
+		Start:      model.Date(time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)),
+		End:        model.Date(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)),
+		Recurrence: "FREQ=WEEKLY;COUNT=20",
+	})
+	require.NoError(t, err)
+
+	_, err = repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Monthly forever",
+		Start:      model.Date(time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)),
+		Recurrence: "FREQ=MONTHLY",
+	})
+	require.NoError(t, err)
+
+	// удаленные события не должны оставаться в индексе
+	for eventId := 1; eventId <= 2000; eventId += 7 {
+		require.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: eventId}))
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	honolulu, err := time.LoadLocation("Pacific/Honolulu")
+	require.NoError(t, err)
+
+	rnd := rand.New(rand.NewPCG(2, 2))
+	for _, loc := range []*time.Location{time.UTC, tokyo, honolulu} {
+		for i := 0; i < 200; i++ {
+			from := time.Date(2020+rnd.IntN(5), time.Month(1+rnd.IntN(12)), 1+rnd.IntN(28), 0, 0, 0, 0, loc)
+			to := from.AddDate(0, 0, 1+rnd.IntN(40))
+
+			indexed := repo.eventsInWindow(1, Window{From: from, To: to})
+			scanned := eventsBetween(repo.events[1], from, to)
+			assert.ElementsMatch(t, scanned, indexed, "%s - %s", from, to)
+		}
+	}
+}
+
+func TestTimeIndexSeriesBounds(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	series, err := repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Daily",
+		Start:      model.Date(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)),
+		End:        model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+		Recurrence: "FREQ=DAILY;UNTIL=20240110T090000Z",
+	})
+	require.NoError(t, err)
+
+	t.Run("Finished series is skipped", func(t *testing.T) {
+		var found []*model.Event
+		repo.index[1].between(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), func(event *model.Event) {
+			found = append(found, event)
+		})
+		assert.Empty(t, found)
+	})
+
+	t.Run("Moved occurrence extends series bounds", func(t *testing.T) {
+		moved := model.Date(time.Date(2024, 2, 20, 9, 0, 0, 0, time.UTC))
+		_, err := repo.UpdateEvent(model.UpdateEvent{
+			UserId:     &series.UserId,
+			EventId:    &series.EventId,
+			Start:      &moved,
+			Occurrence: &series.Start,
+			Scope:      model.ScopeThis,
+		})
+		require.NoError(t, err)
+
+		events := repo.eventsInWindow(1, MonthWindow(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+		require.Len(t, events, 1)
+		assert.Equal(t, moved, events[0].Start)
+	})
+}
+
+func TestTimeIndexSeriesWithLargeCount(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, rule := range []string{
+		fmt.Sprintf("FREQ=DAILY;COUNT=%d", rrule.MaxCount),
+		fmt.Sprintf("FREQ=WEEKLY;BYDAY=MO,WE;COUNT=%d", rrule.MaxCount),
+		// больше rrule.MaxCount: правило не разбирается и не разворачивается
+		"FREQ=DAILY;COUNT=1000000000",
+	} {
+		_, err := repo.CreateEvent(model.Event{
+			UserId:     1,
+			Text:       rule,
+			Start:      model.Date(start),
+			End:        model.Date(start.Add(time.Hour)),
+			Recurrence: rule,
+		})
+		require.NoError(t, err)
+	}
+
+	// последний ежедневный повтор - через 9999 дней, по понедельникам и средам - через 4999 недель
+	lastDaily := start.AddDate(0, 0, rrule.MaxCount-1)
+	lastWeekly := start.AddDate(0, 0, 7*(rrule.MaxCount/2-1)+2)
+
+	_, end := indexBounds(repo.events[1][0])
+	assert.Equal(t, lastDaily.Add(25*time.Hour), end)
+	_, end = indexBounds(repo.events[1][1])
+	assert.Equal(t, lastWeekly.Add(25*time.Hour), end)
+
+	events := repo.eventsInWindow(1, DayWindow(lastDaily))
+	require.Len(t, events, 1)
+	assert.Equal(t, model.Date(lastDaily), events[0].Start)
+	assert.Empty(t, repo.eventsInWindow(1, DayWindow(lastWeekly.AddDate(0, 0, 1))))
+}
+
+func TestTimeIndexSparseCountSeries(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	start := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)
+	event := model.Event{
+		UserId:     1,
+		Text:       "Leap day",
+		Start:      model.Date(start),
+		End:        model.Date(start.Add(time.Hour)),
+		Recurrence: "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29;COUNT=10000",
+	}
+
+	// до конца серии ~14.6 млн дневных периодов: она отклоняется, а не перебирается под r.mu
+	began := time.Now()
+	_, err := repo.CreateEvent(event)
+	assert.ErrorIs(t, err, ErrRecurrenceTooLong)
+	assert.Less(t, time.Since(began), 500*time.Millisecond)
+
+	// такая серия из старого журнала индексируется без конца
+	event.EventId = 1
+	began = time.Now()
+	repo.apply(record{Op: opPutEvent, Event: &event})
+	assert.Less(t, time.Since(began), 500*time.Millisecond)
+
+	_, end := indexBounds(repo.events[1][0])
+	assert.Equal(t, endOfTime, end)
+
+	event.Recurrence = "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29;COUNT=5"
+	created, err := repo.CreateEvent(event)
+	require.NoError(t, err)
+	_, end = indexBounds(&created)
+	assert.Equal(t, time.Date(2040, 2, 29, 10, 0, 0, 0, time.UTC).Add(24*time.Hour), end)
+
+	sparse := "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29;COUNT=10000"
+	_, err = repo.UpdateEvent(model.UpdateEvent{UserId: &created.UserId, EventId: &created.EventId, Recurrence: &sparse})
+	assert.ErrorIs(t, err, ErrRecurrenceTooLong)
+}
+
+// сравнение выборки за месяц через индекс и полным перебором
+func BenchmarkGetEventsForMonth(b *testing.B) {
+	for _, count := range []int{1000, 10000, 100000} {
+		repo := New()
+		registerUsers(b, repo, 1)
+		fillRepository(b, repo, count, 1)
+		date := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		b.Run(fmt.Sprintf("Index/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				repo.GetEventsForMonth(1, date)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Scan/%d", count), func(b *testing.B) {
+			window := MonthWindow(date)
+			for i := 0; i < b.N; i++ {
+				eventsBetween(repo.events[1], window.From, window.To)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"slices"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+const (
+	opPutEvent    = "put_event"
+	opPutEvents   = "put_events" // несколько событий, которые сохраняются только вместе
+	opDeleteEvent = "delete_event"
+
+	opPutCalendar    = "put_calendar"
+	opDeleteCalendar = "delete_calendar"
+	opPutACL         = "put_acl"
+	opDeleteACL      = "delete_acl"
+
+	opPutUser    = "put_user"
+	opDeleteUser = "delete_user"
+)
+
+// record - одно изменение состояния хранилища, в таком виде оно пишется в журнал
+type record struct {
+	Op       string              `json:"op"`
+	Event    *model.Event        `json:"event,omitempty"`
+	Events   []*model.Event      `json:"events,omitempty"`
+	UserId   int                 `json:"user_id,omitempty"`
+	EventId  int                 `json:"event_id,omitempty"`
+
+	Calendar   *model.Calendar `json:"calendar,omitempty"`
+	CalendarId int             `json:"calendar_id,omitempty"`
+	ACL        *model.ACLEntry `json:"acl,omitempty"`
+	User       *model.User     `json:"user,omitempty"`
+}
+
+// journal получает каждое изменение до того, как оно будет применено в памяти
+type journal interface {
+	append(rec record) error
+}
+
+// вызывается под r.mu: сначала запись в журнал (если он есть), потом изменение в памяти
+func (r *Repository) commit(rec record) error {
+	if r.journal != nil {
+		if err := r.journal.append(rec); err != nil {
+			return err
+		}
+	}
+
+	r.apply(rec)
+	return nil
+}
+
+// replay применяет запись журнала при восстановлении. В журналах до появления
+// регистрации пользователь появлялся вместе с первым событием, и для таких
+// записей он создается без имени и почты. Новые события без пользователя не пишутся.
+func (r *Repository) replay(rec record) {
+	if rec.Op == opPutEvent {
+		r.ensureUser(rec.Event.UserId, rec.Event.CreatedAt)
+	}
+
+	r.apply(rec)
+}
+
+// apply идемпотентен, поэтому повторное применение журнала поверх снапшота безопасно
+func (r *Repository) apply(rec record) {
+	switch rec.Op {
+	case opPutEvent:
+		// новый календарь по умолчанию пишется вместе с первым событием в нем
+		if rec.Calendar != nil {
+			r.putCalendar(rec.Calendar)
+		}
+		r.putEvent(rec.Event)
+	case opPutEvents:
+		for _, event := range rec.Events {
+			r.putEvent(event)
+		}
+	case opDeleteEvent:
+		r.removeEvent(rec.UserId, rec.EventId)
+	case opPutCalendar:
+		r.putCalendar(rec.Calendar)
+	case opDeleteCalendar:
+		r.removeCalendar(rec.UserId, rec.CalendarId)
+	case opPutACL:
+		r.putACL(rec.ACL)
+	case opDeleteACL:
+		r.removeACL(rec.CalendarId, rec.UserId)
+	case opPutUser:
+		r.putUser(rec.User)
+	case opDeleteUser:
+		r.removeUser(rec.UserId)
+	}
+}
+
+func (r *Repository) putEvent(event *model.Event) {
+	event = r.withExistingAttendees(event)
+	if event.EventId > r.lastEventId {
+		r.lastEventId = event.EventId
+	}
+
+	index, ok := r.index[event.UserId]
+	if !ok {
+		index = newTimeIndex()
+		r.index[event.UserId] = index
+	}
+	previous, exists := index.get(event.EventId)
+	index.put(event)
+	r.indexAttendees(previous, event)
+	if previous != nil {
+		r.unindexUID(previous)
+	}
+	r.indexUID(event)
+
+	events := r.events[event.UserId]
+	if !exists {
+		r.events[event.UserId] = append(events, event)
+		return
+	}
+
+	for i, stored := range events {
+		if stored.EventId == event.EventId {
+			events[i] = event
+			return
+		}
+	}
+}
+
+func (r *Repository) removeEvent(userId int, eventId int) {
+	events, ok := r.events[userId]
+	if !ok {
+		return
+	}
+
+	if event, ok := r.getEventByUserId(userId, eventId); ok {
+		for _, attendee := range event.Attendees {
+			r.unindex(attendee.UserId, eventId)
+		}
+		r.unindexUID(event)
+	}
+	r.unindex(userId, eventId)
+
+	// удаляем с сохранением порядка, чтобы выборки оставались стабильными
+	r.events[userId] = slices.DeleteFunc(events, func(event *model.Event) bool {
+		return event.EventId == eventId
+	})
+}
+
+func (r *Repository) indexUID(event *model.Event) {
+	if event.UID == "" {
+		return
+	}
+
+	uids, ok := r.uids[event.UserId]
+	if !ok {
+		uids = make(map[string]int)
+		r.uids[event.UserId] = uids
+	}
+	uids[event.UID] = event.EventId
+}
+
+func (r *Repository) unindexUID(event *model.Event) {
+	if uids, ok := r.uids[event.UserId]; ok && uids[event.UID] == event.EventId {
+		delete(uids, event.UID)
+	}
+}
+
+// snapshot - полное состояние хранилища, из которого можно восстановиться без журнала
+type snapshot struct {
+	Events      []*model.Event `json:"events"`
+	LastEventId int            `json:"last_event_id"`
+
+	Users      []*model.User `json:"users"`
+	LastUserId int           `json:"last_user_id"`
+
+	Calendars      []*model.Calendar `json:"calendars"`
+	LastCalendarId int               `json:"last_calendar_id"`
+	ACL            []*model.ACLEntry `json:"acl"`
+}
+
+func (r *Repository) snapshot() snapshot {
+	s := snapshot{LastEventId: r.lastEventId, LastCalendarId: r.lastCalendarId, LastUserId: r.lastUserId}
+	for _, events := range r.events {
+		s.Events = append(s.Events, events...)
+	}
+	for _, user := range r.users {
+		s.Users = append(s.Users, user)
+	}
+	for _, calendar := range r.calendars {
+		s.Calendars = append(s.Calendars, calendar)
+	}
+	for calendarId, roles := range r.acl {
+		for userId, role := range roles {
+			s.ACL = append(s.ACL, &model.ACLEntry{CalendarId: calendarId, UserId: userId, Role: role})
+		}
+	}
+
+	return s
+}
+
+func (r *Repository) restore(s snapshot) {
+	r.lastEventId = s.LastEventId
+	r.lastCalendarId = s.LastCalendarId
+	r.lastUserId = s.LastUserId
+	for _, user := range s.Users {
+		r.putUser(user)
+	}
+	for _, calendar := range s.Calendars {
+		r.putCalendar(calendar)
+	}
+	for _, entry := range s.ACL {
+		r.putACL(entry)
+	}
+	for _, event := range s.Events {
+		r.ensureUser(event.UserId, event.CreatedAt)
+		r.putEvent(event)
+	}
+}
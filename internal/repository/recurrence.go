@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+)
+
+// occurrencesBetween лениво разворачивает серию и возвращает только экземпляры,
+// пересекающиеся с полуинтервалом [from, to). Отмененные экземпляры пропускаются,
+// измененные подставляются вместо исходных. Разворачивание начинается недалеко от
+// from: раньше могут начинаться только экземпляры, которые длятся до from, и
+// события на весь день, которые в зоне from сдвигаются на ее смещение.
+func occurrencesBetween(series *model.Event, from, to time.Time) []*model.Event {
+	rule, err := rrule.Parse(series.Recurrence)
+	if err != nil {
+		return nil
+	}
+
+	duration := time.Time(series.End).Sub(time.Time(series.Start))
+	var result []*model.Event
+	rule.IterateFrom(seriesStart(series), from.Add(-duration-maxZoneOffset), func(start time.Time) bool {
+		instance := occurrence(series, start)
+
+		inZone := instance.In(from.Location())
+		if !time.Time(inZone.Start).Before(to) {
+			return false
+		}
+
+		if !isException(series, start) && inZone.Overlaps(from, to) {
+			result = append(result, &instance)
+		}
+		return true
+	})
+
+	// измененный экземпляр мог переехать в окно из-за его границ, поэтому проверяем их отдельно
+	for _, override := range series.Overrides {
+		if isCancelled(series, time.Time(*override.RecurrenceId)) {
+			continue
+		}
+
+		if override.In(from.Location()).Overlaps(from, to) {
+			instance := *override
+			instance.EventId, instance.Recurrence, instance.Version = series.EventId, series.Recurrence, series.Version
+			result = append(result, &instance)
+		}
+	}
+
+	return result
+}
+
+// checkRecurrence отклоняет серию с COUNT, конец которой не находится за
+// rrule.MaxCountYears лет: ее индексирование и выборки перебирали бы периоды без
+// ограничения. Неразборчивые правила отклоняет валидация.
+func checkRecurrence(series *model.Event) error {
+	rule, err := rrule.Parse(series.Recurrence)
+	if err != nil || rule.Count == 0 {
+		return nil
+	}
+
+	if _, ok := rule.Last(seriesStart(series)); !ok {
+		return ErrRecurrenceTooLong
+	}
+
+	return nil
+}
+
+// начало серии в ее зоне: от зоны зависит настенное время повторений после перехода на летнее время
+func seriesStart(series *model.Event) time.Time {
+	start := time.Time(series.Start)
+	if series.TimeZone == "" || series.AllDay {
+		return start
+	}
+
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return start
+	}
+
+	return start.In(loc)
+}
+
+// экземпляр серии, начинающийся в start, с той же длительностью
+func occurrence(series *model.Event, start time.Time) model.Event {
+	instance := *series
+	seriesStart, seriesEnd := time.Time(series.Start), time.Time(series.End)
+
+	if series.AllDay {
+		days := int(seriesEnd.Sub(seriesStart).Round(24*time.Hour) / (24 * time.Hour))
+		instance.End = model.Date(start.AddDate(0, 0, days))
+	} else {
+		instance.End = model.Date(start.Add(seriesEnd.Sub(seriesStart)))
+	}
+
+	instance.Start = model.Date(start)
+	originalStart := model.Date(start)
+	instance.RecurrenceId = &originalStart
+	instance.ExDates = nil
+	instance.Overrides = nil
+
+	return instance
+}
+
+// findOccurrence ищет экземпляр серии с исходным началом start и возвращает его точное начало
+func findOccurrence(series *model.Event, start time.Time) (time.Time, bool) {
+	rule, err := rrule.Parse(series.Recurrence)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var found time.Time
+	rule.IterateFrom(seriesStart(series), start.Add(-24*time.Hour-maxZoneOffset), func(t time.Time) bool {
+		if sameOccurrence(series, t, start) {
+			found = t
+			return false
+		}
+		return t.Before(start.Add(24 * time.Hour))
+	})
+
+	return found, !found.IsZero() && !isCancelled(series, found)
+}
+
+// число экземпляров серии, начинающихся раньше start
+func countBefore(series *model.Event, rule rrule.Rule, start time.Time) int {
+	count := 0
+	rule.Iterate(seriesStart(series), func(t time.Time) bool {
+		if !t.Before(start) {
+			return false
+		}
+		count++
+		return true
+	})
+
+	return count
+}
+
+// у событий на весь день экземпляр определяется датой, у остальных - моментом времени
+func sameOccurrence(series *model.Event, a, b time.Time) bool {
+	if series.AllDay {
+		return a.Format(time.DateOnly) == b.Format(time.DateOnly)
+	}
+
+	return a.Equal(b)
+}
+
+func isCancelled(series *model.Event, start time.Time) bool {
+	return slices.ContainsFunc(series.ExDates, func(d model.Date) bool {
+		return sameOccurrence(series, time.Time(d), start)
+	})
+}
+
+func isException(series *model.Event, start time.Time) bool {
+	return isCancelled(series, start) || overrideIndex(series, start) >= 0
+}
+
+func overrideIndex(series *model.Event, start time.Time) int {
+	return slices.IndexFunc(series.Overrides, func(o *model.Event) bool {
+		return sameOccurrence(series, time.Time(*o.RecurrenceId), start)
+	})
+}
+
+// копия серии, которую можно менять, не затрагивая сохраненную версию
+func cloneSeries(series *model.Event) model.Event {
+	event := *series
+	event.ExDates = slices.Clone(series.ExDates)
+	event.Overrides = slices.Clone(series.Overrides)
+
+	return event
+}
+
+func occurrenceScope(scope string) string {
+	if scope == "" {
+		return model.ScopeThis
+	}
+
+	return scope
+}
+
+// вызывается под r.mu
+func (r *Repository) updateOccurrence(series *model.Event, updateEvent model.UpdateEvent) (model.Event, error) {
+	start, ok := findOccurrence(series, time.Time(*updateEvent.Occurrence))
+	if !ok {
+		return model.Event{}, ErrNoSuchOccurrence
+	}
+
+	if updateEvent.Recurrence != nil || updateEvent.TimeZone != nil || updateEvent.CalendarId != nil || updateEvent.Attendees != nil {
+		return model.Event{}, ErrInvalidOccurrenceUpdate
+	}
+
+	event := cloneSeries(series)
+
+	instance := occurrence(series, start)
+	i := overrideIndex(series, start)
+	if i >= 0 {
+		instance = *series.Overrides[i]
+	}
+
+	if err := applyUpdate(&instance, updateEvent); err != nil {
+		return model.Event{}, err
+	}
+
+	if i >= 0 {
+		event.Overrides[i] = &instance
+	} else {
+		event.Overrides = append(event.Overrides, &instance)
+	}
+
+	saved, err := r.saveEvent(event)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	// экземпляр меняется вместе с серией и получает ее версию
+	instance.Version = saved.Version
+	return instance, nil
+}
+
+// при изменении всей серии через экземпляр сдвиг начала экземпляра переносится на начало серии
+func (r *Repository) updateWholeSeries(series *model.Event, updateEvent model.UpdateEvent) (model.Event, error) {
+	if updateEvent.Start != nil {
+		newStart := time.Time(*updateEvent.Start)
+		shifted := model.Date(time.Time(series.Start).Add(newStart.Sub(time.Time(*updateEvent.Occurrence))))
+
+		if updateEvent.End != nil {
+			end := model.Date(time.Time(shifted).Add(time.Time(*updateEvent.End).Sub(newStart)))
+			updateEvent.End = &end
+		}
+		updateEvent.Start = &shifted
+	}
+
+	event := cloneSeries(series)
+	if err := applyUpdate(&event, updateEvent); err != nil {
+		return model.Event{}, err
+	}
+
+	return r.saveEvent(event)
+}
+
+// splitSeries завершает серию перед экземпляром и начинает с него новую серию с изменениями
+func (r *Repository) splitSeries(series *model.Event, updateEvent model.UpdateEvent) (model.Event, error) {
+	start, ok := findOccurrence(series, time.Time(*updateEvent.Occurrence))
+	if !ok {
+		return model.Event{}, ErrNoSuchOccurrence
+	}
+
+	if start.Equal(seriesStart(series)) {
+		return r.updateWholeSeries(series, updateEvent)
+	}
+
+	head, tail, err := splitAt(series, start)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	// UID принадлежит исходной серии и остается у head, у tail он строится по новому id
+	tail.EventId = r.lastEventId + 1
+	tail.UID = ""
+	tail.Version = 0
+	tail.CreatedAt = model.Date{}
+	if err := applyUpdate(&tail, updateEvent); err != nil {
+		return model.Event{}, err
+	}
+
+	// обе части пишутся одной записью: иначе после ошибки на tail серия осталась бы обрезанной
+	saved, err := r.saveEvents(head, tail)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	return saved[1], nil
+}
+
+func (r *Repository) cancelOccurrence(series *model.Event, occurrence time.Time) error {
+	start, ok := findOccurrence(series, occurrence)
+	if !ok {
+		return ErrNoSuchOccurrence
+	}
+
+	event := cloneSeries(series)
+	event.ExDates = append(event.ExDates, model.Date(start))
+	if i := overrideIndex(series, start); i >= 0 {
+		event.Overrides = slices.Delete(event.Overrides, i, i+1)
+	}
+
+	_, err := r.saveEvent(event)
+	return err
+}
+
+// truncateSeries удаляет экземпляр и все следующие за ним
+func (r *Repository) truncateSeries(series *model.Event, occurrence time.Time) error {
+	start, ok := findOccurrence(series, occurrence)
+	if !ok {
+		return ErrNoSuchOccurrence
+	}
+
+	if start.Equal(seriesStart(series)) {
+		return r.commit(record{Op: opDeleteEvent, UserId: series.UserId, EventId: series.EventId})
+	}
+
+	head, _, err := splitAt(series, start)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.saveEvent(head)
+	return err
+}
+
+// splitAt делит серию на экземпляры до start и начиная с start. Исключения
+// достаются той части, к которой относятся. У второй части нет id.
+func splitAt(series *model.Event, start time.Time) (model.Event, model.Event, error) {
+	rule, err := rrule.Parse(series.Recurrence)
+	if err != nil {
+		return model.Event{}, model.Event{}, err
+	}
+
+	head := cloneSeries(series)
+	tail := occurrence(series, start)
+	tail.RecurrenceId = nil
+
+	headRule, tailRule := rule, rule
+	if rule.Count > 0 {
+		headRule.Count = countBefore(series, rule, start)
+		tailRule.Count = rule.Count - headRule.Count
+	} else {
+		headRule.Until, headRule.Floating = start.Add(-time.Second), false
+	}
+	head.Recurrence, tail.Recurrence = headRule.String(), tailRule.String()
+
+	head.ExDates, tail.ExDates = nil, nil
+	for _, date := range series.ExDates {
+		if time.Time(date).Before(start) {
+			head.ExDates = append(head.ExDates, date)
+		} else {
+			tail.ExDates = append(tail.ExDates, date)
+		}
+	}
+
+	head.Overrides, tail.Overrides = nil, nil
+	for _, override := range series.Overrides {
+		if time.Time(*override.RecurrenceId).Before(start) {
+			head.Overrides = append(head.Overrides, override)
+		} else {
+			tail.Overrides = append(tail.Overrides, override)
+		}
+	}
+
+	return head, tail, nil
+}
@@ -1,157 +1,281 @@
 package repository
 
 import (
-	"errors"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/Komilov31/calendar-service/internal/apperror"
 	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
 )
 
 var (
-	ErrNoSuchEvent = errors.New("no such event in database")
-	ErrNoSuchUser  = errors.New("no such user in database")
+	ErrNoSuchEvent = apperror.NotFound("event_not_found", "no such event in database")
+	ErrNoSuchUser  = apperror.NotFound("user_not_found", "no such user in database")
+
+	ErrNoSuchCalendar  = apperror.NotFound("calendar_not_found", "no such calendar in database")
+	ErrDefaultCalendar = apperror.Forbidden("default_calendar", "default calendar cannot be deleted")
+
+	ErrEndBeforeStart = apperror.Validation("end_before_start", "event end is before its start")
+
+	ErrVersionMismatch = apperror.PreconditionFailed("version_mismatch", "event was changed by another request")
+
+	ErrNoSuchOccurrence        = apperror.NotFound("occurrence_not_found", "no such occurrence in recurring event")
+	ErrRecurrenceTooLong       = apperror.Validation("recurrence_too_long", fmt.Sprintf("recurrence rule with COUNT must end within %d years", rrule.MaxCountYears))
+	ErrInvalidOccurrenceUpdate = apperror.Validation("invalid_occurrence_update", "recurrence rule, time zone, calendar and attendees can be changed only for the whole series or following occurrences")
 )
 
 type Repository struct {
-	mu     *sync.RWMutex
-	events map[int][]*model.Event
+	mu          *sync.RWMutex
+	events      map[int][]*model.Event
+	index       map[int]*timeIndex     // события пользователя, упорядоченные по времени
+	uids        map[int]map[string]int // id событий пользователя по сохраненному UID
+	lastEventId int                    // id событий глобальные и никогда не переиспользуются
+	journal     journal
+
+	users      map[int]*model.User
+	lastUserId int
+
+	calendars      map[int]*model.Calendar
+	lastCalendarId int
+	acl            map[int]map[int]string // роли пользователей по id календаря
 }
 
 func New() *Repository {
 	return &Repository{
-		mu:     &sync.RWMutex{},
-		events: make(map[int][]*model.Event),
+		mu:        &sync.RWMutex{},
+		events:    make(map[int][]*model.Event),
+		index:     make(map[int]*timeIndex),
+		uids:      make(map[int]map[string]int),
+		users:     make(map[int]*model.User),
+		calendars: make(map[int]*model.Calendar),
+		acl:       make(map[int]map[int]string),
 	}
 }
 
-func (r *Repository) CreateEvent(event model.Event) model.Event {
+func (r *Repository) CreateEvent(event model.Event) (model.Event, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	events := r.events[event.UserId]
 
-	event.EventId = len(events) + 1
-	events = append(events, &event)
+	if _, ok := r.users[event.UserId]; !ok {
+		return model.Event{}, ErrNoSuchUser
+	}
+
+	calendar, created, err := r.eventCalendar(event.UserId, event.CalendarId)
+	if err != nil {
+		return model.Event{}, err
+	}
+	event.CalendarId = calendar.CalendarId
+	if event.TimeZone == "" && !event.AllDay {
+		event.TimeZone = calendar.TimeZone
+	}
+
+	event.EventId = r.lastEventId + 1
+	event.RecurrenceId = nil
+	event.Version = 0
+	event.CreatedAt = model.Date{}
+	event.Attendees = mergeAttendees(nil, event.Attendees, event.UserId, false)
+	event.Normalize()
+	if err := checkRecurrence(&event); err != nil {
+		return model.Event{}, err
+	}
 
-	r.events[event.UserId] = events
+	// новый календарь по умолчанию не должен остаться без события, если его запись не удалась
+	if created {
+		return r.saveEventWith(event, &calendar)
+	}
 
-	return event
+	return r.saveEvent(event)
 }
 
 func (r *Repository) UpdateEvent(updateEvent model.UpdateEvent) (model.Event, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	event, ok := r.getEventByUserId(*updateEvent.UserId, *updateEvent.EventId)
+	stored, ok := r.getEventByUserId(*updateEvent.UserId, *updateEvent.EventId)
 	if !ok {
 		return model.Event{}, ErrNoSuchEvent
 	}
 
-	if updateEvent.Text != nil {
-		event.Text = *updateEvent.Text
+	if !versionMatches(stored, updateEvent.IfMatch) {
+		return model.Event{}, ErrVersionMismatch
 	}
 
-	if updateEvent.Date != nil {
-		event.Date = *updateEvent.Date
+	if updateEvent.CalendarId != nil {
+		if _, ok := r.getCalendarByUserId(stored.UserId, *updateEvent.CalendarId); !ok {
+			return model.Event{}, ErrNoSuchCalendar
+		}
 	}
 
-	return *event, nil
+	if updateEvent.Occurrence == nil || !stored.IsRecurring() {
+		event := *stored
+		if err := applyUpdate(&event, updateEvent); err != nil {
+			return model.Event{}, err
+		}
+		return r.saveEvent(event)
+	}
+
+	switch occurrenceScope(updateEvent.Scope) {
+	case model.ScopeThis:
+		return r.updateOccurrence(stored, updateEvent)
+	case model.ScopeFollowing:
+		return r.splitSeries(stored, updateEvent)
+	default:
+		return r.updateWholeSeries(stored, updateEvent)
+	}
 }
 
-func (r *Repository) DeleteEvent(userId int, eventId int) error {
+func (r *Repository) DeleteEvent(deleteEvent model.DeleteEvent) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	events, ok := r.events[userId]
-	if !ok {
+	if _, ok := r.users[deleteEvent.UserId]; !ok {
 		return ErrNoSuchUser
 	}
 
-	found := false
-	for i, event := range events {
-		if event.EventId == eventId {
-			found = true
-			events[i] = events[len(events)-1]
-			events = events[:len(events)-1]
-			break
-		}
+	stored, ok := r.getEventByUserId(deleteEvent.UserId, deleteEvent.EventId)
+	if !ok {
+		return ErrNoSuchEvent
 	}
 
-	if !found {
-		return ErrNoSuchEvent
+	if !versionMatches(stored, deleteEvent.IfMatch) {
+		return ErrVersionMismatch
 	}
 
-	r.events[userId] = events
+	if deleteEvent.Occurrence != nil && stored.IsRecurring() {
+		switch occurrenceScope(deleteEvent.Scope) {
+		case model.ScopeThis:
+			return r.cancelOccurrence(stored, time.Time(*deleteEvent.Occurrence))
+		case model.ScopeFollowing:
+			return r.truncateSeries(stored, time.Time(*deleteEvent.Occurrence))
+		}
+	}
 
-	return nil
+	return r.commit(record{Op: opDeleteEvent, UserId: deleteEvent.UserId, EventId: deleteEvent.EventId})
 }
 
-func (r *Repository) GetEventsForDay(userId int, date time.Time) ([]*model.Event, error) {
+func (r *Repository) GetEvent(userId int, eventId int) (model.Event, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	events, ok := r.events[userId]
+	event, ok := r.getEventByUserId(userId, eventId)
 	if !ok {
-		return nil, ErrNoSuchUser
+		event, ok = r.getInvitation(userId, eventId)
+	}
+	if !ok {
+		return model.Event{}, ErrNoSuchEvent
 	}
 
-	var eventsForDay []*model.Event
-	for _, event := range events {
-		curDate := time.Time(event.Date)
-		eventsDay, eventsYear := curDate.Day(), curDate.Year()
-		day, year := date.Day(), date.Year()
+	return *event, nil
+}
 
-		if eventsDay == day && eventsYear == year {
-			eventsForDay = append(eventsForDay, event)
-		}
+// GetEventByUID возвращает событие пользователя с сохраненным UID, например из
+// импортированного календаря
+func (r *Repository) GetEventByUID(userId int, uid string) (model.Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	eventId, ok := r.uids[userId][uid]
+	if !ok {
+		return model.Event{}, ErrNoSuchEvent
 	}
 
-	return eventsForDay, nil
+	event, ok := r.getEventByUserId(userId, eventId)
+	if !ok {
+		return model.Event{}, ErrNoSuchEvent
+	}
+
+	return *event, nil
 }
 
-func (r *Repository) GetEventsForWeek(userId int, date time.Time) ([]*model.Event, error) {
+// GetEvents возвращает все события пользователя в порядке создания, серии не разворачиваются
+func (r *Repository) GetEvents(userId int) ([]*model.Event, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	events, ok := r.events[userId]
-	if !ok {
+	if _, ok := r.users[userId]; !ok {
 		return nil, ErrNoSuchUser
 	}
 
-	var eventsForWeek []*model.Event
-	for _, event := range events {
-		curDate := time.Time(event.Date)
+	return slices.Clone(r.events[userId]), nil
+}
 
-		currentYear, currentWeek := date.ISOWeek()
-		eventYear, eventWeek := curDate.ISOWeek()
+func (r *Repository) GetEventsForDay(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, DayWindow(date))
+}
 
-		if currentYear == eventYear && currentWeek == eventWeek {
-			eventsForWeek = append(eventsForWeek, event)
-		}
-	}
+func (r *Repository) GetEventsForWeek(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, LocaleWeekWindow(date, r.firstWeekday(userId)))
+}
 
-	return eventsForWeek, nil
+// GetEventsForISOWeek - неделя с понедельника независимо от week_start пользователя
+func (r *Repository) GetEventsForISOWeek(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, ISOWeekWindow(date))
 }
 
 func (r *Repository) GetEventsForMonth(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, MonthWindow(date))
+}
+
+func (r *Repository) GetEventsForQuarter(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, QuarterWindow(date))
+}
+
+func (r *Repository) GetEventsForYear(userId int, date time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, YearWindow(date))
+}
+
+// GetEventsInRange возвращает события, пересекающиеся с полуинтервалом [from, to),
+// упорядоченные по началу
+func (r *Repository) GetEventsInRange(userId int, from, to time.Time) ([]*model.Event, error) {
+	return r.GetEventsInWindow(userId, RangeWindow(from, to))
+}
+
+// GetEventsInWindow возвращает события, пересекающиеся с календарным периодом,
+// упорядоченные по началу в зоне периода
+func (r *Repository) GetEventsInWindow(userId int, window Window) ([]*model.Event, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	events, ok := r.events[userId]
-	if !ok {
+	if _, ok := r.users[userId]; !ok {
 		return nil, ErrNoSuchUser
 	}
 
-	var eventsForMonth []*model.Event
-	for _, event := range events {
-		curDate := time.Time(event.Date)
-		eventsMonth, eventsYear := curDate.Month(), curDate.Year()
-		month, year := date.Month(), date.Year()
+	return r.eventsInWindow(userId, window), nil
+}
 
-		if eventsMonth == month && eventsYear == year {
-			eventsForMonth = append(eventsForMonth, event)
-		}
+// UpdateUserSettings меняет зону зарегистрированного пользователя
+func (r *Repository) UpdateUserSettings(settings model.UserSettings) (model.UserSettings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[settings.UserId]
+	if !ok {
+		return model.UserSettings{}, ErrNoSuchUser
+	}
+
+	user := *stored
+	user.TimeZone = settings.TimeZone
+
+	user, err := r.saveUser(user)
+	if err != nil {
+		return model.UserSettings{}, err
+	}
+
+	return user.Settings(), nil
+}
+
+// для пользователя без сохраненной зоны возвращаются настройки по умолчанию
+func (r *Repository) GetUserSettings(userId int) (model.UserSettings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userId]
+	if !ok {
+		return model.User{UserId: userId}.Settings(), nil
 	}
 
-	return eventsForMonth, nil
+	return user.Settings(), nil
 }
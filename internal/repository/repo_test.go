@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/Komilov31/calendar-service/internal/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -18,28 +20,35 @@ func TestNew(t *testing.T) {
 
 func TestCreateEvent(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1, 2)
 
 	t.Run("Create first event for user", func(t *testing.T) {
+		before := time.Now()
+
 		event := model.Event{
 			UserId: 1,
 			Text:   "Test Event 1",
-			Date:   model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
+			Start:  model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
 		}
 
-		result := repo.CreateEvent(event)
+		result, err := repo.CreateEvent(event)
+		assert.NoError(t, err)
 		assert.Equal(t, 1, result.EventId)
 		assert.Equal(t, "Test Event 1", result.Text)
 		assert.Equal(t, 1, result.UserId)
+		assert.False(t, time.Time(result.CreatedAt).Before(before))
+		assert.Equal(t, result.CreatedAt, result.UpdatedAt)
 	})
 
 	t.Run("Create second event for same user", func(t *testing.T) {
 		event := model.Event{
 			UserId: 1,
 			Text:   "Test Event 2",
-			Date:   model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
+			Start:  model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
 		}
 
-		result := repo.CreateEvent(event)
+		result, err := repo.CreateEvent(event)
+		assert.NoError(t, err)
 		assert.Equal(t, 2, result.EventId)
 		assert.Equal(t, "Test Event 2", result.Text)
 	})
@@ -48,30 +57,37 @@ func TestCreateEvent(t *testing.T) {
 		event := model.Event{
 			UserId: 2,
 			Text:   "Test Event for User 2",
-			Date:   model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC)),
+			Start:  model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC)),
 		}
 
-		result := repo.CreateEvent(event)
-		assert.Equal(t, 1, result.EventId)
+		result, err := repo.CreateEvent(event)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.EventId)
 		assert.Equal(t, "Test Event for User 2", result.Text)
 		assert.Equal(t, 2, result.UserId)
 	})
+
+	t.Run("Unregistered user", func(t *testing.T) {
+		_, err := repo.CreateEvent(model.Event{UserId: 3, Text: "Test", Start: model.Date(time.Now())})
+		assert.Equal(t, ErrNoSuchUser, err)
+	})
 }
 
 func TestUpdateEvent(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1)
 
 	event1 := model.Event{
 		UserId: 1,
 		Text:   "Original Text",
-		Date:   model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event1)
 
 	event2 := model.Event{
 		UserId: 1,
 		Text:   "Another Event",
-		Date:   model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event2)
 
@@ -87,7 +103,7 @@ func TestUpdateEvent(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 1, result.EventId)
 		assert.Equal(t, "Updated Text", result.Text)
-		assert.Equal(t, model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)), result.Date)
+		assert.Equal(t, model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)), result.Start)
 	})
 
 	t.Run("Update event date only", func(t *testing.T) {
@@ -95,14 +111,14 @@ func TestUpdateEvent(t *testing.T) {
 		updateEvent := model.UpdateEvent{
 			EventId: intPtr(2),
 			UserId:  intPtr(1),
-			Date:    &newDate,
+			Start:   &newDate,
 		}
 
 		result, err := repo.UpdateEvent(updateEvent)
 		assert.NoError(t, err)
 		assert.Equal(t, 2, result.EventId)
 		assert.Equal(t, "Another Event", result.Text)
-		assert.Equal(t, newDate, result.Date)
+		assert.Equal(t, newDate, result.Start)
 	})
 
 	t.Run("Update both text and date", func(t *testing.T) {
@@ -112,14 +128,14 @@ func TestUpdateEvent(t *testing.T) {
 			EventId: intPtr(1),
 			UserId:  intPtr(1),
 			Text:    &newText,
-			Date:    &newDate,
+			Start:   &newDate,
 		}
 
 		result, err := repo.UpdateEvent(updateEvent)
 		assert.NoError(t, err)
 		assert.Equal(t, 1, result.EventId)
 		assert.Equal(t, newText, result.Text)
-		assert.Equal(t, newDate, result.Date)
+		assert.Equal(t, newDate, result.Start)
 	})
 
 	t.Run("Update non-existent event", func(t *testing.T) {
@@ -151,30 +167,31 @@ func TestUpdateEvent(t *testing.T) {
 
 func TestDeleteEvent(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1, 2)
 
 	event1 := model.Event{
 		UserId: 1,
 		Text:   "Event 1",
-		Date:   model.Date(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event1)
 
 	event2 := model.Event{
 		UserId: 1,
 		Text:   "Event 2",
-		Date:   model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event2)
 
 	event3 := model.Event{
 		UserId: 2,
 		Text:   "Event for User 2",
-		Date:   model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event3)
 
 	t.Run("Delete existing event", func(t *testing.T) {
-		err := repo.DeleteEvent(1, 1)
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 1})
 		assert.NoError(t, err)
 
 		events, _ := repo.GetEventsForDay(1, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
@@ -183,47 +200,174 @@ func TestDeleteEvent(t *testing.T) {
 	})
 
 	t.Run("Delete non-existent event", func(t *testing.T) {
-		err := repo.DeleteEvent(1, 999)
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 999})
 		assert.Error(t, err)
 		assert.Equal(t, ErrNoSuchEvent, err)
 	})
 
 	t.Run("Delete event from non-existent user", func(t *testing.T) {
-		err := repo.DeleteEvent(999, 1)
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 999, EventId: 1})
 		assert.Error(t, err)
 		assert.Equal(t, ErrNoSuchUser, err)
 	})
 
+	t.Run("Delete keeps order of remaining events", func(t *testing.T) {
+		repo := New()
+		registerUsers(t, repo, 1)
+		for day := 1; day <= 4; day++ {
+			repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: model.Date(time.Date(2024, 2, day, 10, 0, 0, 0, time.UTC))})
+		}
+
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 1})
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForMonth(1, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 3)
+		assert.Equal(t, []int{2, 3, 4}, []int{events[0].EventId, events[1].EventId, events[2].EventId})
+	})
+
+	t.Run("Deleted ids are never reused", func(t *testing.T) {
+		event := model.Event{
+			UserId: 1,
+			Text:   "Event 4",
+			Start:  model.Date(time.Date(2024, 1, 18, 10, 0, 0, 0, time.UTC)),
+		}
+
+		result, err := repo.CreateEvent(event)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.EventId)
+
+		err = repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: 4})
+		assert.NoError(t, err)
+
+		result, err = repo.CreateEvent(event)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, result.EventId)
+	})
+}
+
+func TestGetEvent(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	created, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Meeting", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	assert.NoError(t, err)
+
+	event, err := repo.GetEvent(1, created.EventId)
+	assert.NoError(t, err)
+	assert.Equal(t, created, event)
+
+	_, err = repo.GetEvent(2, created.EventId)
+	assert.ErrorIs(t, err, ErrNoSuchEvent)
+
+	_, err = repo.GetEvent(1, created.EventId+1)
+	assert.ErrorIs(t, err, ErrNoSuchEvent)
+}
+
+func TestGetEventByUID(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	created, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Meeting", UID: "meeting@example.com", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	assert.NoError(t, err)
+
+	event, err := repo.GetEventByUID(1, "meeting@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, created, event)
+
+	_, err = repo.GetEventByUID(2, "meeting@example.com")
+	assert.ErrorIs(t, err, ErrNoSuchEvent)
+
+	assert.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: created.EventId}))
+	_, err = repo.GetEventByUID(1, "meeting@example.com")
+	assert.ErrorIs(t, err, ErrNoSuchEvent)
+}
+
+func TestEventVersion(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+	text := "Planning"
+
+	created, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Meeting", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created.Version)
+
+	update := model.UpdateEvent{UserId: &created.UserId, EventId: &created.EventId, Text: &text, IfMatch: []int{1}}
+	updated, err := repo.UpdateEvent(update)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	t.Run("Stale version is rejected", func(t *testing.T) {
+		_, err := repo.UpdateEvent(update)
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		err = repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: created.EventId, IfMatch: []int{1}})
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		stored, err := repo.GetEvent(1, created.EventId)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stored.Version)
+	})
+
+	t.Run("Any listed version matches", func(t *testing.T) {
+		update.IfMatch = []int{1, 2}
+		updated, err := repo.UpdateEvent(update)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, updated.Version)
+	})
+
+	t.Run("Occurrence carries series version", func(t *testing.T) {
+		series, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Standup", Recurrence: "FREQ=DAILY", Start: model.Date(time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC))})
+		assert.NoError(t, err)
+
+		occurrence := model.Date(time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC))
+		instance, err := repo.UpdateEvent(model.UpdateEvent{UserId: &series.UserId, EventId: &series.EventId, Text: &text, Occurrence: &occurrence, IfMatch: []int{1}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, instance.Version)
+
+		events, err := repo.GetEventsForDay(1, time.Time(occurrence))
+		assert.NoError(t, err)
+		for _, event := range events {
+			if event.EventId == series.EventId {
+				assert.Equal(t, 2, event.Version)
+			}
+		}
+
+		err = repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: series.EventId, IfMatch: []int{2}})
+		assert.NoError(t, err)
+	})
 }
 
 func TestGetEventsForDay(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1, 2)
 
 	event1 := model.Event{
 		UserId: 1,
 		Text:   "Event Jan 15",
-		Date:   model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event1)
 
 	event2 := model.Event{
 		UserId: 1,
 		Text:   "Event Jan 15 Afternoon",
-		Date:   model.Date(time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event2)
 
 	event3 := model.Event{
 		UserId: 1,
 		Text:   "Event Jan 16",
-		Date:   model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event3)
 
 	event4 := model.Event{
 		UserId: 2,
 		Text:   "Event for User 2",
-		Date:   model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
+		Start:  model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
 	}
 	repo.CreateEvent(event4)
 
@@ -270,13 +414,14 @@ func TestGetEventsForDay(t *testing.T) {
 
 func TestGetEventsForWeek(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1, 2)
 
 	events := []model.Event{
-		{UserId: 1, Text: "Monday Event", Date: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Wednesday Event", Date: model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Sunday Event", Date: model.Date(time.Date(2024, 1, 21, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Next Monday Event", Date: model.Date(time.Date(2024, 1, 22, 10, 0, 0, 0, time.UTC))},
-		{UserId: 2, Text: "User 2 Monday Event", Date: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Monday Event", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Wednesday Event", Start: model.Date(time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Sunday Event", Start: model.Date(time.Date(2024, 1, 21, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Next Monday Event", Start: model.Date(time.Date(2024, 1, 22, 10, 0, 0, 0, time.UTC))},
+		{UserId: 2, Text: "User 2 Monday Event", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
 	}
 
 	for _, event := range events {
@@ -324,13 +469,14 @@ func TestGetEventsForWeek(t *testing.T) {
 
 func TestGetEventsForMonth(t *testing.T) {
 	repo := New()
+	registerUsers(t, repo, 1, 2)
 
 	events := []model.Event{
-		{UserId: 1, Text: "Jan 1 Event", Date: model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Jan 15 Event", Date: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Jan 31 Event", Date: model.Date(time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC))},
-		{UserId: 1, Text: "Feb 1 Event", Date: model.Date(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC))},
-		{UserId: 2, Text: "User 2 Jan Event", Date: model.Date(time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Jan 1 Event", Start: model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Jan 15 Event", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Jan 31 Event", Start: model.Date(time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Feb 1 Event", Start: model.Date(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC))},
+		{UserId: 2, Text: "User 2 Jan Event", Start: model.Date(time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC))},
 	}
 
 	for _, event := range events {
@@ -376,6 +522,643 @@ func TestGetEventsForMonth(t *testing.T) {
 	})
 }
 
+func TestEventIntervals(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	events := []model.Event{
+		{UserId: 1, Text: "Mar 15 Event", Start: model.Date(time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC))},
+		{
+			UserId: 1,
+			Text:   "Conference",
+			Start:  model.Date(time.Date(2024, 1, 30, 18, 0, 0, 0, time.UTC)),
+			End:    model.Date(time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)),
+		},
+		{UserId: 1, Text: "Vacation", Start: model.Date(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)), End: model.Date(time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC)), AllDay: true},
+	}
+
+	for _, event := range events {
+		repo.CreateEvent(event)
+	}
+
+	t.Run("Same day number in another month is not returned", func(t *testing.T) {
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Multi-day event appears on every day it covers", func(t *testing.T) {
+		for _, day := range []int{30, 31} {
+			events, err := repo.GetEventsForDay(1, time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC))
+			assert.NoError(t, err)
+			assert.Len(t, events, 1)
+		}
+
+		events, err := repo.GetEventsForMonth(1, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("All-day end is exclusive", func(t *testing.T) {
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+
+		events, err = repo.GetEventsForDay(1, time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Event without end is an instant", func(t *testing.T) {
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, events[0].Start, events[0].End)
+	})
+
+	t.Run("Update keeps duration when only start moves", func(t *testing.T) {
+		newStart := model.Date(time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC))
+		result, err := repo.UpdateEvent(model.UpdateEvent{EventId: intPtr(2), UserId: intPtr(1), Start: &newStart})
+		assert.NoError(t, err)
+		assert.Equal(t, model.Date(time.Date(2024, 4, 4, 3, 0, 0, 0, time.UTC)), result.End)
+	})
+
+	t.Run("Update with end before start is rejected", func(t *testing.T) {
+		newEnd := model.Date(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+		_, err := repo.UpdateEvent(model.UpdateEvent{EventId: intPtr(2), UserId: intPtr(1), End: &newEnd})
+		assert.Equal(t, ErrEndBeforeStart, err)
+	})
+}
+
+func TestGetEventsInTimeZone(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	loc, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	events := []model.Event{
+		{UserId: 1, Text: "Late evening before", Start: model.Date(time.Date(2024, 3, 10, 4, 30, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Late evening on DST day", Start: model.Date(time.Date(2024, 3, 11, 3, 30, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Next morning", Start: model.Date(time.Date(2024, 3, 11, 4, 30, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Birthday", Start: model.Date(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)), AllDay: true},
+	}
+
+	for _, event := range events {
+		repo.CreateEvent(event)
+	}
+
+	t.Run("Day window follows the zone across DST transition", func(t *testing.T) {
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 3, 10, 0, 0, 0, 0, loc))
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, "Birthday", events[0].Text)
+		assert.Equal(t, "Late evening on DST day", events[1].Text)
+	})
+
+	t.Run("All-day event keeps its date in any zone", func(t *testing.T) {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 3, 10, 0, 0, 0, 0, tokyo))
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, "Birthday", events[0].Text)
+		assert.Equal(t, "Late evening before", events[1].Text)
+	})
+}
+
+func TestRecurringEvents(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1, 2)
+
+	standup, err := repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Standup",
+		Start:      model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)),
+		End:        model.Date(time.Date(2024, 1, 15, 10, 15, 0, 0, time.UTC)),
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+	})
+	assert.NoError(t, err)
+
+	repo.CreateEvent(model.Event{
+		UserId:     1,
+		Text:       "Retro",
+		Start:      model.Date(time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)),
+		AllDay:     true,
+		Recurrence: "FREQ=WEEKLY;INTERVAL=2;COUNT=2",
+	})
+
+	t.Run("Occurrences are expanded inside the window", func(t *testing.T) {
+		events, err := repo.GetEventsForWeek(1, time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 3)
+
+		for _, event := range events {
+			assert.Equal(t, standup.EventId, event.EventId)
+			assert.NotNil(t, event.RecurrenceId)
+			assert.Equal(t, *event.RecurrenceId, event.Start)
+			assert.Equal(t, 15*time.Minute, time.Time(event.End).Sub(time.Time(event.Start)))
+		}
+		assert.Equal(t, model.Date(time.Date(2024, 1, 24, 10, 0, 0, 0, time.UTC)), events[1].Start)
+	})
+
+	t.Run("Count limits the series", func(t *testing.T) {
+		events, err := repo.GetEventsForMonth(1, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+
+		retros := 0
+		for _, event := range events {
+			if event.Text == "Retro" {
+				retros++
+				assert.Equal(t, model.Date(time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)), event.Start)
+			}
+		}
+		assert.Equal(t, 1, retros)
+	})
+
+	t.Run("Series in a zone keeps wall clock time after DST", func(t *testing.T) {
+		repo.CreateEvent(model.Event{
+			UserId:     2,
+			Text:       "Planning",
+			Start:      model.Date(time.Date(2024, 3, 25, 8, 0, 0, 0, time.UTC)),
+			Recurrence: "FREQ=WEEKLY",
+			TimeZone:   "Europe/Berlin",
+		})
+
+		events, err := repo.GetEventsForDay(2, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, 7, time.Time(events[0].Start).UTC().Hour())
+	})
+
+	t.Run("Old series spanning into the window", func(t *testing.T) {
+		repo.CreateEvent(model.Event{
+			UserId:     2,
+			Text:       "Trip",
+			Start:      model.Date(time.Date(2000, 1, 1, 20, 0, 0, 0, time.UTC)),
+			End:        model.Date(time.Date(2000, 1, 6, 22, 0, 0, 0, time.UTC)),
+			Recurrence: "FREQ=DAILY",
+		})
+
+		// поездки длятся пять дней: в день попадают начавшиеся за пять дней до него
+		events, err := repo.GetEventsForDay(2, time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 6)
+		assert.Equal(t, model.Date(time.Date(2024, 6, 6, 20, 0, 0, 0, time.UTC)), events[0].Start)
+		assert.Equal(t, model.Date(time.Date(2024, 6, 11, 20, 0, 0, 0, time.UTC)), events[5].Start)
+	})
+}
+
+func TestRecurringEventExceptions(t *testing.T) {
+	newSeries := func(t *testing.T, rule string) (*Repository, model.Event) {
+		repo := New()
+		registerUsers(t, repo, 1)
+		series, err := repo.CreateEvent(model.Event{
+			UserId:     1,
+			Text:       "Standup",
+			Start:      model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+			End:        model.Date(time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)),
+			Recurrence: rule,
+		})
+		assert.NoError(t, err)
+		return repo, series
+	}
+
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	occurrence := model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	t.Run("Cancel single occurrence", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY")
+
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: series.EventId, Occurrence: &occurrence, Scope: model.ScopeThis})
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForMonth(1, january)
+		assert.NoError(t, err)
+		assert.Len(t, events, 4)
+		for _, event := range events {
+			assert.NotEqual(t, occurrence, event.Start)
+		}
+
+		err = repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: series.EventId, Occurrence: &occurrence, Scope: model.ScopeThis})
+		assert.Equal(t, ErrNoSuchOccurrence, err)
+	})
+
+	t.Run("Override single occurrence", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY")
+
+		moved := model.Date(time.Date(2024, 1, 16, 11, 0, 0, 0, time.UTC))
+		text := "Standup (moved)"
+		instance, err := repo.UpdateEvent(model.UpdateEvent{
+			EventId:    &series.EventId,
+			UserId:     intPtr(1),
+			Text:       &text,
+			Start:      &moved,
+			Occurrence: &occurrence,
+			Scope:      model.ScopeThis,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, occurrence, *instance.RecurrenceId)
+		assert.Equal(t, moved, instance.Start)
+
+		events, err := repo.GetEventsForDay(1, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+
+		events, err = repo.GetEventsForDay(1, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, text, events[0].Text)
+		assert.Equal(t, series.EventId, events[0].EventId)
+
+		events, err = repo.GetEventsForMonth(1, january)
+		assert.NoError(t, err)
+		assert.Len(t, events, 5)
+	})
+
+	t.Run("Update occurrence that does not exist", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY")
+
+		wrong := model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC))
+		_, err := repo.UpdateEvent(model.UpdateEvent{EventId: &series.EventId, UserId: intPtr(1), Text: stringPtr("x"), Occurrence: &wrong})
+		assert.Equal(t, ErrNoSuchOccurrence, err)
+	})
+
+	t.Run("Update this and following splits the series", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY;COUNT=5")
+
+		text := "Standup v2"
+		tail, err := repo.UpdateEvent(model.UpdateEvent{
+			EventId:    &series.EventId,
+			UserId:     intPtr(1),
+			Text:       &text,
+			Occurrence: &occurrence,
+			Scope:      model.ScopeFollowing,
+		})
+		assert.NoError(t, err)
+		assert.NotEqual(t, series.EventId, tail.EventId)
+		assert.Equal(t, occurrence, tail.Start)
+		assert.Equal(t, "FREQ=WEEKLY;COUNT=3", tail.Recurrence)
+
+		events, err := repo.GetEventsForMonth(1, january)
+		assert.NoError(t, err)
+		assert.Len(t, events, 5)
+
+		texts := map[string]int{}
+		for _, event := range events {
+			texts[event.Text]++
+		}
+		assert.Equal(t, map[string]int{"Standup": 2, "Standup v2": 3}, texts)
+	})
+
+	t.Run("Split series keeps its UID on the head", func(t *testing.T) {
+		repo := New()
+		registerUsers(t, repo, 1)
+		series, err := repo.CreateEvent(model.Event{
+			UserId:     1,
+			UID:        "abc@client",
+			Text:       "Standup",
+			Start:      model.Date(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+			End:        model.Date(time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)),
+			Recurrence: "FREQ=WEEKLY",
+		})
+		require.NoError(t, err)
+
+		tail, err := repo.UpdateEvent(model.UpdateEvent{
+			EventId:    &series.EventId,
+			UserId:     intPtr(1),
+			Text:       stringPtr("Standup v2"),
+			Occurrence: &occurrence,
+			Scope:      model.ScopeFollowing,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, tail.UID)
+
+		head, err := repo.GetEventByUID(1, "abc@client")
+		require.NoError(t, err)
+		assert.Equal(t, series.EventId, head.EventId)
+		assert.Equal(t, "Standup", head.Text)
+	})
+
+	t.Run("Delete this and following truncates the series", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY")
+
+		err := repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: series.EventId, Occurrence: &occurrence, Scope: model.ScopeFollowing})
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForMonth(1, january)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("Update all through an occurrence shifts the series", func(t *testing.T) {
+		repo, series := newSeries(t, "FREQ=WEEKLY")
+
+		later := model.Date(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
+		updated, err := repo.UpdateEvent(model.UpdateEvent{
+			EventId:    &series.EventId,
+			UserId:     intPtr(1),
+			Start:      &later,
+			Occurrence: &occurrence,
+			Scope:      model.ScopeAll,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, model.Date(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)), updated.Start)
+		assert.Equal(t, model.Date(time.Date(2024, 1, 1, 12, 15, 0, 0, time.UTC)), updated.End)
+	})
+}
+
+func TestGetEventsInRange(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	events := []model.Event{
+		{UserId: 1, Text: "Later", Start: model.Date(time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Earlier", Start: model.Date(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "At the end", Start: model.Date(time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC))},
+		{
+			UserId:     1,
+			Text:       "Daily",
+			Start:      model.Date(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)),
+			Recurrence: "FREQ=DAILY",
+		},
+	}
+
+	for _, event := range events {
+		repo.CreateEvent(event)
+	}
+
+	t.Run("Half-open range ordered by start", func(t *testing.T) {
+		from := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)
+
+		events, err := repo.GetEventsInRange(1, from, to)
+		assert.NoError(t, err)
+		assert.Len(t, events, 8)
+
+		for i := 1; i < len(events); i++ {
+			assert.False(t, time.Time(events[i].Start).Before(time.Time(events[i-1].Start)))
+		}
+		assert.Equal(t, "Daily", events[0].Text)
+		assert.Equal(t, "Earlier", events[1].Text)
+	})
+
+	t.Run("Non-existent user", func(t *testing.T) {
+		_, err := repo.GetEventsInRange(999, time.Now(), time.Now().Add(time.Hour))
+		assert.Equal(t, ErrNoSuchUser, err)
+	})
+}
+
+func TestUserSettings(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	settings, err := repo.GetUserSettings(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC", settings.TimeZone)
+
+	_, err = repo.UpdateUserSettings(model.UserSettings{UserId: 1, TimeZone: "Europe/Moscow"})
+	assert.NoError(t, err)
+
+	settings, err = repo.GetUserSettings(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Moscow", settings.TimeZone)
+
+	// настройки не регистрируют пользователя
+	_, err = repo.UpdateUserSettings(model.UserSettings{UserId: 2, TimeZone: "Europe/Moscow"})
+	assert.Equal(t, ErrNoSuchUser, err)
+	_, err = repo.GetUser(2)
+	assert.Equal(t, ErrNoSuchUser, err)
+}
+
+func TestCalendars(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1, 2)
+	start := model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	// первое событие без календаря создает календарь по умолчанию
+	first, err := repo.CreateEvent(model.Event{UserId: 1, Text: "Event", Start: start})
+	assert.NoError(t, err)
+
+	calendars, err := repo.GetCalendars(1)
+	assert.NoError(t, err)
+	assert.Len(t, calendars, 1)
+	assert.True(t, calendars[0].Default)
+	assert.Equal(t, calendars[0].CalendarId, first.CalendarId)
+
+	work, err := repo.CreateCalendar(model.Calendar{UserId: 1, Name: "Work", TimeZone: "Europe/Moscow"})
+	assert.NoError(t, err)
+	assert.False(t, work.Default)
+
+	t.Run("Event gets calendar time zone", func(t *testing.T) {
+		event, err := repo.CreateEvent(model.Event{UserId: 1, CalendarId: work.CalendarId, Text: "Standup", Start: start})
+		assert.NoError(t, err)
+		assert.Equal(t, work.CalendarId, event.CalendarId)
+		assert.Equal(t, "Europe/Moscow", event.TimeZone)
+	})
+
+	t.Run("Calendar of another user", func(t *testing.T) {
+		_, err := repo.CreateEvent(model.Event{UserId: 2, CalendarId: work.CalendarId, Text: "Event", Start: start})
+		assert.Equal(t, ErrNoSuchCalendar, err)
+
+		_, err = repo.GetCalendar(2, work.CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+	})
+
+	t.Run("Move event", func(t *testing.T) {
+		event, err := repo.UpdateEvent(model.UpdateEvent{EventId: intPtr(first.EventId), UserId: intPtr(1), CalendarId: intPtr(work.CalendarId)})
+		assert.NoError(t, err)
+		assert.Equal(t, work.CalendarId, event.CalendarId)
+
+		_, err = repo.UpdateEvent(model.UpdateEvent{EventId: intPtr(first.EventId), UserId: intPtr(1), CalendarId: intPtr(999)})
+		assert.Equal(t, ErrNoSuchCalendar, err)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updated, err := repo.UpdateCalendar(model.Calendar{UserId: 1, CalendarId: work.CalendarId, Name: "Office", Color: "#ff0000"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Office", updated.Name)
+		assert.Equal(t, "#ff0000", updated.Color)
+		assert.Equal(t, work.CreatedAt, updated.CreatedAt)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, ErrDefaultCalendar, repo.DeleteCalendar(1, calendars[0].CalendarId))
+
+		assert.NoError(t, repo.DeleteCalendar(1, work.CalendarId))
+		_, err := repo.GetCalendar(1, work.CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+
+		// события календаря удаляются вместе с ним
+		events, err := repo.GetEvents(1)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestACL(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1, 2, 3)
+	_, err := repo.CreateCalendar(model.Calendar{UserId: 1, Name: "Personal"})
+	assert.NoError(t, err)
+	work, err := repo.CreateCalendar(model.Calendar{UserId: 1, Name: "Work"})
+	assert.NoError(t, err)
+
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 3, Role: model.RoleViewer})
+	assert.NoError(t, err)
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 2, Role: model.RoleViewer})
+	assert.NoError(t, err)
+
+	// повторная выдача меняет роль
+	_, err = repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 2, Role: model.RoleEditor})
+	assert.NoError(t, err)
+
+	entries, err := repo.GetACL(1, work.CalendarId)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ACLEntry{
+		{CalendarId: work.CalendarId, UserId: 2, Role: model.RoleEditor},
+		{CalendarId: work.CalendarId, UserId: 3, Role: model.RoleViewer},
+	}, entries)
+
+	t.Run("Unknown grantee", func(t *testing.T) {
+		_, err := repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 4, Role: model.RoleViewer})
+		assert.Equal(t, ErrNoSuchUser, err)
+
+		entries, err := repo.GetACL(1, work.CalendarId)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("Owner and unknown calendar", func(t *testing.T) {
+		_, err := repo.PutACL(1, model.ACLEntry{CalendarId: work.CalendarId, UserId: 1, Role: model.RoleViewer})
+		assert.Equal(t, ErrOwnerACL, err)
+
+		_, err = repo.PutACL(1, model.ACLEntry{CalendarId: 999, UserId: 2, Role: model.RoleViewer})
+		assert.Equal(t, ErrNoSuchCalendar, err)
+	})
+
+	t.Run("Calendar of another user", func(t *testing.T) {
+		_, err := repo.PutACL(2, model.ACLEntry{CalendarId: work.CalendarId, UserId: 2, Role: model.RoleOwner})
+		assert.Equal(t, ErrNoSuchCalendar, err)
+
+		_, err = repo.GetACL(2, work.CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+
+		assert.Equal(t, ErrNoSuchCalendar, repo.DeleteACL(2, work.CalendarId, 3))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteACL(1, work.CalendarId, 3))
+		assert.Equal(t, ErrNoSuchACL, repo.DeleteACL(1, work.CalendarId, 3))
+
+		entries, err := repo.GetACL(1, work.CalendarId)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("Deleted with calendar", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteCalendar(1, work.CalendarId))
+
+		_, err := repo.GetACL(1, work.CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+	})
+}
+
+func TestUsers(t *testing.T) {
+	repo := New()
+
+	alice, err := repo.CreateUser(model.User{Name: "Alice", Email: "alice@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, alice.UserId)
+	assert.False(t, alice.CreatedAt.IsZero())
+
+	bob, err := repo.CreateUser(model.User{UserId: 10, Name: "Bob", Email: "bob@example.com", WeekStart: model.WeekStartSunday})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, bob.UserId)
+
+	t.Run("Conflicts", func(t *testing.T) {
+		_, err := repo.CreateUser(model.User{UserId: 10, Name: "Bob", Email: "other@example.com"})
+		assert.Equal(t, ErrUserExists, err)
+
+		_, err = repo.CreateUser(model.User{Name: "Alice", Email: "ALICE@example.com"})
+		assert.Equal(t, ErrEmailTaken, err)
+
+		// id выдаются после самого большого занятого
+		carol, err := repo.CreateUser(model.User{Name: "Carol", Email: "carol@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 11, carol.UserId)
+	})
+
+	t.Run("User without events", func(t *testing.T) {
+		events, err := repo.GetEvents(alice.UserId)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+
+		events, err = repo.GetEventsForDay(alice.UserId, time.Now())
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Week start", func(t *testing.T) {
+		// воскресенье попадает в неделю Боба, которая начинается с воскресенья
+		sunday := time.Date(2024, 1, 14, 10, 0, 0, 0, time.UTC)
+		_, err := repo.CreateEvent(model.Event{UserId: bob.UserId, Text: "Brunch", Start: model.Date(sunday)})
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForWeek(bob.UserId, sunday.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updated, err := repo.UpdateUser(model.User{UserId: alice.UserId, Name: "Alice B.", Email: "alice@example.com", TimeZone: "Europe/Moscow"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Alice B.", updated.Name)
+		assert.Equal(t, alice.CreatedAt, updated.CreatedAt)
+
+		settings, err := repo.GetUserSettings(alice.UserId)
+		assert.NoError(t, err)
+		assert.Equal(t, "Europe/Moscow", settings.TimeZone)
+
+		_, err = repo.UpdateUser(model.User{UserId: 999, Name: "Nobody"})
+		assert.Equal(t, ErrNoSuchUser, err)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		disabled, err := repo.DisableUser(alice.UserId)
+		assert.NoError(t, err)
+		assert.False(t, disabled.Active())
+
+		// данные отключенного пользователя остаются
+		_, err = repo.GetEvents(alice.UserId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		calendars, err := repo.GetCalendars(bob.UserId)
+		assert.NoError(t, err)
+		if !assert.Len(t, calendars, 1) {
+			return
+		}
+
+		_, err = repo.PutACL(bob.UserId, model.ACLEntry{CalendarId: calendars[0].CalendarId, UserId: alice.UserId, Role: model.RoleViewer})
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.DeleteUser(bob.UserId))
+		assert.Equal(t, ErrNoSuchUser, repo.DeleteUser(bob.UserId))
+
+		_, err = repo.GetEvents(bob.UserId)
+		assert.Equal(t, ErrNoSuchUser, err)
+
+		_, err = repo.GetCalendar(bob.UserId, calendars[0].CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+
+		_, err = repo.GetACL(bob.UserId, calendars[0].CalendarId)
+		assert.Equal(t, ErrNoSuchCalendar, err)
+	})
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -383,3 +1166,162 @@ func intPtr(i int) *int {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestInvitations(t *testing.T) {
+	repo := New()
+	for _, user := range []model.User{
+		{UserId: 1, Name: "Alice", Email: "alice@example.com"},
+		{UserId: 2, Name: "Bob", Email: "bob@example.com"},
+		{UserId: 3, Name: "Carol", Email: "carol@example.com"},
+	} {
+		_, err := repo.CreateUser(user)
+		assert.NoError(t, err)
+	}
+
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)
+	meeting, err := repo.CreateEvent(model.Event{
+		UserId: 1,
+		Text:   "Planning",
+		Start:  model.Date(start),
+		End:    model.Date(start.Add(time.Hour)),
+		// организатор в списке не нужен, присланный ответ не учитывается
+		Attendees: []model.Attendee{{UserId: 1}, {UserId: 2, Status: model.StatusAccepted}, {UserId: 3}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Attendee{
+		{UserId: 2, Status: model.StatusNeedsAction},
+		{UserId: 3, Status: model.StatusNeedsAction},
+	}, meeting.Attendees)
+
+	t.Run("Visible to attendees", func(t *testing.T) {
+		events, err := repo.GetEventsForDay(2, start)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, meeting.EventId, events[0].EventId)
+		}
+
+		event, err := repo.GetEvent(3, meeting.EventId)
+		assert.NoError(t, err)
+		assert.Equal(t, "Planning", event.Text)
+
+		invitations, err := repo.GetInvitations(2)
+		assert.NoError(t, err)
+		assert.Len(t, invitations, 1)
+
+		// у организатора приглашений нет, а его список событий не меняется
+		invitations, err = repo.GetInvitations(1)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+
+		events, err = repo.GetEvents(2)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Only organizer changes event", func(t *testing.T) {
+		text := "Hijacked"
+		_, err := repo.UpdateEvent(model.UpdateEvent{UserId: intPtr(2), EventId: &meeting.EventId, Text: &text})
+		assert.Equal(t, ErrNoSuchEvent, err)
+
+		assert.Equal(t, ErrNoSuchEvent, repo.DeleteEvent(model.DeleteEvent{UserId: 2, EventId: meeting.EventId}))
+	})
+
+	t.Run("Respond", func(t *testing.T) {
+		event, err := repo.Respond(2, meeting.EventId, model.StatusAccepted)
+		assert.NoError(t, err)
+		attendee, _ := event.Attendee(2)
+		assert.Equal(t, model.StatusAccepted, attendee.Status)
+
+		_, err = repo.Respond(1, meeting.EventId, model.StatusAccepted)
+		assert.Equal(t, ErrNoSuchInvitation, err)
+
+		_, err = repo.Respond(2, 999, model.StatusAccepted)
+		assert.Equal(t, ErrNoSuchInvitation, err)
+	})
+
+	t.Run("Organizer updates fan out", func(t *testing.T) {
+		text := "Quarterly planning"
+		_, err := repo.UpdateEvent(model.UpdateEvent{UserId: intPtr(1), EventId: &meeting.EventId, Text: &text})
+		assert.NoError(t, err)
+
+		event, err := repo.GetEvent(2, meeting.EventId)
+		assert.NoError(t, err)
+		assert.Equal(t, text, event.Text)
+
+		// ответ сохраняется, пока время встречи не меняется
+		attendee, _ := event.Attendee(2)
+		assert.Equal(t, model.StatusAccepted, attendee.Status)
+	})
+
+	t.Run("Reschedule resets responses", func(t *testing.T) {
+		moved := model.Date(start.AddDate(0, 0, 1))
+		_, err := repo.UpdateEvent(model.UpdateEvent{UserId: intPtr(1), EventId: &meeting.EventId, Start: &moved})
+		assert.NoError(t, err)
+
+		events, err := repo.GetEventsForDay(2, start.AddDate(0, 0, 1))
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			attendee, _ := events[0].Attendee(2)
+			assert.Equal(t, model.StatusNeedsAction, attendee.Status)
+		}
+
+		events, err = repo.GetEventsForDay(2, start)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Remove attendee", func(t *testing.T) {
+		attendees := []model.Attendee{{UserId: 2}}
+		_, err := repo.UpdateEvent(model.UpdateEvent{UserId: intPtr(1), EventId: &meeting.EventId, Attendees: &attendees})
+		assert.NoError(t, err)
+
+		_, err = repo.GetEvent(3, meeting.EventId)
+		assert.Equal(t, ErrNoSuchEvent, err)
+
+		invitations, err := repo.GetInvitations(3)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+	})
+
+	t.Run("Deleted attendee", func(t *testing.T) {
+		// новый пользователь с id удаленного не получает его приглашения и ответы
+		_, err := repo.Respond(2, meeting.EventId, model.StatusAccepted)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteUser(2))
+		_, err = repo.CreateUser(model.User{UserId: 2, Name: "Dave", Email: "dave@example.com"})
+		require.NoError(t, err)
+
+		invitations, err := repo.GetInvitations(2)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+
+		_, err = repo.GetEvent(2, meeting.EventId)
+		assert.Equal(t, ErrNoSuchEvent, err)
+
+		_, err = repo.Respond(2, meeting.EventId, model.StatusAccepted)
+		assert.Equal(t, ErrNoSuchInvitation, err)
+
+		event, err := repo.GetEvent(1, meeting.EventId)
+		assert.NoError(t, err)
+		assert.Empty(t, event.Attendees)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteEvent(model.DeleteEvent{UserId: 1, EventId: meeting.EventId}))
+
+		invitations, err := repo.GetInvitations(2)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+	})
+}
+
+// registerUsers регистрирует пользователей: события создаются только для них
+func registerUsers(t testing.TB, repo interface {
+	CreateUser(model.User) (model.User, error)
+}, userIds ...int) {
+	t.Helper()
+	for _, userId := range userIds {
+		_, err := repo.CreateUser(model.User{UserId: userId, Name: fmt.Sprintf("User %d", userId), Email: fmt.Sprintf("user%d@example.com", userId)})
+		require.NoError(t, err)
+	}
+}
@@ -1,15 +1,190 @@
 package repository
 
-import "github.com/Komilov31/calendar-service/internal/model"
+import (
+	"slices"
+	"time"
 
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+// событие, организатор которого userId. Приглашения тоже лежат в индексе участника,
+// но менять и удалять их может только организатор.
 func (r *Repository) getEventByUserId(userId int, eventId int) (*model.Event, bool) {
-	events := r.events[userId]
+	index, ok := r.index[userId]
+	if !ok {
+		return nil, false
+	}
+
+	event, ok := index.get(eventId)
+	if !ok || event.UserId != userId {
+		return nil, false
+	}
+
+	return event, true
+}
+
+// проверка версии происходит под r.mu вместе с изменением, поэтому между
+// проверкой и записью событие не может измениться
+func versionMatches(event *model.Event, ifMatch []int) bool {
+	return len(ifMatch) == 0 || slices.Contains(ifMatch, event.Version)
+}
+
+// переносит изменения из updateEvent в event и проверяет, что конец не раньше начала.
+// При переносе встречи ответы участников сбрасываются.
+func applyUpdate(event *model.Event, updateEvent model.UpdateEvent) error {
+	before := *event
+
+	if updateEvent.CalendarId != nil {
+		event.CalendarId = *updateEvent.CalendarId
+	}
+
+	if updateEvent.Text != nil {
+		event.Text = *updateEvent.Text
+	}
+
+	if updateEvent.AllDay != nil {
+		event.AllDay = *updateEvent.AllDay
+	}
+
+	if updateEvent.Recurrence != nil {
+		event.Recurrence = *updateEvent.Recurrence
+	}
+
+	if updateEvent.TimeZone != nil {
+		event.TimeZone = *updateEvent.TimeZone
+	}
+
+	if updateEvent.Transparency != nil {
+		event.Transparency = *updateEvent.Transparency
+	}
+
+	if updateEvent.ExDates != nil {
+		event.ExDates = slices.Clone(*updateEvent.ExDates)
+	}
+
+	if updateEvent.Overrides != nil {
+		event.Overrides = slices.Clone(*updateEvent.Overrides)
+	}
+
+	// при переносе начала без явного конца длительность события сохраняется
+	if updateEvent.Start != nil {
+		duration := time.Time(event.End).Sub(time.Time(event.Start))
+		event.Start = *updateEvent.Start
+		event.End = model.Date(time.Time(event.Start).Add(duration))
+	}
+
+	if updateEvent.End != nil {
+		event.End = *updateEvent.End
+	}
+
+	if time.Time(event.End).Before(time.Time(event.Start)) {
+		return ErrEndBeforeStart
+	}
+	event.Normalize()
+
+	if updateEvent.Recurrence != nil || updateEvent.Start != nil {
+		if err := checkRecurrence(event); err != nil {
+			return err
+		}
+	}
+
+	attendees := event.Attendees
+	if updateEvent.Attendees != nil {
+		attendees = *updateEvent.Attendees
+	}
+	event.Attendees = mergeAttendees(before.Attendees, attendees, event.UserId, rescheduled(&before, event))
+
+	return nil
+}
+
+// вызывается под r.mu. У нового события (без CreatedAt) проставляется время создания,
+// версия увеличивается при каждом сохранении
+func (r *Repository) saveEvent(event model.Event) (model.Event, error) {
+	return r.saveEventWith(event, nil)
+}
+
+// saveEventWith - saveEvent вместе с новым календарем события в той же записи журнала
+func (r *Repository) saveEventWith(event model.Event, calendar *model.Calendar) (model.Event, error) {
+	now := model.Date(time.Now().UTC())
+	stamp(&event, now)
+	if calendar != nil {
+		calendar.CreatedAt, calendar.UpdatedAt = now, now
+	}
 
+	if err := r.commit(record{Op: opPutEvent, Event: &event, Calendar: calendar}); err != nil {
+		return model.Event{}, err
+	}
+
+	return event, nil
+}
+
+// saveEvents - saveEvent для нескольких событий одной записью журнала: после
+// ошибки записи не остается сохраненной только часть изменений
+func (r *Repository) saveEvents(events ...model.Event) ([]model.Event, error) {
+	now := model.Date(time.Now().UTC())
+	rec := record{Op: opPutEvents}
 	for _, event := range events {
-		if event.EventId == eventId {
-			return event, true
+		stamp(&event, now)
+		rec.Events = append(rec.Events, &event)
+	}
+
+	if err := r.commit(rec); err != nil {
+		return nil, err
+	}
+
+	saved := make([]model.Event, 0, len(rec.Events))
+	for _, event := range rec.Events {
+		saved = append(saved, *event)
+	}
+
+	return saved, nil
+}
+
+func stamp(event *model.Event, now model.Date) {
+	event.Version++
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = now
+	}
+	event.UpdatedAt = now
+}
+
+// вызывается под r.mu. События, пересекающиеся с периодом, упорядоченные по началу
+// в его зоне. Кандидаты берутся из индекса по периоду, расширенному на максимальное
+// смещение зоны, и затем проверяются так же, как в eventsBetween.
+func (r *Repository) eventsInWindow(userId int, window Window) []*model.Event {
+	index, ok := r.index[userId]
+	if !ok {
+		return nil
+	}
+
+	var candidates []*model.Event
+	index.between(window.From.Add(-maxZoneOffset), window.To.Add(maxZoneOffset), func(event *model.Event) {
+		candidates = append(candidates, event)
+	})
+
+	loc := window.From.Location()
+	result := eventsBetween(candidates, window.From, window.To)
+	slices.SortStableFunc(result, func(a, b *model.Event) int {
+		return time.Time(a.In(loc).Start).Compare(time.Time(b.In(loc).Start))
+	})
+
+	return result
+}
+
+// все события, пересекающиеся с полуинтервалом [from, to), полным перебором.
+// События на весь день сравниваются по календарным датам в зоне from
+func eventsBetween(events []*model.Event, from, to time.Time) []*model.Event {
+	var result []*model.Event
+	for _, event := range events {
+		if event.IsRecurring() {
+			result = append(result, occurrencesBetween(event, from, to)...)
+			continue
+		}
+
+		if event.In(from.Location()).Overlaps(from, to) {
+			result = append(result, event)
 		}
 	}
 
-	return nil, false
+	return result
 }
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+var (
+	ErrUserExists = apperror.Conflict("user_exists", "user with this id already exists")
+	ErrEmailTaken = apperror.Conflict("email_taken", "email is already used by another user")
+)
+
+// CreateUser регистрирует пользователя. Без UserId выдается следующий свободный id.
+func (r *Repository) CreateUser(user model.User) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.UserId == 0 {
+		user.UserId = r.lastUserId + 1
+	} else if _, ok := r.users[user.UserId]; ok {
+		return model.User{}, ErrUserExists
+	}
+
+	user.CreatedAt = model.Date{}
+	user.DisabledAt = nil
+	return r.saveUser(user)
+}
+
+// UpdateUser заменяет имя, почту, зону, язык и первый день недели пользователя
+func (r *Repository) UpdateUser(user model.User) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.UserId]
+	if !ok {
+		return model.User{}, ErrNoSuchUser
+	}
+
+	updated := *stored
+	updated.Name = user.Name
+	updated.Email = user.Email
+	updated.TimeZone = user.TimeZone
+	updated.Locale = user.Locale
+	updated.WeekStart = user.WeekStart
+
+	return r.saveUser(updated)
+}
+
+func (r *Repository) GetUser(userId int) (model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userId]
+	if !ok {
+		return model.User{}, ErrNoSuchUser
+	}
+
+	return *user, nil
+}
+
+// DisableUser отправляет пользователя в архив, его календари и события сохраняются
+func (r *Repository) DisableUser(userId int) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[userId]
+	if !ok {
+		return model.User{}, ErrNoSuchUser
+	}
+
+	if !stored.Active() {
+		return *stored, nil
+	}
+
+	disabled := *stored
+	now := model.Date(time.Now().UTC())
+	disabled.DisabledAt = &now
+
+	return r.saveUser(disabled)
+}
+
+// DeleteUser удаляет пользователя вместе с календарями, событиями и доступами
+func (r *Repository) DeleteUser(userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userId]; !ok {
+		return ErrNoSuchUser
+	}
+
+	return r.commit(record{Op: opDeleteUser, UserId: userId})
+}
+
+// вызывается под r.mu
+func (r *Repository) saveUser(user model.User) (model.User, error) {
+	if user.Email != "" {
+		for _, other := range r.users {
+			if other.UserId != user.UserId && strings.EqualFold(other.Email, user.Email) {
+				return model.User{}, ErrEmailTaken
+			}
+		}
+	}
+
+	now := model.Date(time.Now().UTC())
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+
+	if err := r.commit(record{Op: opPutUser, User: &user}); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// первый день недели пользователя для выборок за неделю
+func (r *Repository) firstWeekday(userId int) time.Weekday {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user, ok := r.users[userId]; ok {
+		return user.FirstWeekday()
+	}
+
+	return time.Monday
+}
+
+func (r *Repository) putUser(user *model.User) {
+	if user.UserId > r.lastUserId {
+		r.lastUserId = user.UserId
+	}
+
+	r.users[user.UserId] = user
+}
+
+// до появления регистрации пользователи появлялись вместе с первым событием. Для таких данных пользователь создается без имени и почты.
+func (r *Repository) ensureUser(userId int, createdAt model.Date) *model.User {
+	if user, ok := r.users[userId]; ok {
+		return user
+	}
+
+	user := &model.User{UserId: userId, CreatedAt: createdAt, UpdatedAt: createdAt}
+	r.putUser(user)
+	return user
+}
+
+// removeUser удаляет пользователя со всеми данными и убирает его из участников чужих
+// событий, чтобы пользователь, зарегистрированный позже с тем же id, не получил его
+// приглашения и ответы
+func (r *Repository) removeUser(userId int) {
+	for _, event := range r.invitations(userId) {
+		event = filterAttendees(event, func(attendee model.Attendee) bool {
+			return attendee.UserId != userId
+		})
+		event.Version++
+		r.putEvent(event)
+	}
+	for _, calendar := range r.userCalendars(userId) {
+		r.removeCalendar(userId, calendar.CalendarId)
+	}
+	for _, roles := range r.acl {
+		delete(roles, userId)
+	}
+	for _, event := range slices.Clone(r.events[userId]) {
+		r.removeEvent(userId, event.EventId)
+	}
+
+	delete(r.events, userId)
+	delete(r.index, userId)
+	delete(r.uids, userId)
+	delete(r.users, userId)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import "time"
+
+// Window - календарный период, полуинтервал [From, To). Границы вычисляются
+// по календарю зоны переданной даты, поэтому сутки на переходе на летнее
+// время длятся 23 или 25 часов.
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// RangeWindow - произвольный период, события на весь день сравниваются в зоне from
+func RangeWindow(from, to time.Time) Window {
+	return Window{From: from, To: to.In(from.Location())}
+}
+
+func DayWindow(date time.Time) Window {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return Window{From: from, To: from.AddDate(0, 0, 1)}
+}
+
+// ISOWeekWindow - неделя по ISO 8601, с понедельника по воскресенье
+func ISOWeekWindow(date time.Time) Window {
+	return LocaleWeekWindow(date, time.Monday)
+}
+
+// LocaleWeekWindow - неделя, начинающаяся с firstDay (например, с воскресенья в США)
+func LocaleWeekWindow(date time.Time, firstDay time.Weekday) Window {
+	day := DayWindow(date).From
+	offset := (int(day.Weekday()) - int(firstDay) + 7) % 7
+	from := day.AddDate(0, 0, -offset)
+	return Window{From: from, To: from.AddDate(0, 0, 7)}
+}
+
+func MonthWindow(date time.Time) Window {
+	from := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	return Window{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+func QuarterWindow(date time.Time) Window {
+	month := (date.Month()-1)/3*3 + 1
+	from := time.Date(date.Year(), month, 1, 0, 0, 0, 0, date.Location())
+	return Window{From: from, To: from.AddDate(0, 3, 0)}
+}
+
+func YearWindow(date time.Time) Window {
+	from := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+	return Window{From: from, To: from.AddDate(1, 0, 0)}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindows(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		window   Window
+		from, to time.Time
+	}{
+		{"Day at end of month", DayWindow(time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)), date(2024, 1, 31), date(2024, 2, 1)},
+		{"Day at end of year", DayWindow(date(2023, 12, 31)), date(2023, 12, 31), date(2024, 1, 1)},
+		{"Leap day", DayWindow(date(2024, 2, 29)), date(2024, 2, 29), date(2024, 3, 1)},
+		{"ISO week across months", ISOWeekWindow(date(2024, 5, 1)), date(2024, 4, 29), date(2024, 5, 6)},
+		{"ISO week across years", ISOWeekWindow(date(2025, 1, 1)), date(2024, 12, 30), date(2025, 1, 6)},
+		{"ISO week 53", ISOWeekWindow(date(2021, 1, 3)), date(2020, 12, 28), date(2021, 1, 4)},
+		{"ISO week on sunday", ISOWeekWindow(date(2024, 3, 17)), date(2024, 3, 11), date(2024, 3, 18)},
+		{"Sunday week", LocaleWeekWindow(date(2024, 3, 17), time.Sunday), date(2024, 3, 17), date(2024, 3, 24)},
+		{"Saturday week across years", LocaleWeekWindow(date(2024, 1, 1), time.Saturday), date(2023, 12, 30), date(2024, 1, 6)},
+		{"February in leap year", MonthWindow(date(2024, 2, 10)), date(2024, 2, 1), date(2024, 3, 1)},
+		{"February in common year", MonthWindow(date(2023, 2, 28)), date(2023, 2, 1), date(2023, 3, 1)},
+		{"December", MonthWindow(date(2023, 12, 31)), date(2023, 12, 1), date(2024, 1, 1)},
+		{"First quarter", QuarterWindow(date(2024, 3, 31)), date(2024, 1, 1), date(2024, 4, 1)},
+		{"Last quarter", QuarterWindow(date(2024, 10, 1)), date(2024, 10, 1), date(2025, 1, 1)},
+		{"Leap year", YearWindow(date(2024, 2, 29)), date(2024, 1, 1), date(2025, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.from, tt.window.From)
+			assert.Equal(t, tt.to, tt.window.To)
+		})
+	}
+
+	t.Run("ISO week 53 number", func(t *testing.T) {
+		year, week := ISOWeekWindow(date(2020, 12, 31)).From.ISOWeek()
+		assert.Equal(t, 2020, year)
+		assert.Equal(t, 53, week)
+	})
+}
+
+func TestWindowsInTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// переход на летнее время 31 марта 2024
+	day := DayWindow(time.Date(2024, 3, 31, 12, 0, 0, 0, loc))
+	assert.Equal(t, 23*time.Hour, day.To.Sub(day.From))
+
+	week := ISOWeekWindow(time.Date(2024, 3, 31, 12, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, 3, 25, 0, 0, 0, 0, loc), week.From)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, loc), week.To)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// в UTC это уже 1 января, а в Нью-Йорке еще 31 декабря
+	year := YearWindow(time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC).In(newYork))
+	assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, newYork), year.From)
+}
+
+func TestGetEventsInWindow(t *testing.T) {
+	repo := New()
+	registerUsers(t, repo, 1)
+
+	events := []model.Event{
+		{UserId: 1, Text: "January 15", Start: model.Date(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "March 15", Start: model.Date(time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC))},
+		{UserId: 1, Text: "Leap day", Start: model.Date(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)), AllDay: true},
+		{UserId: 1, Text: "New year eve", Start: model.Date(time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC)), End: model.Date(time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC))},
+	}
+	for _, event := range events {
+		_, err := repo.CreateEvent(event)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		window Window
+		want   []string
+	}{
+		{"Same day number in another month", DayWindow(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)), []string{"January 15"}},
+		{"Leap day", DayWindow(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)), []string{"Leap day"}},
+		{"First quarter", QuarterWindow(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)), []string{"January 15", "Leap day", "March 15"}},
+		{"Event across years", YearWindow(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)), []string{"New year eve"}},
+		{"Week 1 of 2025", ISOWeekWindow(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), []string{"New year eve"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := repo.GetEventsInWindow(1, tt.window)
+			require.NoError(t, err)
+
+			var got []string
+			for _, event := range events {
+				got = append(got, event.Text)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Quarter, year and ISO week", func(t *testing.T) {
+		date := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+		quarter, err := repo.GetEventsForQuarter(1, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Len(t, quarter, 3)
+
+		year, err := repo.GetEventsForYear(1, date)
+		require.NoError(t, err)
+		assert.Len(t, year, 4)
+
+		week, err := repo.GetEventsForISOWeek(1, date)
+		require.NoError(t, err)
+		if assert.Len(t, week, 1) {
+			assert.Equal(t, "New year eve", week[0].Text)
+		}
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		_, err := repo.GetEventsInWindow(2, DayWindow(time.Now()))
+		assert.ErrorIs(t, err, ErrNoSuchUser)
+	})
+}
@@ -0,0 +1,683 @@
+// Package rrule разбирает и разворачивает правила повторения RRULE из RFC 5545.
+// Поддерживаются FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH и WKST.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// если за столько лет (с учетом INTERVAL) после последнего повторения не нашлось
+// нового, правило считается исчерпанным. 29 февраля повторяется раз в 4 года, а
+// через невисокосный 2100 год - через 8.
+const maxEmptyYears = 8
+
+// верхние границы COUNT и INTERVAL: правила с большими значениями слишком дорого
+// разворачивать и индексировать
+const (
+	MaxCount    = 10000
+	MaxInterval = 1000
+)
+
+// MaxCountYears ограничивает, сколько лет от DTSTART перебираются периоды правила с
+// COUNT, у которого число повторений в периоде меняется. Такое правило не должно
+// продолжаться дольше: иначе его конец нельзя найти без долгого перебора
+// (FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29 дает одно повторение на ~1461 период).
+const MaxCountYears = 100
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// WeekdayNum - значение BYDAY: день недели и необязательный порядковый номер (1MO, -1FR)
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+
+	// Floating - UNTIL задан без зоны и считается по часам DTSTART, см. UntilIn
+	Floating bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, rule.Freq) {
+				err = fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseBounded(value, MaxInterval)
+		case "COUNT":
+			rule.Count, err = parseBounded(value, MaxCount)
+		case "UNTIL":
+			rule.Until, rule.Floating, err = parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(value, 1, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseIntList(value, 1, 12)
+			for _, m := range months {
+				if m < 0 {
+					err = fmt.Errorf("invalid BYMONTH %d", m)
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			day, ok := weekdays[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown WKST %q", value)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("unsupported part %q", key)
+		}
+
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %s", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+
+	return rule, nil
+}
+
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() && r.Floating {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+	} else if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, int(m))
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// UntilIn возвращает UNTIL для DTSTART в зоне loc: UNTIL без зоны - плавающее
+// время по часам DTSTART (RFC 5545, 3.3.10)
+func (r Rule) UntilIn(loc *time.Location) time.Time {
+	if r.Until.IsZero() || !r.Floating {
+		return r.Until
+	}
+
+	y, m, d := r.Until.Date()
+	return time.Date(y, m, d, r.Until.Hour(), r.Until.Minute(), r.Until.Second(), r.Until.Nanosecond(), loc)
+}
+
+func (wd WeekdayNum) String() string {
+	if wd.N == 0 {
+		return weekdayCode(wd.Day)
+	}
+
+	return strconv.Itoa(wd.N) + weekdayCode(wd.Day)
+}
+
+// Iterate по порядку вызывает fn для каждого повторения, начиная с dtstart, пока fn
+// возвращает true и правило не исчерпано. Повторения строятся по настенному времени
+// dtstart в его зоне, поэтому переходы на летнее время не сдвигают время события.
+func (r Rule) Iterate(dtstart time.Time, fn func(time.Time) bool) {
+	r.iterate(dtstart, 0, fn)
+}
+
+// IterateFrom - Iterate для выборки повторений с from: периоды правила, целиком
+// лежащие раньше from, пропускаются без разворачивания. Повторения раньше from
+// (из предыдущего периода) тоже могут прийти.
+func (r Rule) IterateFrom(dtstart, from time.Time, fn func(time.Time) bool) {
+	if !from.After(dtstart) {
+		r.iterate(dtstart, 0, fn)
+		return
+	}
+
+	// начинаем на период раньше, чтобы не потерять его повторения после from
+	first := r.periodsBefore(dtstart, from)/r.Interval - 1
+	r.iterate(dtstart, max(first, 0), fn)
+}
+
+// Last возвращает начало последнего повторения правила с COUNT. Если число
+// повторений в периоде постоянно, оно вычисляется без разворачивания, иначе
+// периоды перебираются не дальше MaxCountYears лет. Правило без COUNT или не
+// закончившееся за MaxCountYears лет дает false.
+func (r Rule) Last(dtstart time.Time) (time.Time, bool) {
+	if r.Count == 0 {
+		return time.Time{}, false
+	}
+
+	if perPeriod := r.perPeriod(dtstart); perPeriod > 0 {
+		// DTSTART и повторения первого периода после него, дальше по perPeriod в периоде
+		head := append([]time.Time{dtstart}, r.occurrencesIn(dtstart, 0)...)
+		if r.Count <= len(head) {
+			return head[r.Count-1], true
+		}
+
+		rest := r.Count - len(head) - 1
+		period := 1 + rest/perPeriod
+		return r.occurrencesIn(dtstart, period)[rest%perPeriod], true
+	}
+
+	var last time.Time
+	complete := r.iterate(dtstart, 0, func(t time.Time) bool {
+		last = t
+		return true
+	})
+
+	return last, complete
+}
+
+// iterate разворачивает правило с периода first (в шагах INTERVAL). DTSTART
+// выдается, только если разворачивание начинается с первого периода. С COUNT
+// номер повторения зависит от всех предыдущих, поэтому повторения пропущенных
+// периодов подсчитываются: умножением, если их число в периоде постоянно, иначе
+// перебором периодов без выдачи повторений. Такие правила перебираются не дальше
+// MaxCountYears лет от dtstart, и тогда iterate возвращает false.
+func (r Rule) iterate(dtstart time.Time, first int, fn func(time.Time) bool) bool {
+	count := 0
+	until := r.UntilIn(dtstart.Location())
+	emit := func(t time.Time) bool {
+		if !until.IsZero() && t.After(until) {
+			return false
+		}
+		count++
+		if !fn(t) {
+			return false
+		}
+		return r.Count == 0 || count < r.Count
+	}
+
+	var limit time.Time
+	start := first
+	switch perPeriod := r.perPeriod(dtstart); {
+	case first == 0:
+		if r.Count > 0 && perPeriod == 0 {
+			limit = dtstart.AddDate(MaxCountYears, 0, 0)
+		}
+		// DTSTART всегда первое повторение (RFC 5545, 3.8.5.3)
+		if !emit(dtstart) {
+			return true
+		}
+	case r.Count > 0 && perPeriod > 0:
+		count = 1 + len(r.occurrencesIn(dtstart, 0)) + (first-1)*perPeriod
+		if count >= r.Count {
+			return true
+		}
+	case r.Count > 0:
+		// повторения считаются не дальше limit, поэтому окно позже него пусто, а
+		// подсчет перед окном перебирает не больше MaxCountYears лет периодов
+		limit = dtstart.AddDate(MaxCountYears, 0, 0)
+		if r.periodStart(dtstart, first*r.Interval).After(limit) {
+			return false
+		}
+		count, start = 1, 0
+	}
+
+	// последний день с повторением, от него отсчитывается maxEmptyYears
+	last := r.periodStart(dtstart, start*r.Interval)
+	for period := start; ; period++ {
+		periodStart := r.periodStart(dtstart, period*r.Interval)
+		if periodStart.After(last.AddDate(maxEmptyYears*r.Interval, 0, 0)) {
+			return true
+		}
+		if !limit.IsZero() && periodStart.After(limit) {
+			return false
+		}
+
+		candidates := r.expand(dtstart, period*r.Interval)
+		if len(candidates) == 0 {
+			continue
+		}
+		last = candidates[len(candidates)-1]
+
+		for _, day := range candidates {
+			t := occurrenceAt(dtstart, day)
+			if !t.After(dtstart) {
+				continue
+			}
+			if period < first {
+				if count++; count >= r.Count {
+					return true
+				}
+				continue
+			}
+			if !emit(t) {
+				return true
+			}
+		}
+	}
+}
+
+// occurrencesIn возвращает повторения периода с номером period (в шагах INTERVAL),
+// начинающиеся позже dtstart
+func (r Rule) occurrencesIn(dtstart time.Time, period int) []time.Time {
+	var result []time.Time
+	for _, day := range r.expand(dtstart, period*r.Interval) {
+		if t := occurrenceAt(dtstart, day); t.After(dtstart) {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// повторение в день day по настенному времени dtstart
+func occurrenceAt(dtstart, day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+}
+
+// perPeriod возвращает число повторений в каждом периоде правила, если оно не
+// зависит от периода, иначе 0
+func (r Rule) perPeriod(dtstart time.Time) int {
+	_, m, d := dtstart.Date()
+	switch r.Freq {
+	case Daily:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+			return 1
+		}
+	case Weekly:
+		if len(r.ByMonth) > 0 {
+			return 0
+		}
+		if len(r.ByDay) == 0 {
+			return 1
+		}
+		days := make(map[time.Weekday]bool)
+		for _, wd := range r.ByDay {
+			days[wd.Day] = true
+		}
+		return len(days)
+	case Monthly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 && d <= 28 {
+			return 1
+		}
+	case Yearly:
+		if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+			return 0
+		}
+		if len(r.ByMonth) == 0 && !(m == time.February && d == 29) {
+			return 1
+		}
+		if d <= 28 {
+			months := make(map[time.Month]bool)
+			for _, month := range r.ByMonth {
+				months[month] = true
+			}
+			return len(months)
+		}
+	}
+
+	return 0
+}
+
+// Between возвращает повторения, начинающиеся в полуинтервале [from, to)
+func (r Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.IterateFrom(dtstart, from, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+
+	return result
+}
+
+// полночь начала периода с номером offset, достаточно точная для ограничения поиска
+func (r Rule) periodStart(dtstart time.Time, offset int) time.Time {
+	y, m, d := dtstart.Date()
+	switch r.Freq {
+	case Daily:
+		d += offset
+	case Weekly:
+		d += 7 * offset
+	case Monthly:
+		m += time.Month(offset)
+	default:
+		y += offset
+	}
+
+	return time.Date(y, m, d, 0, 0, 0, 0, dtstart.Location())
+}
+
+// число целых периодов FREQ (дней, недель, месяцев или лет) от dtstart до t по
+// календарю зоны dtstart
+func (r Rule) periodsBefore(dtstart, t time.Time) int {
+	y, m, d := dtstart.Date()
+	ty, tm, td := t.In(dtstart.Location()).Date()
+
+	days := int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	switch r.Freq {
+	case Daily:
+		return days
+	case Weekly:
+		// недели считаются от начала недели dtstart по WKST
+		shift := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		return (days + shift) / 7
+	case Monthly:
+		return (ty-y)*12 + int(tm-m)
+	default:
+		return ty - y
+	}
+}
+
+// expand возвращает отсортированные даты повторений в периоде с номером offset
+// (в днях, неделях, месяцах или годах от dtstart в зависимости от FREQ)
+func (r Rule) expand(dtstart time.Time, offset int) []time.Time {
+	y, m, d := dtstart.Date()
+	loc := dtstart.Location()
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, loc)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		shift := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := time.Date(y, m, d-shift+7*offset, 0, 0, 0, 0, loc)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchesMonth(day) && r.matchesWeekday(day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		month := time.Date(y, m+time.Month(offset), 1, 0, 0, 0, 0, loc)
+		if r.matchesMonth(month) {
+			days = r.expandMonth(month, d)
+		}
+	case Yearly:
+		year := y + offset
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, month := range r.ByMonth {
+				days = append(days, r.expandMonth(time.Date(year, month, 1, 0, 0, 0, 0, loc), d)...)
+			}
+		case len(r.ByMonthDay) > 0:
+			for month := time.January; month <= time.December; month++ {
+				days = append(days, r.expandMonth(time.Date(year, month, 1, 0, 0, 0, 0, loc), d)...)
+			}
+		case len(r.ByDay) > 0:
+			days = r.expandYearWeekdays(year, loc)
+		default:
+			day := time.Date(year, m, d, 0, 0, 0, 0, loc)
+			if day.Day() == d {
+				days = append(days, day)
+			}
+		}
+	}
+
+	slices.SortFunc(days, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(days, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// дни месяца по BYMONTHDAY и BYDAY; без них - тот же день месяца, что и у dtstart
+func (r Rule) expandMonth(month time.Time, dtstartDay int) []time.Time {
+	last := daysIn(month)
+
+	var days []time.Time
+	for day := 1; day <= last; day++ {
+		date := month.AddDate(0, 0, day-1)
+
+		switch {
+		case len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+			if day != dtstartDay {
+				continue
+			}
+		case len(r.ByMonthDay) > 0 && !r.matchesMonthDay(date):
+			continue
+		case len(r.ByDay) > 0 && !matchesNthWeekday(r.ByDay, date, day, last):
+			continue
+		}
+
+		days = append(days, date)
+	}
+
+	return days
+}
+
+// BYDAY без BYMONTH в годовом правиле: номер считается от начала или конца года
+func (r Rule) expandYearWeekdays(year int, loc *time.Location) []time.Time {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	total := daysInYear(year)
+
+	var days []time.Time
+	for i := 0; i < total; i++ {
+		date := first.AddDate(0, 0, i)
+		if matchesNthWeekday(r.ByDay, date, i+1, total) {
+			days = append(days, date)
+		}
+	}
+
+	return days
+}
+
+func (r Rule) matchesMonth(day time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, day.Month())
+}
+
+func (r Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+
+	last := daysIn(day)
+	for _, md := range r.ByMonthDay {
+		if md == day.Day() || (md < 0 && last+md+1 == day.Day()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// для DAILY и WEEKLY порядковые номера в BYDAY не используются
+func (r Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// index - номер дня в периоде (с 1), total - число дней в периоде
+func matchesNthWeekday(byDay []WeekdayNum, date time.Time, index, total int) bool {
+	for _, wd := range byDay {
+		if wd.Day != date.Weekday() {
+			continue
+		}
+
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (index-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (total-index)/7+1 == -wd.N:
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+func parseBounded(value string, max int) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil || i <= 0 || i > max {
+		return 0, fmt.Errorf("%q is not an integer from 1 to %d", value, max)
+	}
+
+	return i, nil
+}
+
+// UNTIL в UTC или плавающее время (без зоны), которое считается в зоне DTSTART
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		// UNTIL в виде даты включает весь последний день
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var result []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+
+		wd := WeekdayNum{Day: day}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+			wd.N = n
+		}
+
+		result = append(result, wd)
+	}
+
+	return result, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || i == 0 || i < -max || i > max || (i > 0 && i < min) {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		result = append(result, i)
+	}
+
+	return result, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func weekdayCode(day time.Weekday) string {
+	for code, wd := range weekdays {
+		if wd == day {
+			return code
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,332 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Round trip", func(t *testing.T) {
+		rule, err := Parse("FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1MO,-1FR")
+		require.NoError(t, err)
+		assert.Equal(t, Monthly, rule.Freq)
+		assert.Equal(t, 2, rule.Interval)
+		assert.Equal(t, []WeekdayNum{{N: 1, Day: time.Monday}, {N: -1, Day: time.Friday}}, rule.ByDay)
+		assert.Equal(t, "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1MO,-1FR", rule.String())
+	})
+
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=SECONDLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;COUNT=10001",
+		"FREQ=WEEKLY;INTERVAL=1001",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;FOO=BAR",
+	}
+	for _, s := range invalid {
+		_, err := Parse(s)
+		assert.ErrorIs(t, err, ErrInvalidRule, s)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	dtstart := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC) // понедельник
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule string
+		to   time.Time
+		want []string
+	}{
+		{
+			name: "Daily with count",
+			rule: "FREQ=DAILY;COUNT=3",
+			to:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name: "Weekly on weekdays with until",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20240108T090000Z",
+			to:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-03", "2024-01-05", "2024-01-08"},
+		},
+		{
+			name: "Every other week",
+			rule: "FREQ=WEEKLY;INTERVAL=2",
+			to:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-15", "2024-01-29"},
+		},
+		{
+			name: "Last friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4",
+			to:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name: "Month day 31 skips short months",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31",
+			to:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			name: "Last day of the month",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			to:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-01-31", "2024-02-29"},
+		},
+		{
+			name: "Yearly by month and weekday",
+			rule: "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3",
+			to:   time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-01-01", "2024-11-28", "2025-11-27"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			require.NoError(t, err)
+
+			var got []string
+			for _, occurrence := range rule.Between(dtstart, from, tt.to) {
+				assert.Equal(t, 9, occurrence.Hour())
+				got = append(got, occurrence.Format(time.DateOnly))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBetweenKeepsWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	rule, err := Parse("FREQ=WEEKLY")
+	require.NoError(t, err)
+
+	dtstart := time.Date(2024, 3, 25, 9, 0, 0, 0, loc) // переход на летнее время 31 марта
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(0, 0, 14))
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, 9, occurrences[1].Hour())
+	assert.Equal(t, 7*24*time.Hour-time.Hour, occurrences[1].Sub(occurrences[0]))
+}
+
+func TestBetweenLeapDayYearly(t *testing.T) {
+	rule, err := Parse("FREQ=YEARLY;COUNT=2")
+	require.NoError(t, err)
+
+	dtstart := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), occurrences[1])
+}
+
+func TestBetweenLeapDayDaily(t *testing.T) {
+	// до следующего 29 февраля больше тысячи дневных периодов, а после 2096 года - восемь лет
+	rule, err := Parse("FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29")
+	require.NoError(t, err)
+
+	dtstart := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)
+	occurrences := rule.Between(dtstart, dtstart, time.Date(2105, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.Len(t, occurrences, 20)
+	assert.Equal(t, time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC), occurrences[1])
+	assert.Equal(t, time.Date(2096, 2, 29, 9, 0, 0, 0, time.UTC), occurrences[18])
+	assert.Equal(t, time.Date(2104, 2, 29, 9, 0, 0, 0, time.UTC), occurrences[19])
+
+	// правило без повторений после DTSTART исчерпывается
+	never, err := Parse("FREQ=DAILY;BYMONTH=2;BYMONTHDAY=30")
+	require.NoError(t, err)
+	assert.Len(t, never.Between(dtstart, dtstart, time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)), 1)
+}
+
+func TestFloatingUntil(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// UNTIL без зоны - 9:00 по часам DTSTART, а не по UTC (18:00 в Токио)
+	rule, err := Parse("FREQ=DAILY;UNTIL=20240103T090000")
+	require.NoError(t, err)
+	assert.True(t, rule.Floating)
+	assert.Equal(t, "FREQ=DAILY;UNTIL=20240103T090000", rule.String())
+
+	dtstart := time.Date(2024, 1, 1, 9, 0, 0, 0, loc)
+	occurrences := rule.Between(dtstart, dtstart, dtstart.AddDate(0, 0, 10))
+	assert.Len(t, occurrences, 3)
+
+	dtstart = time.Date(2024, 1, 1, 12, 0, 0, 0, loc)
+	occurrences = rule.Between(dtstart, dtstart, dtstart.AddDate(0, 0, 10))
+	assert.Len(t, occurrences, 2)
+
+	utc, err := Parse("FREQ=DAILY;UNTIL=20240103T090000Z")
+	require.NoError(t, err)
+	assert.False(t, utc.Floating)
+	assert.Len(t, utc.Between(dtstart, dtstart, dtstart.AddDate(0, 0, 10)), 3)
+}
+
+func TestIterateFrom(t *testing.T) {
+	dtstart := time.Date(2019, 3, 13, 9, 0, 0, 0, time.UTC) // среда
+
+	rules := []string{
+		"FREQ=DAILY",
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;WKST=SU",
+		"FREQ=WEEKLY;INTERVAL=3;BYDAY=SU,TU;WKST=MO",
+		"FREQ=MONTHLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;INTERVAL=5;BYMONTHDAY=31",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+		"FREQ=DAILY;COUNT=2500",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR,MO;COUNT=150",
+		"FREQ=MONTHLY;BYDAY=-1FR;COUNT=40",
+		"FREQ=MONTHLY;INTERVAL=2;COUNT=25",
+		"FREQ=YEARLY;BYMONTH=3,9;COUNT=9",
+		"FREQ=WEEKLY;UNTIL=20240601T090000Z",
+	}
+
+	for _, text := range rules {
+		t.Run(text, func(t *testing.T) {
+			rule, err := Parse(text)
+			require.NoError(t, err)
+
+			// from сдвигается на 11 дней, чтобы попадать на разные дни недели и месяца
+			for from := dtstart.AddDate(0, 0, -3); from.Year() < 2025; from = from.AddDate(0, 0, 11) {
+				to := from.AddDate(0, 2, 0)
+
+				var want []time.Time
+				rule.Iterate(dtstart, func(t time.Time) bool {
+					if !t.Before(to) {
+						return false
+					}
+					if !t.Before(from) {
+						want = append(want, t)
+					}
+					return true
+				})
+				assert.Equal(t, want, rule.Between(dtstart, from, to), from)
+			}
+		})
+	}
+
+	t.Run("Skips periods before from", func(t *testing.T) {
+		rule, err := Parse("FREQ=DAILY")
+		require.NoError(t, err)
+
+		from := dtstart.AddDate(5, 0, 0)
+		calls := 0
+		rule.IterateFrom(dtstart, from, func(t time.Time) bool {
+			calls++
+			return t.Before(from)
+		})
+		assert.LessOrEqual(t, calls, 3)
+	})
+
+	t.Run("Skips periods before from with count", func(t *testing.T) {
+		rule, err := Parse("FREQ=DAILY;COUNT=10000")
+		require.NoError(t, err)
+
+		from := dtstart.AddDate(20, 0, 0)
+		var got []time.Time
+		rule.IterateFrom(dtstart, from, func(t time.Time) bool {
+			got = append(got, t)
+			return t.Before(from)
+		})
+		assert.LessOrEqual(t, len(got), 3)
+		assert.Equal(t, dtstart.AddDate(0, 0, 7304), got[0])
+
+		// повторения кончаются до from
+		rule.Count = 100
+		calls := 0
+		rule.IterateFrom(dtstart, from, func(time.Time) bool {
+			calls++
+			return true
+		})
+		assert.Zero(t, calls)
+	})
+}
+
+func TestIterateFromDistantWindowWithCount(t *testing.T) {
+	dtstart := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)
+
+	for _, text := range []string{
+		// число повторений в периоде меняется: подсчет ограничен MaxCountYears
+		"FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29;COUNT=10000",
+		"FREQ=MONTHLY;BYDAY=-1FR;COUNT=1000",
+		"FREQ=DAILY;COUNT=10000",
+	} {
+		rule, err := Parse(text)
+		require.NoError(t, err)
+
+		for _, from := range []time.Time{
+			time.Date(2100, 3, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(9000, 3, 1, 0, 0, 0, 0, time.UTC),
+		} {
+			began := time.Now()
+			occurrences := rule.Between(dtstart, from, from.AddDate(0, 1, 0))
+			assert.Less(t, time.Since(began), 50*time.Millisecond, "%s from %s", text, from)
+
+			if from.Year() == 9000 {
+				assert.Empty(t, occurrences, text)
+			}
+		}
+	}
+}
+
+func TestLast(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY;COUNT=1",
+		"FREQ=DAILY;INTERVAL=3;COUNT=10000",
+		"FREQ=WEEKLY;COUNT=52",
+		"FREQ=WEEKLY;BYDAY=MO,TH;COUNT=7",
+		"FREQ=WEEKLY;BYDAY=SU,SA;WKST=SU;COUNT=2",
+		"FREQ=MONTHLY;COUNT=30",
+		"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=5",
+		"FREQ=YEARLY;COUNT=5",
+		"FREQ=YEARLY;BYMONTH=1,4,7,10;COUNT=11",
+	}
+	starts := []time.Time{
+		time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 7, 4, 9, 0, 0, 0, time.UTC), // четверг
+	}
+
+	for _, text := range rules {
+		rule, err := Parse(text)
+		require.NoError(t, err)
+
+		for _, dtstart := range starts {
+			var want time.Time
+			rule.Iterate(dtstart, func(t time.Time) bool {
+				want = t
+				return true
+			})
+
+			got, ok := rule.Last(dtstart)
+			assert.True(t, ok)
+			assert.Equal(t, want, got, "%s from %s", text, dtstart)
+		}
+	}
+
+	unbounded, err := Parse("FREQ=DAILY")
+	require.NoError(t, err)
+	_, ok := unbounded.Last(starts[0])
+	assert.False(t, ok)
+
+	// одно повторение на ~1461 дневной период: за MaxCountYears лет серия не кончается
+	sparse, err := Parse("FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29;COUNT=10000")
+	require.NoError(t, err)
+	_, ok = sparse.Last(starts[1])
+	assert.False(t, ok)
+
+	sparse.Count = 5
+	last, ok := sparse.Last(starts[1])
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2040, 2, 29, 9, 0, 0, 0, time.UTC), last)
+}
@@ -0,0 +1,224 @@
+// Package scheduling подбирает время встречи по занятости участников: свободные
+// для всех промежутки в рабочие часы с запасом между встречами, упорядоченные
+// по предпочтениям.
+package scheduling
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultStep - шаг начала слотов, если в Options он не задан
+const DefaultStep = 15 * time.Minute
+
+// Interval - полуинтервал времени [Start, End)
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Options - параметры поиска. Рабочие часы и дни считаются в зоне From.
+type Options struct {
+	From     time.Time
+	To       time.Time
+	Duration time.Duration
+
+	// Buffer - минимальный промежуток между слотом и занятым временем участников
+	Buffer time.Duration
+
+	// WorkStart и WorkEnd - рабочие часы как смещение от полуночи
+	WorkStart time.Duration
+	WorkEnd   time.Duration
+
+	// WorkDays - рабочие дни, по умолчанию с понедельника по пятницу
+	WorkDays []time.Weekday
+
+	// Step - шаг начала слотов от полуночи, по умолчанию DefaultStep
+	Step  time.Duration
+	Limit int
+}
+
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// слот-кандидат и его оценка: номер рабочего дня, число потерянных встреч такой же
+// длины и начало после полудня
+type candidate struct {
+	Interval
+	day    int
+	lost   int
+	midday bool
+}
+
+// Find возвращает до opts.Limit непересекающихся слотов, свободных у всех участников.
+// busy - занятость каждого участника. Слоты берутся с самых ранних дней, внутри дня
+// сначала идут слоты, которые меньше дробят свободное время (не уменьшают число встреч
+// такой же длины, которые еще поместятся), затем утренние, затем более ранние.
+func Find(opts Options, busy ...[]Interval) []Interval {
+	if opts.Duration <= 0 || opts.Limit <= 0 || !opts.To.After(opts.From) {
+		return []Interval{}
+	}
+
+	step := opts.Step
+	if step <= 0 {
+		step = DefaultStep
+	}
+
+	workDays := opts.WorkDays
+	if len(workDays) == 0 {
+		workDays = weekdays
+	}
+
+	occupied := merge(busy, opts.Buffer)
+
+	var candidates []candidate
+	for day, working := range workingHours(opts, workDays) {
+		for _, gap := range subtract(working, occupied) {
+			candidates = append(candidates, slotsIn(gap, day, opts.Duration, step)...)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.day != b.day:
+			return a.day - b.day
+		case a.lost != b.lost:
+			return a.lost - b.lost
+		case a.midday != b.midday:
+			if a.midday {
+				return 1
+			}
+			return -1
+		default:
+			return a.Start.Compare(b.Start)
+		}
+	})
+
+	result := []Interval{}
+	for _, c := range candidates {
+		if len(result) == opts.Limit {
+			break
+		}
+
+		if !slices.ContainsFunc(result, c.overlaps) {
+			result = append(result, c.Interval)
+		}
+	}
+
+	return result
+}
+
+func (i Interval) overlaps(other Interval) bool {
+	return i.Start.Before(other.End) && other.Start.Before(i.End)
+}
+
+// workingHours возвращает рабочие часы по рабочим дням периода, обрезанные по его границам
+func workingHours(opts Options, workDays []time.Weekday) []Interval {
+	var result []Interval
+	for date := midnight(opts.From); date.Before(opts.To); date = midnight(date.AddDate(0, 0, 1)) {
+		if !slices.Contains(workDays, date.Weekday()) {
+			continue
+		}
+
+		start, end := wallClock(date, opts.WorkStart), wallClock(date, opts.WorkEnd)
+		if start.Before(opts.From) {
+			start = opts.From
+		}
+		if end.After(opts.To) {
+			end = opts.To
+		}
+
+		if end.After(start) {
+			result = append(result, Interval{Start: start, End: end})
+		}
+	}
+
+	return result
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// время на часах дня date: переход на летнее время рабочие часы не сдвигает
+func wallClock(date time.Time, offset time.Duration) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, int(offset/time.Minute), 0, 0, date.Location())
+}
+
+// merge объединяет занятость всех участников, расширяя каждый интервал на buffer
+func merge(busy [][]Interval, buffer time.Duration) []Interval {
+	var all []Interval
+	for _, intervals := range busy {
+		for _, interval := range intervals {
+			all = append(all, Interval{Start: interval.Start.Add(-buffer), End: interval.End.Add(buffer)})
+		}
+	}
+
+	slices.SortFunc(all, func(a, b Interval) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	var merged []Interval
+	for _, interval := range all {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// subtract возвращает части window, не занятые occupied (отсортированы и не пересекаются)
+func subtract(window Interval, occupied []Interval) []Interval {
+	var gaps []Interval
+	start := window.Start
+	for _, interval := range occupied {
+		if !interval.End.After(start) {
+			continue
+		}
+		if !interval.Start.Before(window.End) {
+			break
+		}
+
+		if interval.Start.After(start) {
+			gaps = append(gaps, Interval{Start: start, End: interval.Start})
+		}
+		start = interval.End
+	}
+
+	if window.End.After(start) {
+		gaps = append(gaps, Interval{Start: start, End: window.End})
+	}
+
+	return gaps
+}
+
+// slotsIn перебирает слоты в свободном промежутке с шагом step от полуночи
+func slotsIn(gap Interval, day int, duration, step time.Duration) []candidate {
+	dayStart := midnight(gap.Start)
+	noon := wallClock(dayStart, 12*time.Hour)
+	capacity := int(gap.End.Sub(gap.Start) / duration)
+
+	var result []candidate
+	offset := gap.Start.Sub(dayStart)
+	first := dayStart.Add((offset + step - 1) / step * step)
+	for start := first; !start.Add(duration).After(gap.End); start = start.Add(step) {
+		end := start.Add(duration)
+		before := int(start.Sub(gap.Start) / duration)
+		after := int(gap.End.Sub(end) / duration)
+
+		result = append(result, candidate{
+			Interval: Interval{Start: start, End: end},
+			day:      day,
+			lost:     capacity - 1 - before - after,
+			midday:   !start.Before(noon),
+		})
+	}
+
+	return result
+}
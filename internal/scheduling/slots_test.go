@@ -0,0 +1,111 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	// понедельник
+	monday := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, 15+day, hour, minute, 0, 0, time.UTC)
+	}
+	slot := func(day, hour, minute int, duration time.Duration) Interval {
+		return Interval{Start: at(day, hour, minute), End: at(day, hour, minute).Add(duration)}
+	}
+	opts := Options{
+		From:      monday,
+		To:        monday.AddDate(0, 0, 7),
+		Duration:  time.Hour,
+		WorkStart: 9 * time.Hour,
+		WorkEnd:   18 * time.Hour,
+		Limit:     3,
+	}
+
+	t.Run("Free day", func(t *testing.T) {
+		assert.Equal(t, []Interval{
+			slot(0, 9, 0, time.Hour),
+			slot(0, 10, 0, time.Hour),
+			slot(0, 11, 0, time.Hour),
+		}, Find(opts))
+	})
+
+	t.Run("Everyone free", func(t *testing.T) {
+		alice := []Interval{{Start: at(0, 9, 0), End: at(0, 10, 30)}}
+		bob := []Interval{{Start: at(0, 11, 0), End: at(0, 12, 0)}, {Start: at(0, 13, 0), End: at(0, 17, 0)}}
+
+		// промежуток 10:30-11:00 короче встречи
+		assert.Equal(t, []Interval{
+			slot(0, 12, 0, time.Hour),
+			slot(0, 17, 0, time.Hour),
+			slot(1, 9, 0, time.Hour),
+		}, Find(opts, alice, bob))
+	})
+
+	t.Run("Overlapping busy intervals", func(t *testing.T) {
+		// занятость участников пересекается и вместе закрывает утро целиком
+		alice := []Interval{{Start: at(0, 9, 0), End: at(0, 10, 30)}}
+		bob := []Interval{{Start: at(0, 10, 0), End: at(0, 11, 15)}, {Start: at(0, 9, 30), End: at(0, 9, 45)}}
+		carol := []Interval{{Start: at(0, 11, 0), End: at(0, 12, 0)}, {Start: at(0, 12, 30), End: at(0, 18, 0)}}
+
+		withLimit := opts
+		withLimit.Duration = 30 * time.Minute
+		withLimit.Limit = 2
+		assert.Equal(t, []Interval{
+			slot(0, 12, 0, 30*time.Minute),
+			slot(1, 9, 0, 30*time.Minute),
+		}, Find(withLimit, alice, bob, carol))
+	})
+
+	t.Run("Minimal fragmentation", func(t *testing.T) {
+		// в 9:10-11:10 помещаются две часовые встречи, но слоты начинаются с шагом 15 минут,
+		// и любой из них оставит место только для одной. Поэтому лучше слот после полудня.
+		busy := []Interval{{Start: at(0, 9, 0), End: at(0, 9, 10)}, {Start: at(0, 11, 10), End: at(0, 12, 0)}}
+
+		withLimit := opts
+		withLimit.Limit = 2
+		assert.Equal(t, []Interval{slot(0, 12, 0, time.Hour), slot(0, 13, 0, time.Hour)}, Find(withLimit, busy))
+	})
+
+	t.Run("Buffer", func(t *testing.T) {
+		busy := []Interval{{Start: at(0, 9, 0), End: at(0, 10, 0)}, {Start: at(0, 12, 0), End: at(0, 18, 0)}}
+
+		withBuffer := opts
+		withBuffer.Buffer = 15 * time.Minute
+		withBuffer.Limit = 1
+		assert.Equal(t, []Interval{slot(0, 10, 15, time.Hour)}, Find(withBuffer, busy))
+	})
+
+	t.Run("Working days", func(t *testing.T) {
+		weekend := opts
+		weekend.From, weekend.To = at(4, 17, 30), at(8, 0, 0)
+		weekend.Limit = 1
+		assert.Equal(t, []Interval{slot(7, 9, 0, time.Hour)}, Find(weekend, nil))
+
+		weekend.To = at(7, 0, 0)
+		assert.Empty(t, Find(weekend))
+
+		weekend.WorkDays = []time.Weekday{time.Saturday}
+		assert.Equal(t, []Interval{slot(5, 9, 0, time.Hour)}, Find(weekend))
+	})
+
+	t.Run("Time zone", func(t *testing.T) {
+		// рабочие часы по местному времени и после перехода на летнее время
+		berlin, err := time.LoadLocation("Europe/Berlin")
+		require.NoError(t, err)
+
+		local := opts
+		local.From = time.Date(2024, 3, 29, 0, 0, 0, 0, berlin)
+		local.To = local.From.AddDate(0, 0, 4)
+		local.WorkDays = []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}
+		local.Limit = 1
+		slots := Find(local, []Interval{{Start: local.From, End: local.From.AddDate(0, 0, 2)}})
+		if assert.Len(t, slots, 1) {
+			assert.True(t, time.Date(2024, 3, 31, 9, 0, 0, 0, berlin).Equal(slots[0].Start))
+		}
+	})
+}
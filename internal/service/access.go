@@ -0,0 +1,128 @@
+package service
+
+import (
+	"github.com/Komilov31/calendar-service/internal/apperror"
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+// Права на данные пользователя: сам пользователь - владелец всех своих календарей,
+// остальным роли выдаются в ACL календаря. Проверка выполняется перед обращением
+// к хранилищу для каждого метода Service.
+
+var ErrAccessDenied = apperror.Forbidden("access_denied", "not enough rights for this calendar")
+
+// roles возвращает роли actor в календарях пользователя userId
+func (s *Service) roles(actor, userId int) (map[int]string, error) {
+	calendars, err := s.storage.GetCalendars(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[int]string, len(calendars))
+	for _, calendar := range calendars {
+		entries, err := s.storage.GetACL(userId, calendar.CalendarId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.UserId != actor {
+				continue
+			}
+			roles[calendar.CalendarId] = entry.Role
+		}
+	}
+
+	return roles, nil
+}
+
+// requireRole проверяет, что у actor есть права роли required в календаре calendarId
+// пользователя userId
+func (s *Service) requireRole(actor, userId, calendarId int, required string) error {
+	if actor == userId {
+		return nil
+	}
+
+	roles, err := s.roles(actor, userId)
+	if err != nil {
+		return err
+	}
+
+	if !model.RoleAllows(roles[calendarId], required) {
+		return ErrAccessDenied
+	}
+
+	return nil
+}
+
+// requireEventRole проверяет права actor в календаре события
+func (s *Service) requireEventRole(actor, userId, eventId int, required string) error {
+	if actor == userId {
+		return nil
+	}
+
+	event, err := s.storage.GetEvent(userId, eventId)
+	if err != nil {
+		return err
+	}
+
+	return s.requireRole(actor, userId, event.CalendarId, required)
+}
+
+// requireActive проверяет, что владелец данных зарегистрирован и не отключен
+func (s *Service) requireActive(userId int) error {
+	user, err := s.storage.GetUser(userId)
+	if err != nil {
+		return err
+	}
+
+	if !user.Active() {
+		return ErrUserDisabled
+	}
+
+	return nil
+}
+
+// requireAttendees проверяет, что участники события зарегистрированы
+func (s *Service) requireAttendees(attendees []model.Attendee) error {
+	for _, attendee := range attendees {
+		_, err := s.storage.GetUser(attendee.UserId)
+		if err != nil && apperror.From(err).Kind == apperror.KindNotFound {
+			return ErrUnknownAttendee
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visible оставляет события, которые видит actor. С ролью freebusy от события
+// остается только время. Без доступа ни к одному календарю выборка запрещена.
+func (s *Service) visible(actor, userId int, events []*model.Event) ([]*model.Event, error) {
+	if actor == userId {
+		return events, nil
+	}
+
+	roles, err := s.roles(actor, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, ErrAccessDenied
+	}
+
+	var result []*model.Event
+	for _, event := range events {
+		switch role := roles[event.CalendarId]; {
+		case role == model.RoleFreeBusy:
+			busy := event.FreeBusy()
+			result = append(result, &busy)
+		case model.RoleAllows(role, model.RoleViewer):
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccess(t *testing.T) {
+	const owner, editor, viewer, busy, stranger = 1, 2, 3, 4, 5
+
+	s := New(repository.New())
+	start := model.Date(time.Date(2030, 1, 15, 10, 0, 0, 0, time.UTC))
+	end := model.Date(time.Time(start).Add(time.Hour))
+
+	for _, userId := range []int{owner, editor, viewer, busy, stranger} {
+		_, err := s.CreateUser(userId, model.User{UserId: userId, Name: "User", Email: fmt.Sprintf("user%d@example.com", userId)})
+		require.NoError(t, err)
+	}
+
+	personal, err := s.CreateCalendar(owner, model.Calendar{UserId: owner, Name: "Personal"})
+	require.NoError(t, err)
+	work, err := s.CreateCalendar(owner, model.Calendar{UserId: owner, Name: "Work"})
+	require.NoError(t, err)
+
+	for grantee, role := range map[int]string{editor: model.RoleEditor, viewer: model.RoleViewer, busy: model.RoleFreeBusy} {
+		_, err := s.PutACL(owner, owner, model.ACLEntry{CalendarId: work.CalendarId, UserId: grantee, Role: role})
+		require.NoError(t, err)
+	}
+
+	secret, err := s.CreateEvent(owner, model.Event{UserId: owner, CalendarId: personal.CalendarId, Text: "Dentist", Start: start})
+	require.NoError(t, err)
+	standup, err := s.CreateEvent(owner, model.Event{UserId: owner, CalendarId: work.CalendarId, Text: "Standup", Start: start, End: end})
+	require.NoError(t, err)
+
+	t.Run("Editor", func(t *testing.T) {
+		_, err := s.CreateEvent(editor, model.Event{UserId: owner, CalendarId: work.CalendarId, Text: "Review", Start: start})
+		assert.NoError(t, err)
+
+		_, err = s.CreateEvent(editor, model.Event{UserId: owner, CalendarId: personal.CalendarId, Text: "Review", Start: start})
+		assert.Equal(t, ErrAccessDenied, err)
+
+		// перенести событие можно только в календарь, где есть права редактора
+		_, err = s.UpdateEvent(editor, model.UpdateEvent{EventId: &standup.EventId, UserId: &standup.UserId, CalendarId: &personal.CalendarId})
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.PutACL(editor, owner, model.ACLEntry{CalendarId: work.CalendarId, UserId: stranger, Role: model.RoleViewer})
+		assert.Equal(t, ErrAccessDenied, err)
+	})
+
+	t.Run("Viewer", func(t *testing.T) {
+		events, err := s.GetEvents(viewer, owner)
+		assert.NoError(t, err)
+		for _, event := range events {
+			assert.Equal(t, work.CalendarId, event.CalendarId)
+		}
+
+		_, err = s.GetEvent(viewer, owner, secret.EventId, time.UTC)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		err = s.DeleteEvent(viewer, model.DeleteEvent{UserId: owner, EventId: standup.EventId})
+		assert.Equal(t, ErrAccessDenied, err)
+
+		calendars, err := s.GetCalendars(viewer, owner)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Calendar{work}, calendars)
+	})
+
+	t.Run("Free/busy", func(t *testing.T) {
+		event, err := s.GetEvent(busy, owner, standup.EventId, time.UTC)
+		require.NoError(t, err)
+		assert.Empty(t, event.Text)
+		assert.Equal(t, time.Time(start), time.Time(event.Start))
+		assert.Equal(t, time.Time(end), time.Time(event.End))
+
+		events, err := s.GetEventsForDay(busy, owner, time.Time(start))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		for _, event := range events {
+			assert.Empty(t, event.Text)
+		}
+	})
+
+	t.Run("Stranger", func(t *testing.T) {
+		_, err := s.GetEvents(stranger, owner)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.GetCalendars(stranger, owner)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		err = s.DeleteEvent(stranger, model.DeleteEvent{UserId: owner, EventId: secret.EventId})
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.GetUserSettings(stranger, owner)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.GetCalendar(stranger, owner, work.CalendarId)
+		assert.Equal(t, ErrAccessDenied, err)
+	})
+
+	t.Run("Stranger manages access through own path", func(t *testing.T) {
+		// календарь владельца под адресом постороннего не находится
+		_, err := s.PutACL(stranger, stranger, model.ACLEntry{CalendarId: personal.CalendarId, UserId: stranger, Role: model.RoleOwner})
+		assert.Equal(t, repository.ErrNoSuchCalendar, err)
+
+		_, err = s.GetACL(stranger, stranger, work.CalendarId)
+		assert.Equal(t, repository.ErrNoSuchCalendar, err)
+
+		err = s.DeleteACL(stranger, stranger, work.CalendarId, viewer)
+		assert.Equal(t, repository.ErrNoSuchCalendar, err)
+
+		_, err = s.GetEvent(stranger, owner, secret.EventId, time.UTC)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		entries, err := s.GetACL(owner, owner, work.CalendarId)
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+}
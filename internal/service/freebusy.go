@@ -0,0 +1,81 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+)
+
+// GetFreeBusy возвращает занятость пользователя userId в периоде [from, to) в зоне from:
+// объединенные интервалы непрозрачных событий, включая экземпляры серий и принятые
+// приглашения. Чужую занятость видит пользователь с доступом хотя бы к одному календарю
+// userId, в нее входят только события открытых ему календарей. Доступ проверяется
+// до чтения событий.
+func (s *Service) GetFreeBusy(actor, userId int, from, to time.Time) ([]model.Interval, error) {
+	var roles map[int]string
+	if actor != userId {
+		var err error
+		roles, err = s.roles(actor, userId)
+		if err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 {
+			return nil, ErrAccessDenied
+		}
+	}
+
+	events, err := s.storage.GetEventsInRange(userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var busy []model.Interval
+	for _, event := range events {
+		if !event.Busy(userId) {
+			continue
+		}
+
+		// приглашения относятся к календарям организатора, занятость участника по ним видна всем
+		if roles != nil && event.UserId == userId && roles[event.CalendarId] == "" {
+			continue
+		}
+
+		// интервалы обрезаются по границам периода
+		inLoc := event.In(from.Location())
+		start, end := time.Time(inLoc.Start), time.Time(inLoc.End)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			busy = append(busy, model.Interval{Start: model.Date(start), End: model.Date(end)})
+		}
+	}
+
+	return mergeIntervals(busy), nil
+}
+
+// mergeIntervals объединяет пересекающиеся и смежные интервалы
+func mergeIntervals(intervals []model.Interval) []model.Interval {
+	slices.SortFunc(intervals, func(a, b model.Interval) int {
+		return time.Time(a.Start).Compare(time.Time(b.Start))
+	})
+
+	merged := []model.Interval{}
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !time.Time(interval.Start).After(time.Time(merged[last].End)) {
+			if time.Time(interval.End).After(time.Time(merged[last].End)) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
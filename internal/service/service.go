@@ -3,18 +3,51 @@ package service
 import (
 	"time"
 
+	"github.com/Komilov31/calendar-service/internal/apperror"
 	"github.com/Komilov31/calendar-service/internal/model"
 )
 
+var (
+	ErrInvalidTimeZone = apperror.Validation("invalid_time_zone", "unknown time zone")
+	ErrUserDisabled    = apperror.Forbidden("user_disabled", "user is disabled, their calendars are read-only")
+	ErrUnknownAttendee = apperror.Validation("unknown_attendee", "attendee is not a registered user")
+)
+
 type EventStorage interface {
-	CreateEvent(model.Event) model.Event
+	CreateEvent(model.Event) (model.Event, error)
 	UpdateEvent(model.UpdateEvent) (model.Event, error)
-	DeleteEvent(int, int) error
+	DeleteEvent(model.DeleteEvent) error
+	GetEvent(int, int) (model.Event, error)
+	GetEventByUID(int, string) (model.Event, error)
+	GetEvents(int) ([]*model.Event, error)
 	GetEventsForDay(int, time.Time) ([]*model.Event, error)
 	GetEventsForWeek(int, time.Time) ([]*model.Event, error)
+	GetEventsForISOWeek(int, time.Time) ([]*model.Event, error)
 	GetEventsForMonth(int, time.Time) ([]*model.Event, error)
+	GetEventsForQuarter(int, time.Time) ([]*model.Event, error)
+	GetEventsForYear(int, time.Time) ([]*model.Event, error)
+	GetEventsInRange(int, time.Time, time.Time) ([]*model.Event, error)
+	UpdateUserSettings(model.UserSettings) (model.UserSettings, error)
+	GetUserSettings(int) (model.UserSettings, error)
+	CreateCalendar(model.Calendar) (model.Calendar, error)
+	UpdateCalendar(model.Calendar) (model.Calendar, error)
+	DeleteCalendar(int, int) error
+	GetCalendar(int, int) (model.Calendar, error)
+	GetCalendars(int) ([]model.Calendar, error)
+	PutACL(int, model.ACLEntry) (model.ACLEntry, error)
+	DeleteACL(int, int, int) error
+	GetACL(int, int) ([]model.ACLEntry, error)
+	CreateUser(model.User) (model.User, error)
+	UpdateUser(model.User) (model.User, error)
+	GetUser(int) (model.User, error)
+	DisableUser(int) (model.User, error)
+	DeleteUser(int) error
+	Respond(int, int, string) (model.Event, error)
+	GetInvitations(int) ([]*model.Event, error)
 }
 
+// Service выполняет запросы от имени пользователя actor (первый аргумент методов)
+// и проверяет его права на календари владельца данных, см. access.go
 type Service struct {
 	storage EventStorage
 }
@@ -25,26 +58,355 @@ func New(storage EventStorage) *Service {
 	}
 }
 
-func (s *Service) CreateEvent(event model.Event) model.Event {
+// CreateEvent создает событие в календаре event.CalendarId пользователя event.UserId
+func (s *Service) CreateEvent(actor int, event model.Event) (model.Event, error) {
+	if err := s.CanCreateEvent(actor, event); err != nil {
+		return model.Event{}, err
+	}
+
 	return s.storage.CreateEvent(event)
 }
 
-func (s *Service) UpdateEvent(updateEvent model.UpdateEvent) (model.Event, error) {
+// CanCreateEvent выполняет проверки CreateEvent без создания события: права actor,
+// активность владельца и участников. Нужна для проверки импорта без записи.
+func (s *Service) CanCreateEvent(actor int, event model.Event) error {
+	if err := s.requireRole(actor, event.UserId, event.CalendarId, model.RoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.requireActive(event.UserId); err != nil {
+		return err
+	}
+
+	return s.requireAttendees(event.Attendees)
+}
+
+func (s *Service) UpdateEvent(actor int, updateEvent model.UpdateEvent) (model.Event, error) {
+	if err := s.requireEventRole(actor, *updateEvent.UserId, *updateEvent.EventId, model.RoleEditor); err != nil {
+		return model.Event{}, err
+	}
+
+	// перенос в другой календарь требует права менять и его события
+	if updateEvent.CalendarId != nil {
+		if err := s.requireRole(actor, *updateEvent.UserId, *updateEvent.CalendarId, model.RoleEditor); err != nil {
+			return model.Event{}, err
+		}
+	}
+
+	if err := s.requireActive(*updateEvent.UserId); err != nil {
+		return model.Event{}, err
+	}
+
+	if updateEvent.Attendees != nil {
+		if err := s.requireAttendees(*updateEvent.Attendees); err != nil {
+			return model.Event{}, err
+		}
+	}
+
 	return s.storage.UpdateEvent(updateEvent)
 }
 
-func (s *Service) DeleteEvent(userId int, eventId int) error {
-	return s.storage.DeleteEvent(userId, eventId)
+func (s *Service) DeleteEvent(actor int, deleteEvent model.DeleteEvent) error {
+	if err := s.requireEventRole(actor, deleteEvent.UserId, deleteEvent.EventId, model.RoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.requireActive(deleteEvent.UserId); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteEvent(deleteEvent)
+}
+
+// GetEvent возвращает событие с временами в зоне loc
+func (s *Service) GetEvent(actor, userId, eventId int, loc *time.Location) (model.Event, error) {
+	event, err := s.storage.GetEvent(userId, eventId)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	return s.visibleEvent(actor, userId, event, loc)
+}
+
+// GetEventByUID возвращает событие пользователя userId с сохраненным UID
+func (s *Service) GetEventByUID(actor, userId int, uid string, loc *time.Location) (model.Event, error) {
+	event, err := s.storage.GetEventByUID(userId, uid)
+	if err != nil {
+		return model.Event{}, err
+	}
+
+	return s.visibleEvent(actor, userId, event, loc)
+}
+
+// событие, каким его видит actor, с временами в зоне loc
+func (s *Service) visibleEvent(actor, userId int, event model.Event, loc *time.Location) (model.Event, error) {
+	events, err := s.visible(actor, userId, []*model.Event{&event})
+	if err != nil {
+		return model.Event{}, err
+	}
+	if len(events) == 0 {
+		return model.Event{}, ErrAccessDenied
+	}
+
+	return events[0].In(loc), nil
+}
+
+// GetEvents возвращает все события пользователя вместе с исключениями серий, времена в UTC
+func (s *Service) GetEvents(actor, userId int) ([]*model.Event, error) {
+	events, err := s.storage.GetEvents(userId)
+	return s.visibleIn(actor, userId, events, time.UTC, err)
+}
+
+// выборки считаются в зоне date, в ней же возвращаются времена событий
+func (s *Service) GetEventsForDay(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForDay(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
+}
+
+func (s *Service) GetEventsForWeek(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForWeek(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
+}
+
+func (s *Service) GetEventsForISOWeek(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForISOWeek(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
+}
+
+func (s *Service) GetEventsForMonth(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForMonth(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
+}
+
+func (s *Service) GetEventsForQuarter(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForQuarter(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
 }
 
-func (s *Service) GetEventsForDay(userId int, date time.Time) ([]*model.Event, error) {
-	return s.storage.GetEventsForDay(userId, date)
+func (s *Service) GetEventsForYear(actor, userId int, date time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsForYear(userId, date)
+	return s.visibleIn(actor, userId, events, date.Location(), err)
 }
 
-func (s *Service) GetEventsForWeek(userId int, date time.Time) ([]*model.Event, error) {
-	return s.storage.GetEventsForWeek(userId, date)
+func (s *Service) GetEventsInRange(actor, userId int, from, to time.Time) ([]*model.Event, error) {
+	events, err := s.storage.GetEventsInRange(userId, from, to)
+	return s.visibleIn(actor, userId, events, from.Location(), err)
 }
 
-func (s *Service) GetEventsForMonth(userId int, date time.Time) ([]*model.Event, error) {
-	return s.storage.GetEventsForMonth(userId, date)
+// настройки пользователя доступны только ему самому
+func (s *Service) UpdateUserSettings(actor int, settings model.UserSettings) (model.UserSettings, error) {
+	if actor != settings.UserId {
+		return model.UserSettings{}, ErrAccessDenied
+	}
+
+	return s.storage.UpdateUserSettings(settings)
+}
+
+func (s *Service) GetUserSettings(actor, userId int) (model.UserSettings, error) {
+	if actor != userId {
+		return model.UserSettings{}, ErrAccessDenied
+	}
+
+	return s.storage.GetUserSettings(userId)
+}
+
+// календари создаются только в своем профиле
+func (s *Service) CreateCalendar(actor int, calendar model.Calendar) (model.Calendar, error) {
+	if actor != calendar.UserId {
+		return model.Calendar{}, ErrAccessDenied
+	}
+
+	if err := s.requireActive(calendar.UserId); err != nil {
+		return model.Calendar{}, err
+	}
+
+	return s.storage.CreateCalendar(calendar)
+}
+
+func (s *Service) UpdateCalendar(actor int, calendar model.Calendar) (model.Calendar, error) {
+	if err := s.requireRole(actor, calendar.UserId, calendar.CalendarId, model.RoleOwner); err != nil {
+		return model.Calendar{}, err
+	}
+
+	return s.storage.UpdateCalendar(calendar)
+}
+
+// DeleteCalendar удаляет календарь вместе с его событиями
+func (s *Service) DeleteCalendar(actor, userId, calendarId int) error {
+	if err := s.requireRole(actor, userId, calendarId, model.RoleOwner); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteCalendar(userId, calendarId)
+}
+
+func (s *Service) GetCalendar(actor, userId, calendarId int) (model.Calendar, error) {
+	if err := s.requireRole(actor, userId, calendarId, model.RoleFreeBusy); err != nil {
+		return model.Calendar{}, err
+	}
+
+	return s.storage.GetCalendar(userId, calendarId)
+}
+
+// GetCalendars возвращает календари пользователя, к которым у actor есть доступ
+func (s *Service) GetCalendars(actor, userId int) ([]model.Calendar, error) {
+	calendars, err := s.storage.GetCalendars(userId)
+	if err != nil || actor == userId {
+		return calendars, err
+	}
+
+	roles, err := s.roles(actor, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		return nil, ErrAccessDenied
+	}
+
+	shared := []model.Calendar{}
+	for _, calendar := range calendars {
+		if roles[calendar.CalendarId] != "" {
+			shared = append(shared, calendar)
+		}
+	}
+
+	return shared, nil
+}
+
+// доступами календаря управляют его владельцы
+func (s *Service) GetACL(actor, userId, calendarId int) ([]model.ACLEntry, error) {
+	if err := s.requireRole(actor, userId, calendarId, model.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.storage.GetACL(userId, calendarId)
+}
+
+func (s *Service) PutACL(actor, userId int, entry model.ACLEntry) (model.ACLEntry, error) {
+	if err := s.requireRole(actor, userId, entry.CalendarId, model.RoleOwner); err != nil {
+		return model.ACLEntry{}, err
+	}
+
+	return s.storage.PutACL(userId, entry)
+}
+
+func (s *Service) DeleteACL(actor, userId, calendarId, grantee int) error {
+	if err := s.requireRole(actor, userId, calendarId, model.RoleOwner); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteACL(userId, calendarId, grantee)
+}
+
+// пользователь регистрирует и меняет только себя. Без UserId выдается новый id: такой
+// запрос приходит от администратора или без аутентификации
+func (s *Service) CreateUser(actor int, user model.User) (model.User, error) {
+	if actor != user.UserId {
+		return model.User{}, ErrAccessDenied
+	}
+
+	return s.storage.CreateUser(user)
+}
+
+func (s *Service) UpdateUser(actor int, user model.User) (model.User, error) {
+	if actor != user.UserId {
+		return model.User{}, ErrAccessDenied
+	}
+
+	return s.storage.UpdateUser(user)
+}
+
+func (s *Service) GetUser(actor, userId int) (model.User, error) {
+	if actor != userId {
+		return model.User{}, ErrAccessDenied
+	}
+
+	return s.storage.GetUser(userId)
+}
+
+// DeleteUser отключает пользователя, оставляя его календари в архиве, а с cascade
+// удаляет его вместе с календарями, событиями и доступами
+func (s *Service) DeleteUser(actor, userId int, cascade bool) error {
+	if actor != userId {
+		return ErrAccessDenied
+	}
+
+	if cascade {
+		return s.storage.DeleteUser(userId)
+	}
+
+	_, err := s.storage.DisableUser(userId)
+	return err
+}
+
+// GetInvitations возвращает события других пользователей, куда приглашен userId,
+// времена в UTC
+func (s *Service) GetInvitations(actor, userId int) ([]*model.Event, error) {
+	if actor != userId {
+		return nil, ErrAccessDenied
+	}
+
+	events, err := s.storage.GetInvitations(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return inLocation(events, time.UTC), nil
+}
+
+// Respond сохраняет ответ userId на приглашение. Отвечает только сам участник.
+func (s *Service) Respond(actor, userId, eventId int, status string) (model.Event, error) {
+	if actor != userId {
+		return model.Event{}, ErrAccessDenied
+	}
+
+	if err := s.requireActive(userId); err != nil {
+		return model.Event{}, err
+	}
+
+	return s.storage.Respond(userId, eventId, status)
+}
+
+// Location выбирает зону для запроса: явно переданную tz, иначе сохраненную у пользователя
+func (s *Service) Location(userId int, tz string) (*time.Location, error) {
+	if tz == "" {
+		settings, err := s.storage.GetUserSettings(userId)
+		if err != nil {
+			return nil, err
+		}
+		tz = settings.TimeZone
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+
+	return loc, nil
+}
+
+// события, доступные actor, в зоне loc
+func (s *Service) visibleIn(actor, userId int, events []*model.Event, loc *time.Location, err error) ([]*model.Event, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	events, err = s.visible(actor, userId, events)
+	return inLocation(events, loc), err
+}
+
+// копируем события, чтобы не отдавать наружу указатели на данные хранилища
+func inLocation(events []*model.Event, loc *time.Location) []*model.Event {
+	if events == nil {
+		return nil
+	}
+
+	result := make([]*model.Event, 0, len(events))
+	for _, event := range events {
+		inLoc := event.In(loc)
+		result = append(result, &inLoc)
+	}
+
+	return result
 }
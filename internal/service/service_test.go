@@ -0,0 +1,278 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers(t *testing.T) {
+	s := New(repository.New())
+	start := model.Date(time.Now().Add(48 * time.Hour))
+
+	t.Run("Events only for registered users", func(t *testing.T) {
+		_, err := s.CreateEvent(1, model.Event{UserId: 1, Text: "Event", Start: start})
+		assert.Equal(t, repository.ErrNoSuchUser, err)
+
+		_, err = s.CreateUser(1, model.User{UserId: 1, Name: "Alice", Email: "alice@example.com"})
+		require.NoError(t, err)
+
+		_, err = s.CreateEvent(1, model.Event{UserId: 1, Text: "Event", Start: start})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Only self", func(t *testing.T) {
+		_, err := s.CreateUser(2, model.User{UserId: 3, Name: "Mallory", Email: "mallory@example.com"})
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.GetUser(2, 1)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		assert.Equal(t, ErrAccessDenied, s.DeleteUser(2, 1, true))
+	})
+
+	t.Run("Archive", func(t *testing.T) {
+		require.NoError(t, s.DeleteUser(1, 1, false))
+
+		_, err := s.CreateEvent(1, model.Event{UserId: 1, Text: "Event", Start: start})
+		assert.Equal(t, ErrUserDisabled, err)
+
+		_, err = s.CreateCalendar(1, model.Calendar{UserId: 1, Name: "Work"})
+		assert.Equal(t, ErrUserDisabled, err)
+
+		// календари и события остаются доступны для чтения
+		events, err := s.GetEvents(1, 1)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Cascade", func(t *testing.T) {
+		require.NoError(t, s.DeleteUser(1, 1, true))
+
+		_, err := s.GetEvents(1, 1)
+		assert.Equal(t, repository.ErrNoSuchUser, err)
+
+		calendars, err := s.GetCalendars(1, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, calendars)
+	})
+}
+
+func TestInvitations(t *testing.T) {
+	s := New(repository.New())
+	for _, user := range []model.User{{UserId: 1, Name: "Alice"}, {UserId: 2, Name: "Bob"}, {UserId: 3, Name: "Carol"}} {
+		_, err := s.CreateUser(user.UserId, user)
+		require.NoError(t, err)
+	}
+	start := model.Date(time.Now().Add(48 * time.Hour))
+
+	_, err := s.CreateEvent(1, model.Event{UserId: 1, Text: "Sync", Start: start, Attendees: []model.Attendee{{UserId: 9}}})
+	assert.Equal(t, ErrUnknownAttendee, err)
+
+	meeting, err := s.CreateEvent(1, model.Event{UserId: 1, Text: "Sync", Start: start, Attendees: []model.Attendee{{UserId: 2}}})
+	require.NoError(t, err)
+
+	t.Run("Only attendee responds", func(t *testing.T) {
+		_, err := s.Respond(1, 2, meeting.EventId, model.StatusAccepted)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		_, err = s.GetInvitations(1, 2)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		event, err := s.Respond(2, 2, meeting.EventId, model.StatusAccepted)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Attendee{{UserId: 2, Status: model.StatusAccepted}}, event.Attendees)
+	})
+
+	t.Run("Not invited", func(t *testing.T) {
+		// ни посторонний, ни организатор не участники встречи
+		_, err := s.Respond(3, 3, meeting.EventId, model.StatusAccepted)
+		assert.Equal(t, repository.ErrNoSuchInvitation, err)
+
+		_, err = s.Respond(1, 1, meeting.EventId, model.StatusDeclined)
+		assert.Equal(t, repository.ErrNoSuchInvitation, err)
+
+		event, err := s.GetEvent(1, 1, meeting.EventId, time.UTC)
+		require.NoError(t, err)
+		assert.Equal(t, []model.Attendee{{UserId: 2, Status: model.StatusAccepted}}, event.Attendees)
+
+		invitations, err := s.GetInvitations(3, 3)
+		assert.NoError(t, err)
+		assert.Empty(t, invitations)
+	})
+
+	t.Run("Invitation in own view", func(t *testing.T) {
+		event, err := s.GetEvent(2, 2, meeting.EventId, time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sync", event.Text)
+
+		invitations, err := s.GetInvitations(2, 2)
+		assert.NoError(t, err)
+		assert.Len(t, invitations, 1)
+	})
+}
+
+func TestFreeBusy(t *testing.T) {
+	s := New(repository.New())
+	for _, user := range []model.User{{UserId: 1, Name: "Alice"}, {UserId: 2, Name: "Bob"}, {UserId: 3, Name: "Carol"}} {
+		_, err := s.CreateUser(user.UserId, user)
+		require.NoError(t, err)
+	}
+
+	day := time.Now().AddDate(0, 0, 2).UTC().Truncate(24 * time.Hour)
+	at := func(hour, minute int) model.Date {
+		return model.Date(day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute))
+	}
+
+	for _, event := range []model.Event{
+		{UserId: 1, Text: "Standup", Start: at(9, 0), End: at(9, 30), Recurrence: "FREQ=DAILY;COUNT=5"},
+		{UserId: 1, Text: "Review", Start: at(9, 15), End: at(10, 0)},
+		{UserId: 1, Text: "Focus time", Start: at(13, 0), End: at(15, 0), Transparency: model.TransparencyTransparent},
+		{UserId: 1, Text: "Lunch", Start: at(12, 0), End: at(13, 0)},
+	} {
+		_, err := s.CreateEvent(1, event)
+		require.NoError(t, err)
+	}
+
+	meeting, err := s.CreateEvent(2, model.Event{UserId: 2, Text: "1:1", Start: at(16, 0), End: at(17, 0), Attendees: []model.Attendee{{UserId: 1}}})
+	require.NoError(t, err)
+
+	from, to := day, day.Add(24*time.Hour)
+	interval := func(fromHour, fromMinute, toHour, toMinute int) model.Interval {
+		return model.Interval{Start: at(fromHour, fromMinute), End: at(toHour, toMinute)}
+	}
+
+	t.Run("Merged busy intervals", func(t *testing.T) {
+		busy, err := s.GetFreeBusy(1, 1, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Interval{interval(9, 0, 10, 0), interval(12, 0, 13, 0)}, busy)
+	})
+
+	t.Run("Accepted invitations", func(t *testing.T) {
+		_, err := s.Respond(1, 1, meeting.EventId, model.StatusAccepted)
+		require.NoError(t, err)
+
+		busy, err := s.GetFreeBusy(1, 1, from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Interval{interval(9, 0, 10, 0), interval(12, 0, 13, 0), interval(16, 0, 17, 0)}, busy)
+	})
+
+	t.Run("Clipped to window", func(t *testing.T) {
+		busy, err := s.GetFreeBusy(1, 1, time.Time(at(9, 45)), time.Time(at(12, 30)))
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Interval{interval(9, 45, 10, 0), interval(12, 0, 12, 30)}, busy)
+	})
+
+	t.Run("Shared calendars only", func(t *testing.T) {
+		_, err := s.GetFreeBusy(3, 1, from, to)
+		assert.Equal(t, ErrAccessDenied, err)
+
+		calendars, err := s.GetCalendars(1, 1)
+		require.NoError(t, err)
+		_, err = s.PutACL(1, 1, model.ACLEntry{CalendarId: calendars[0].CalendarId, UserId: 3, Role: model.RoleFreeBusy})
+		require.NoError(t, err)
+
+		busy, err := s.GetFreeBusy(3, 1, from, to)
+		assert.NoError(t, err)
+		assert.Len(t, busy, 3)
+	})
+}
+
+func TestFreeBusyRoles(t *testing.T) {
+	const owner, viewer, busy, stranger = 1, 2, 3, 4
+
+	s := New(repository.New())
+	for _, userId := range []int{owner, viewer, busy, stranger} {
+		_, err := s.CreateUser(userId, model.User{UserId: userId, Name: "User"})
+		require.NoError(t, err)
+	}
+
+	personal, err := s.CreateCalendar(owner, model.Calendar{UserId: owner, Name: "Personal"})
+	require.NoError(t, err)
+	work, err := s.CreateCalendar(owner, model.Calendar{UserId: owner, Name: "Work"})
+	require.NoError(t, err)
+
+	for grantee, role := range map[int]string{viewer: model.RoleViewer, busy: model.RoleFreeBusy} {
+		_, err := s.PutACL(owner, owner, model.ACLEntry{CalendarId: work.CalendarId, UserId: grantee, Role: role})
+		require.NoError(t, err)
+	}
+
+	day := time.Now().AddDate(0, 0, 2).UTC().Truncate(24 * time.Hour)
+	at := func(hour int) model.Date {
+		return model.Date(day.Add(time.Duration(hour) * time.Hour))
+	}
+	for _, event := range []model.Event{
+		{UserId: owner, CalendarId: personal.CalendarId, Text: "Dentist", Start: at(9), End: at(10)},
+		{UserId: owner, CalendarId: work.CalendarId, Text: "Planning", Start: at(11), End: at(12)},
+	} {
+		_, err := s.CreateEvent(owner, event)
+		require.NoError(t, err)
+	}
+
+	from, to := day, day.Add(24*time.Hour)
+	workOnly := []model.Interval{{Start: at(11), End: at(12)}}
+
+	t.Run("Viewer and freebusy see the same shared calendar", func(t *testing.T) {
+		for _, actor := range []int{viewer, busy} {
+			intervals, err := s.GetFreeBusy(actor, owner, from, to)
+			assert.NoError(t, err)
+			assert.Equal(t, workOnly, intervals, actor)
+		}
+
+		intervals, err := s.GetFreeBusy(owner, owner, from, to)
+		assert.NoError(t, err)
+		assert.Len(t, intervals, 2)
+	})
+
+	t.Run("Only viewer reads event text", func(t *testing.T) {
+		events, err := s.GetEventsInRange(viewer, owner, from, to)
+		require.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "Planning", events[0].Text)
+		}
+
+		events, err = s.GetEventsInRange(busy, owner, from, to)
+		require.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Empty(t, events[0].Text)
+		}
+	})
+
+	t.Run("No role", func(t *testing.T) {
+		_, err := s.GetFreeBusy(stranger, owner, from, to)
+		assert.Equal(t, ErrAccessDenied, err)
+	})
+}
+
+// хранилище, которое считает чтения событий за период
+type rangeCounter struct {
+	EventStorage
+	reads int
+}
+
+func (r *rangeCounter) GetEventsInRange(userId int, from, to time.Time) ([]*model.Event, error) {
+	r.reads++
+	return r.EventStorage.GetEventsInRange(userId, from, to)
+}
+
+func TestFreeBusyAccessBeforeRead(t *testing.T) {
+	storage := &rangeCounter{EventStorage: repository.New()}
+	s := New(storage)
+	for _, user := range []model.User{{UserId: 1, Name: "Alice"}, {UserId: 2, Name: "Bob"}} {
+		_, err := s.CreateUser(user.UserId, user)
+		require.NoError(t, err)
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	_, err := s.GetFreeBusy(2, 1, from, from.Add(24*time.Hour))
+	assert.Equal(t, ErrAccessDenied, err)
+	assert.Zero(t, storage.reads)
+
+	_, err = s.GetFreeBusy(1, 1, from, from.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, storage.reads)
+}
@@ -1,36 +1,156 @@
 package validator
 
 import (
-	"fmt"
+	"cmp"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Komilov31/calendar-service/internal/apperror"
 	"github.com/Komilov31/calendar-service/internal/model"
+	"github.com/Komilov31/calendar-service/internal/rrule"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	ru_translations "github.com/go-playground/validator/v10/translations/ru"
 )
 
 var Validate *validator.Validate
 
+// переводчики сообщений об ошибках, язык по умолчанию - английский
+var translators = ut.New(en.New(), en.New(), ru.New())
+
+// сообщения для собственных правил и правил, у которых нет стандартного перевода
+var customTranslations = map[string]map[string]string{
+	"en": {
+		"date_after_now":  "{0} must be a date in the future",
+		"rrule":           "{0} must be a valid recurrence rule",
+		"end_after_start": "{0} must not be before {1}",
+		"timezone":        "{0} must be a valid IANA time zone",
+	},
+	"ru": {
+		"date_after_now":  "{0} должно быть датой в будущем",
+		"rrule":           "{0} должно быть правилом повторения RFC 5545",
+		"end_after_start": "{0} не может быть раньше {1}",
+		"timezone":        "{0} должно быть временной зоной IANA",
+	},
+}
+
 func init() {
 	Validate = validator.New()
+	Validate.RegisterTagNameFunc(jsonName)
 	Validate.RegisterValidation("date_after_now", dateAfterNow)
+	Validate.RegisterValidation("rrule", validRecurrenceRule)
+	Validate.RegisterStructValidation(eventEndAfterStart, model.Event{})
+	Validate.RegisterStructValidation(updateEventEndAfterStart, model.UpdateEvent{})
+
+	registerTranslations("en", en_translations.RegisterDefaultTranslations)
+	registerTranslations("ru", ru_translations.RegisterDefaultTranslations)
+}
+
+func registerTranslations(locale string, registerDefaults func(*validator.Validate, ut.Translator) error) {
+	trans, _ := translators.GetTranslator(locale)
+	if err := registerDefaults(Validate, trans); err != nil {
+		panic("could not register validation translations: " + err.Error())
+	}
+
+	for tag, text := range customTranslations[locale] {
+		err := Validate.RegisterTranslation(tag, trans,
+			func(trans ut.Translator) error {
+				return trans.Add(tag, text, true)
+			},
+			func(trans ut.Translator, fe validator.FieldError) string {
+				msg, _ := trans.T(fe.Tag(), fe.Field(), fe.Param())
+				return msg
+			},
+		)
+		if err != nil {
+			panic("could not register validation translations: " + err.Error())
+		}
+	}
 }
 
-// функция подготовит сообщение ошибки в случае ошибки валидации поля
-func CreateValidationErrorResponse(err error) string {
-	var msg string
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		for _, fe := range validationErrors {
-			switch fe.Tag() {
-			case "required":
-				msg = fmt.Sprintf("%s is required", fe.Field())
-			case "date_after_now":
-				msg = fmt.Sprintf("%s must be a date in the future", fe.Field())
-			default:
-				msg = fmt.Sprintf("%s is not valid due to %s", fe.Field(), fe.Tag())
+// Translator выбирает язык сообщений по заголовку Accept-Language с учетом весов q
+func Translator(acceptLanguage string) ut.Translator {
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
 			}
 		}
+
+		// ru-RU -> ru
+		primary, _, _ := strings.Cut(tag, "-")
+		languages = append(languages, language{tag: strings.ToLower(primary), q: q})
+	}
+
+	slices.SortStableFunc(languages, func(a, b language) int {
+		return cmp.Compare(b.q, a.q)
+	})
+
+	tags := make([]string, 0, len(languages))
+	for _, l := range languages {
+		tags = append(tags, l.tag)
 	}
-	return msg
+
+	trans, _ := translators.FindTranslator(tags...)
+	return trans
+}
+
+// FieldErrors описывает каждое нарушенное правило: JSON путь к полю, правило,
+// его параметр и переведенное сообщение
+func FieldErrors(err error, trans ut.Translator) []apperror.FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []apperror.FieldError{{Message: err.Error()}}
+	}
+
+	fields := make([]apperror.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, apperror.FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		})
+	}
+
+	return fields
+}
+
+// Event.overrides[0].text -> overrides[0].text
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
+}
+
+// поля называются так же, как в JSON; поля без JSON имени (параметры из query) - по имени в нижнем регистре
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return strings.ToLower(field.Name)
+	}
+	return name
 }
 
 func dateAfterNow(fl validator.FieldLevel) bool {
@@ -42,3 +162,36 @@ func dateAfterNow(fl validator.FieldLevel) bool {
 	t := time.Time(date)
 	return t.After(time.Now())
 }
+
+// пустое правило означает, что событие не повторяется
+func validRecurrenceRule(fl validator.FieldLevel) bool {
+	if fl.Field().String() == "" {
+		return true
+	}
+
+	_, err := rrule.Parse(fl.Field().String())
+	return err == nil
+}
+
+// конец события не может быть раньше начала, пустой End допустим
+func eventEndAfterStart(sl validator.StructLevel) {
+	event := sl.Current().Interface().(model.Event)
+	if event.End.IsZero() {
+		return
+	}
+
+	if time.Time(event.End).Before(time.Time(event.Start)) {
+		sl.ReportError(event.End, "end", "End", "end_after_start", "start")
+	}
+}
+
+func updateEventEndAfterStart(sl validator.StructLevel) {
+	updateEvent := sl.Current().Interface().(model.UpdateEvent)
+	if updateEvent.Start == nil || updateEvent.End == nil {
+		return
+	}
+
+	if time.Time(*updateEvent.End).Before(time.Time(*updateEvent.Start)) {
+		sl.ReportError(updateEvent.End, "end", "End", "end_after_start", "start")
+	}
+}